@@ -9,6 +9,7 @@ import (
 	"github.com/Huong3203/APIPodcast/config"
 	"github.com/Huong3203/APIPodcast/models"
 	"github.com/Huong3203/APIPodcast/routes"
+	"github.com/Huong3203/APIPodcast/services"
 	"github.com/Huong3203/APIPodcast/ws"
 
 	"github.com/gin-contrib/cors"
@@ -33,6 +34,11 @@ func main() {
 		&models.FeaturedRating{},
 	)
 
+	// Worker xử lý tài liệu nền
+	services.StartDocumentWorkers(config.DB)
+	// Phiên bản audio đang đọc lại nhưng không còn job (đọc lại chạy nền từ bản cũ): đánh dấu lỗi để admin chạy lại
+	services.FailOrphanedRevoices(config.DB)
+
 	// WebSocket background worker
 	go ws.HandleNotificationMessages()
 
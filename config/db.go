@@ -51,6 +51,13 @@ func ConnectDB() {
 		&models.PodcastLuu{},
 		&models.LichSuNghe{},
 		&models.Notification{},
+		&models.ProcessingJob{},
+		&models.TapTaiLieu{},
+		&models.MauPrompt{},
+		&models.TuDienPhatAm{},
+		&models.BoNhoDemTTS{},
+		&models.CauHinhGiong{},
+		&models.PhienBanAudio{},
 	)
 	if err != nil {
 		log.Fatalf("Auto migration thất bại: %v", err)
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+type JobQueueConfig struct {
+	Workers      int           // Số worker xử lý song song
+	PollInterval time.Duration // Chu kỳ quét job mới
+	MaxAttempts  int           // Số lần thử tối đa cho mỗi bước
+	BaseBackoff  time.Duration // Thời gian chờ cơ sở, nhân đôi sau mỗi lần lỗi
+	StaleAfter   time.Duration // Job RUNNING quá lâu được coi là worker đã chết
+}
+
+func GetJobQueueConfig() JobQueueConfig {
+	return JobQueueConfig{
+		Workers:      getEnvIntOrDefault("JOB_WORKERS", 2),
+		PollInterval: time.Duration(getEnvIntOrDefault("JOB_POLL_SECONDS", 3)) * time.Second,
+		MaxAttempts:  getEnvIntOrDefault("JOB_MAX_ATTEMPTS", 5),
+		BaseBackoff:  time.Duration(getEnvIntOrDefault("JOB_BACKOFF_SECONDS", 10)) * time.Second,
+		StaleAfter:   time.Duration(getEnvIntOrDefault("JOB_STALE_MINUTES", 30)) * time.Minute,
+	}
+}
+
+func getEnvIntOrDefault(key string, defaultVal int) int {
+	if n, err := strconv.Atoi(getEnvOrDefault(key, "")); err == nil && n > 0 {
+		return n
+	}
+	return defaultVal
+}
+
+// getEnvNonNegativeIntOrDefault như getEnvIntOrDefault nhưng nhận cả 0 (VD số lần thử lại = 0 là tắt thử lại)
+func getEnvNonNegativeIntOrDefault(key string, defaultVal int) int {
+	if n, err := strconv.Atoi(getEnvOrDefault(key, "")); err == nil && n >= 0 {
+		return n
+	}
+	return defaultVal
+}
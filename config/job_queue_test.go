@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestGetEnvIntOrDefault(t *testing.T) {
+	tests := []struct {
+		val                   string
+		positive, nonNegative int
+	}{
+		{"", 3, 3},
+		{"5", 5, 5},
+		{"0", 3, 0},
+		{"-1", 3, 3},
+		{"abc", 3, 3},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_RETRIES", tt.val)
+		if got := getEnvIntOrDefault("TEST_RETRIES", 3); got != tt.positive {
+			t.Errorf("getEnvIntOrDefault(%q) = %d, muốn %d", tt.val, got, tt.positive)
+		}
+		if got := getEnvNonNegativeIntOrDefault("TEST_RETRIES", 3); got != tt.nonNegative {
+			t.Errorf("getEnvNonNegativeIntOrDefault(%q) = %d, muốn %d", tt.val, got, tt.nonNegative)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+type LLMConfig struct {
+	Provider        string        // "gemini", "openai" (API tương thích OpenAI) hoặc "fake" (chạy local, không gọi mạng)
+	Model           string        // Tên model, rỗng = mặc định theo provider
+	Temperature     float32       // Độ sáng tạo của câu trả lời
+	MaxOutputTokens int           // Giới hạn token đầu ra (0 = để provider quyết định)
+	Timeout         time.Duration // Thời gian tối đa cho 1 lần gọi
+	MaxRetries      int           // Số lần thử lại khi lỗi tạm thời (429, 5xx, timeout)
+	BaseBackoff     time.Duration // Thời gian chờ cơ sở, nhân đôi sau mỗi lần thử lại
+	TrackUsage      bool          // Ghi nhận số token đã dùng theo provider/model
+
+	GeminiAPIKey  string
+	OpenAIBaseURL string
+	OpenAIAPIKey  string
+}
+
+func GetLLMConfig() LLMConfig {
+	return LLMConfig{
+		Provider:        getEnvOrDefault("LLM_PROVIDER", "gemini"),
+		Model:           getEnvOrDefault("LLM_MODEL", ""),
+		Temperature:     float32(getEnvFloatOrDefault("LLM_TEMPERATURE", 0.3)),
+		MaxOutputTokens: getEnvIntOrDefault("LLM_MAX_OUTPUT_TOKENS", 0),
+		Timeout:         time.Duration(getEnvIntOrDefault("LLM_TIMEOUT_SECONDS", 120)) * time.Second,
+		MaxRetries:      getEnvNonNegativeIntOrDefault("LLM_MAX_RETRIES", 3),
+		BaseBackoff:     time.Duration(getEnvIntOrDefault("LLM_BACKOFF_SECONDS", 2)) * time.Second,
+		TrackUsage:      getEnvOrDefault("LLM_TRACK_USAGE", "true") == "true",
+
+		GeminiAPIKey:  getEnvOrDefault("GEMINI_API_KEY", ""),
+		OpenAIBaseURL: getEnvOrDefault("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+		OpenAIAPIKey:  getEnvOrDefault("OPENAI_API_KEY", ""),
+	}
+}
+
+func getEnvFloatOrDefault(key string, defaultVal float64) float64 {
+	if f, err := strconv.ParseFloat(getEnvOrDefault(key, ""), 64); err == nil && f >= 0 {
+		return f
+	}
+	return defaultVal
+}
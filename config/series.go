@@ -0,0 +1,13 @@
+package config
+
+type SeriesConfig struct {
+	EpisodeMaxChars int // Tài liệu dài hơn mức này được tách thành nhiều tập
+	EpisodeMinChars int // Phần ngắn hơn mức này được gộp vào tập kế bên
+}
+
+func GetSeriesConfig() SeriesConfig {
+	return SeriesConfig{
+		EpisodeMaxChars: getEnvIntOrDefault("SERIES_EPISODE_MAX_CHARS", 20000),
+		EpisodeMinChars: getEnvIntOrDefault("SERIES_EPISODE_MIN_CHARS", 3000),
+	}
+}
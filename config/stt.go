@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+type STTConfig struct {
+	Provider     string        // "google" hoặc "fake" (chạy local không cần credentials)
+	LanguageCode string        // Ngôn ngữ nhận dạng, vd "vi-VN"
+	Timeout      time.Duration // Thời gian chờ tối đa cho 1 lần nhận dạng
+	PollInterval time.Duration // Chu kỳ kiểm tra kết quả nhận dạng dài
+	FakeText     string        // Nội dung trả về của transcriber giả lập
+}
+
+func GetSTTConfig() STTConfig {
+	return STTConfig{
+		Provider:     getEnvOrDefault("STT_PROVIDER", "google"),
+		LanguageCode: getEnvOrDefault("STT_LANGUAGE", "vi-VN"),
+		Timeout:      time.Duration(getEnvIntOrDefault("STT_TIMEOUT_MINUTES", 15)) * time.Minute,
+		PollInterval: time.Duration(getEnvIntOrDefault("STT_POLL_SECONDS", 5)) * time.Second,
+		FakeText:     getEnvOrDefault("STT_FAKE_TEXT", ""),
+	}
+}
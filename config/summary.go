@@ -0,0 +1,15 @@
+package config
+
+type SummaryConfig struct {
+	Mode         string // "extractive" (offline, mặc định) hoặc "llm"/"gemini" (qua LLM_PROVIDER, lỗi thì quay về extractive)
+	MaxSentences int    // Số câu tối đa trong tóm tắt
+	MaxChars     int    // Độ dài tối đa của tóm tắt (ký tự)
+}
+
+func GetSummaryConfig() SummaryConfig {
+	return SummaryConfig{
+		Mode:         getEnvOrDefault("SUMMARY_MODE", "extractive"),
+		MaxSentences: getEnvIntOrDefault("SUMMARY_MAX_SENTENCES", 5),
+		MaxChars:     getEnvIntOrDefault("SUMMARY_MAX_CHARS", 800),
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+type TTSConfig struct {
+	// "google" (mặc định), "local" (gọi lệnh cục bộ như piper/espeak) hoặc "fake" (audio im lặng, chạy offline)
+	Engine string
+	// "auto" (mặc định): gửi SSML cho giọng hỗ trợ, giọng Chirp HD nhận văn bản thuần; "on": luôn gửi SSML; "off": không dùng SSML
+	SSMLMode string
+	// Đọc số, ngày, tiền tệ, đơn vị thành chữ trước khi gửi giọng tiếng Việt (TTS_NORMALIZE=off để tắt)
+	Normalize   bool
+	Timeout     time.Duration // Thời gian tối đa cho 1 lần tổng hợp 1 chunk
+	Concurrency int           // Số chunk tổng hợp song song cho 1 tài liệu
+	MaxRetries  int           // Số lần thử lại 1 chunk khi lỗi tạm thời (quá tải, 5xx, timeout)
+	BaseBackoff time.Duration // Thời gian chờ cơ sở, nhân đôi sau mỗi lần thử lại
+	// Lưu audio từng chunk lên storage theo mã băm để không tổng hợp lại văn bản giống hệt (TTS_CACHE=off để tắt)
+	Cache bool
+	// Tài liệu nhiều ngôn ngữ: đoạn văn khác ngôn ngữ được đọc bằng giọng của ngôn ngữ đó (mặc định tắt, TTS_SWITCH_LANGUAGE=on để bật)
+	SwitchLanguage bool
+
+	GoogleCredentialsJSON string
+
+	// Lệnh của engine local, chạy qua "sh -c": văn bản được đưa vào stdin, audio đọc từ stdout.
+	// {voice} và {rate} được thay bằng giọng và tốc độ đọc, vd: espeak-ng -v {voice} --stdout | lame --quiet - -
+	LocalCommand       string
+	LocalVoices        []string // Giọng cho phép, giọng đầu tiên là mặc định
+	LocalLanguage      string   // Mã ngôn ngữ của các giọng local
+	LocalFormat        string   // Định dạng audio lệnh xuất ra: "mp3" hoặc "wav"
+	LocalMaxChunkBytes int
+}
+
+func GetTTSConfig() TTSConfig {
+	var voices []string
+	for _, v := range strings.Split(getEnvOrDefault("TTS_LOCAL_VOICES", "vi"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			voices = append(voices, v)
+		}
+	}
+
+	return TTSConfig{
+		Engine:      getEnvOrDefault("TTS_ENGINE", "google"),
+		SSMLMode:    getEnvOrDefault("TTS_SSML", "auto"),
+		Normalize:   getEnvOrDefault("TTS_NORMALIZE", "on") != "off",
+		Timeout:     time.Duration(getEnvIntOrDefault("TTS_TIMEOUT_SECONDS", 60)) * time.Second,
+		Concurrency: getEnvIntOrDefault("TTS_CONCURRENCY", 4),
+		MaxRetries:  getEnvNonNegativeIntOrDefault("TTS_MAX_RETRIES", 3),
+		BaseBackoff: time.Duration(getEnvIntOrDefault("TTS_BACKOFF_SECONDS", 1)) * time.Second,
+		Cache:       getEnvOrDefault("TTS_CACHE", "on") != "off",
+
+		SwitchLanguage: getEnvOrDefault("TTS_SWITCH_LANGUAGE", "off") == "on",
+
+		GoogleCredentialsJSON: getEnvOrDefault("GOOGLE_CREDENTIALS_JSON", ""),
+
+		LocalCommand:       getEnvOrDefault("TTS_LOCAL_COMMAND", "espeak-ng -v {voice} --stdout | lame --quiet - -"),
+		LocalVoices:        voices,
+		LocalLanguage:      getEnvOrDefault("TTS_LOCAL_LANGUAGE", "vi-VN"),
+		LocalFormat:        getEnvOrDefault("TTS_LOCAL_FORMAT", "mp3"),
+		LocalMaxChunkBytes: getEnvIntOrDefault("TTS_LOCAL_MAX_CHUNK_BYTES", 2000),
+	}
+}
@@ -0,0 +1,10 @@
+package config
+
+import "testing"
+
+func TestTTSMaxRetriesZero(t *testing.T) {
+	t.Setenv("TTS_MAX_RETRIES", "0")
+	if got := GetTTSConfig().MaxRetries; got != 0 {
+		t.Errorf("TTS_MAX_RETRIES=0 -> MaxRetries = %d, muốn 0", got)
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+type URLFetchConfig struct {
+	Timeout      time.Duration // Thời gian tối đa cho 1 lần tải trang
+	MaxBytes     int64         // Dung lượng HTML tối đa được đọc
+	MaxRedirects int           // Số lần chuyển hướng tối đa
+	AllowPrivate bool          // Cho phép tải địa chỉ nội bộ (localhost, 10.x, 192.168.x...)
+}
+
+func GetURLFetchConfig() URLFetchConfig {
+	return URLFetchConfig{
+		Timeout:      time.Duration(getEnvIntOrDefault("URL_FETCH_TIMEOUT_SECONDS", 20)) * time.Second,
+		MaxBytes:     int64(getEnvIntOrDefault("URL_FETCH_MAX_MB", 5)) * 1024 * 1024,
+		MaxRedirects: getEnvIntOrDefault("URL_FETCH_MAX_REDIRECTS", 5),
+		AllowPrivate: getEnvOrDefault("URL_FETCH_ALLOW_PRIVATE", "false") == "true",
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Huong3203/APIPodcast/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Admin đọc lại podcast với giọng/tốc độ mới từ nội dung đã làm sạch, audio cũ được giữ thành phiên bản
+func RevoicePodcast(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền đọc lại podcast"})
+		return
+	}
+
+	var input struct {
+		Voice        string  `json:"voice"`
+		SpeakingRate float64 `json:"speaking_rate"`
+		VoiceB       string  `json:"voice_b"`
+		Activate     *bool   `json:"activate"` // Mặc định true: dùng ngay khi đọc xong
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+	if input.SpeakingRate != 0 && (input.SpeakingRate < 0.25 || input.SpeakingRate > 4.0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tốc độ đọc phải từ 0.25 đến 4.0"})
+		return
+	}
+	if !checkVoiceChoices(c, input.Voice, input.VoiceB) {
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	version, job, err := services.RevoicePodcast(db, c.Param("id"), c.GetString("user_id"), services.RevoiceOptions{
+		Voice:        input.Voice,
+		SpeakingRate: input.SpeakingRate,
+		VoiceB:       input.VoiceB,
+		Activate:     input.Activate == nil || *input.Activate,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		case errors.Is(err, services.ErrNoSourceText):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrRevoiceActive), errors.Is(err, services.ErrJobActive):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể đọc lại podcast", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Đã xếp hàng đọc lại podcast",
+		"job_id":  job.ID,
+		"data":    version,
+	})
+}
+
+// Admin xem các phiên bản audio của podcast
+func GetAudioVersions(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền xem phiên bản audio"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	versions, err := services.ListAudioVersions(db, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy phiên bản audio", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": versions})
+}
+
+// Admin chọn phiên bản audio đang dùng của podcast
+func ActivateAudioVersion(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền đổi phiên bản audio"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	if err := services.ActivateAudioVersion(db, c.Param("id"), c.Param("version_id"), c.GetString("user_id")); err != nil {
+		if errors.Is(err, services.ErrAudioVersionInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể đổi phiên bản audio", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã đổi phiên bản audio"})
+}
+
+// Admin quay về phiên bản audio trước phiên bản đang dùng
+func RollbackAudioVersion(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền đổi phiên bản audio"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	version, err := services.RollbackAudioVersion(db, c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		case errors.Is(err, services.ErrNoPreviousVersion):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể quay về phiên bản trước", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã quay về phiên bản trước", "data": version})
+}
@@ -1,20 +1,17 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
+	"io"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/Huong3203/APIPodcast/config"
 	"github.com/Huong3203/APIPodcast/models"
 	"github.com/Huong3203/APIPodcast/services"
-	"github.com/Huong3203/APIPodcast/utils"
-	"github.com/Huong3203/APIPodcast/ws"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -29,6 +26,39 @@ func UploadDocument(c *gin.Context) {
 		return
 	}
 
+	rate := 1.0
+	if rateStr := c.PostForm("speaking_rate"); rateStr != "" {
+		if parsed, err := strconv.ParseFloat(rateStr, 64); err == nil && parsed > 0 {
+			rate = parsed
+		}
+	}
+	opts := services.EnqueueOptions{
+		Voice:        c.PostForm("voice"),
+		SpeakingRate: rate,
+	}
+	if !readPromptOptions(c, db, &opts) {
+		return
+	}
+
+	// Nguồn là bài viết trên web thay vì file
+	if articleURL := c.PostForm("url"); articleURL != "" {
+		if _, err := services.ValidateArticleURL(articleURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		doc, job, err := services.EnqueueURLJob(db, articleURL, userID, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo tài liệu từ URL", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":  "Đã nhận URL, đang xử lý",
+			"job_id":   job.ID,
+			"tai_lieu": doc,
+		})
+		return
+	}
+
 	file, err := c.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Không có file đính kèm"})
@@ -40,135 +70,93 @@ func UploadDocument(c *gin.Context) {
 	}
 
 	ext := filepath.Ext(file.Filename)
-	inputType, err := services.GetInputTypeFromExt(ext)
-	if err != nil {
+	if _, err := services.GetInputTypeFromExt(ext); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	id := uuid.New().String()
-	ws.SendStatusUpdate(id, "Đang tải lên tài liệu...", 0, "")
-
-	publicURL, err := utils.UploadFileToSupabase(file, id)
+	// Trích xuất, làm sạch, tóm tắt và tạo audio được worker nền xử lý
+	doc, job, err := services.EnqueueDocumentJob(db, file, userID, opts)
 	if err != nil {
-		ws.SendStatusUpdate(id, "Lỗi khi tải lên Supabase", 0, err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi upload Supabase", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải lên tài liệu", "details": err.Error()})
 		return
 	}
 
-	doc := models.TaiLieu{
-		ID:            id,
-		TenFileGoc:    file.Filename,
-		DuongDanFile:  publicURL,
-		LoaiFile:      strings.TrimPrefix(ext, "."),
-		KichThuocFile: file.Size,
-		TrangThai:     "Đã tải lên",
-		NguoiTaiLen:   userID,
-	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Đã nhận tài liệu, đang xử lý",
+		"job_id":   job.ID,
+		"tai_lieu": doc,
+	})
+}
 
-	if err := db.Create(&doc).Error; err != nil {
-		ws.SendStatusUpdate(id, "Không thể lưu tài liệu vào database", 0, err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không lưu được tài liệu", "details": err.Error()})
+// Admin xem tiến trình job xử lý tài liệu
+func GetProcessingJob(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền xem job xử lý"})
 		return
 	}
 
-	ws.SendStatusUpdate(id, "Đã tải lên", 10, "")
-	ws.BroadcastDocumentListChanged()
-
-	ws.SendStatusUpdate(id, "Đang trích xuất nội dung...", 20, "")
-	noiDung, err := services.NormalizeInput(services.InputSource{
-		Type:       inputType,
-		FileHeader: file,
-	})
-	if err != nil {
-		ws.SendStatusUpdate(id, "Lỗi khi trích xuất nội dung", 0, err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể trích xuất nội dung", "details": err.Error()})
-		return
-	}
+	db := c.MustGet("db").(*gorm.DB)
 
-	ws.SendStatusUpdate(id, "Đang làm sạch nội dung...", 30, "")
-	cleanedContent, err := services.CleanTextPipeline(noiDung)
-	if err != nil {
-		ws.SendStatusUpdate(id, "Lỗi khi làm sạch nội dung", 0, err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể làm sạch nội dung", "details": err.Error()})
+	var job models.ProcessingJob
+	if err := db.First(&job, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy job"})
 		return
 	}
 
-	fmt.Println("Nội dung đã làm sạch:", cleanedContent)
-
-	// Cập nhật nội dung trích xuất
-	db.Model(&doc).Updates(map[string]interface{}{
-		"TrangThai":        "Đã trích xuất",
-		"NoiDungTrichXuat": cleanedContent,
-	})
-	ws.SendStatusUpdate(id, "Đã trích xuất", 40, "")
-	ws.BroadcastDocumentListChanged()
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
 
-	// ⭐ Tạo tóm tắt
-	ws.SendStatusUpdate(id, "Đang tạo tóm tắt...", 45, "")
-	summary, err := services.GenerateSummary(cleanedContent)
-	if err != nil {
-		ws.SendStatusUpdate(id, "Lỗi tạo tóm tắt", 0, err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo tóm tắt", "details": err.Error()})
+// Admin xử lý lại tài liệu, bỏ qua các bước đã có kết quả (có thể buộc chạy lại 1 bước)
+func ReprocessDocument(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền xử lý lại tài liệu"})
 		return
 	}
 
-	db.Model(&doc).Updates(map[string]interface{}{
-		"TomTat": summary,
-	})
-	ws.SendStatusUpdate(id, "Đã tạo tóm tắt", 47, "")
-	ws.BroadcastDocumentListChanged()
+	db := c.MustGet("db").(*gorm.DB)
 
-	// Tạo audio
-	ws.SendStatusUpdate(id, "Đang tạo audio...", 50, "")
-	voice := c.PostForm("voice")
-	if voice == "" {
-		voice = "vi-VN-Chirp3-HD-Puck"
+	var input struct {
+		ForceStage   string  `json:"force_stage"` // extract | clean | summary | audio
+		Voice        string  `json:"voice"`
+		SpeakingRate float64 `json:"speaking_rate"`
+		DinhDang     string  `json:"format"` // narration | dialogue
+		VoiceB       string  `json:"voice_b"`
 	}
-	rate := 1.0
-	if rateStr := c.PostForm("speaking_rate"); rateStr != "" {
-		if parsed, err := strconv.ParseFloat(rateStr, 64); err == nil && parsed > 0 {
-			rate = parsed
-		}
-	}
-
-	audioData, err := services.SynthesizeText(cleanedContent, voice, rate)
-	if err != nil {
-		ws.SendStatusUpdate(id, "Lỗi khi tạo audio", 0, err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo audio", "details": err.Error()})
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
 		return
 	}
-
-	ws.SendStatusUpdate(id, "Đang lưu audio...", 60, "")
-	audioURL, err := utils.UploadBytesToSupabase(audioData, id+".mp3", "audio/mp3")
-	if err != nil {
-		ws.SendStatusUpdate(id, "Lỗi upload audio", 0, err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể upload audio", "details": err.Error()})
+	if !checkVoiceChoices(c, input.Voice, input.VoiceB) {
 		return
 	}
 
-	ws.SendStatusUpdate(id, "Đã lưu audio", 70, "")
-	now := time.Now()
-	db.Model(&doc).Updates(map[string]interface{}{
-		"TrangThai":    "Hoàn thành",
-		"NgayXuLyXong": &now,
+	job, err := services.ReprocessDocument(db, c.Param("id"), c.GetString("user_id"), services.ReprocessOptions{
+		ForceStage:   input.ForceStage,
+		Voice:        input.Voice,
+		SpeakingRate: input.SpeakingRate,
+		DinhDang:     input.DinhDang,
+		VoiceB:       input.VoiceB,
 	})
-
-	// 🔹 Tạo thông báo realtime
-	message := fmt.Sprintf("Người dùng %s đã tải lên tài liệu: %s", userID, doc.TenFileGoc)
-	if err := services.CreateNotification(userID, doc.ID, "upload_document", message); err != nil {
-		fmt.Println("Lỗi khi tạo thông báo:", err)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy tài liệu"})
+		case errors.Is(err, services.ErrInvalidStage), errors.Is(err, services.ErrInvalidFormat),
+			errors.Is(err, services.ErrTranslationFormat), errors.Is(err, services.ErrNoSourceText):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrJobActive):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xử lý lại tài liệu", "details": err.Error()})
+		}
+		return
 	}
 
-	ws.SendStatusUpdate(id, "Hoàn thành", 100, "")
-	ws.BroadcastDocumentListChanged()
-
-	db.Preload("NguoiDung").First(&doc, "id = ?", doc.ID)
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Tải lên thành công",
-		"tai_lieu":  doc,
-		"audio_url": audioURL,
-		"tom_tat":   doc.TomTat, // trả tóm tắt cho client
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Đã xếp hàng xử lý lại tài liệu",
+		"job_id":  job.ID,
+		"data":    job,
 	})
 }
 
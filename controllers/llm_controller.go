@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Admin xem số token LLM đã dùng (theo provider/model) kể từ khi server khởi động
+func GetLLMUsage(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền xem thống kê LLM"})
+		return
+	}
+
+	cfg := config.GetLLMConfig()
+	c.JSON(http.StatusOK, gin.H{
+		"provider": cfg.Provider,
+		"model":    cfg.Model,
+		"data":     services.GetLLMUsageStats(),
+	})
+}
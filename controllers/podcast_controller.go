@@ -375,7 +375,7 @@
 // 	}
 
 // 	theTag := c.PostForm("the_tag")
-// 	voice := c.DefaultPostForm("voice", "vi-VN-Chirp3-HD-Puck")
+// 	voice := c.PostForm("voice") // Rỗng = giọng mặc định của danh mục
 // 	speakingRateStr := c.DefaultPostForm("speaking_rate", "1.0")
 // 	rateValue, _ := strconv.ParseFloat(speakingRateStr, 64)
 // 	if rateValue <= 0 {
@@ -678,8 +678,10 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -712,17 +714,53 @@ func BatchUpdateVIPStatus(db *gorm.DB, podcasts []models.Podcast) {
 	}
 }
 
+// Tập trong series podcast (rút gọn)
+type SeriesEpisode struct {
+	ID            string `json:"id"`
+	TieuDe        string `json:"tieu_de"`
+	SoTap         int    `json:"so_tap"`
+	ThoiLuongGiay int    `json:"thoi_luong_giay"`
+	IsVIP         bool   `json:"is_vip"`
+}
+
 func AttachSummary(db *gorm.DB, podcasts []models.Podcast) {
 	for i := range podcasts {
+		if podcasts[i].SoTap > 0 {
+			// Tập trong series có tóm tắt riêng
+			var tap models.TapTaiLieu
+			if err := db.First(&tap, "podcast_id = ?", podcasts[i].ID).Error; err == nil && tap.TomTat != "" {
+				podcasts[i].TomTat = tap.TomTat
+				podcasts[i].CauChinh = services.DecodeKeySentences(tap.CauChinh)
+				continue
+			}
+		}
 		if podcasts[i].TailieuID != "" {
 			var tl models.TaiLieu
 			if err := db.First(&tl, "id = ?", podcasts[i].TailieuID).Error; err == nil {
 				podcasts[i].TomTat = tl.TomTat
+				podcasts[i].CauChinh = services.DecodeKeySentences(tl.CauChinh)
 			}
 		}
 	}
 }
 
+// filterPodcastLanguage lọc danh sách theo ?lang= (vi, en...), rỗng = mọi ngôn ngữ
+func filterPodcastLanguage(c *gin.Context, query *gorm.DB, column string) *gorm.DB {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(c.Query("lang"))), "-")
+	return whereLanguage(query, column, lang)
+}
+
+// whereLanguage: podcast tạo trước khi có nhận diện ngôn ngữ (cột rỗng) được coi là tiếng Việt
+func whereLanguage(query *gorm.DB, column, lang string) *gorm.DB {
+	switch lang {
+	case "":
+		return query
+	case "vi":
+		return query.Where(fmt.Sprintf("(%[1]s = ? OR %[1]s = '' OR %[1]s IS NULL)", column), lang)
+	}
+	return query.Where(column+" = ?", lang)
+}
+
 func FormatSecondsToHHMMSS(seconds int) string {
 	h := seconds / 3600
 	m := (seconds % 3600) / 60
@@ -791,6 +829,10 @@ func CreatePodcastWithUpload(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Không có file đính kèm"})
 		return
 	}
+	if _, err := services.GetInputTypeFromExt(filepath.Ext(file.Filename)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	tieuDe := c.PostForm("tieu_de")
 	danhMucID := c.PostForm("danh_muc_id")
@@ -811,80 +853,38 @@ func CreatePodcastWithUpload(c *gin.Context) {
 	}
 
 	theTag := c.PostForm("the_tag")
-	voice := c.DefaultPostForm("voice", "vi-VN-Chirp3-HD-Puck")
+	voice := c.PostForm("voice") // Rỗng = chọn theo ngôn ngữ của tài liệu
 	speakingRateStr := c.DefaultPostForm("speaking_rate", "1.0")
 	rateValue, _ := strconv.ParseFloat(speakingRateStr, 64)
 	if rateValue <= 0 {
 		rateValue = 1.0
 	}
 
-	authHeader := c.GetHeader("Authorization")
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header không hợp lệ"})
-		return
-	}
-	token := parts[1]
-
-	respData, err := services.CallUploadDocumentAPI(file, userID, token, voice, rateValue)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi gọi UploadDocument", "details": err.Error()})
-		return
-	}
-
-	taiLieuRaw, ok := respData["tai_lieu"]
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy dữ liệu tài liệu từ UploadDocument"})
-		return
-	}
-
-	taiLieuMap, ok := taiLieuRaw.(map[string]interface{})
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Dữ liệu tài liệu không đúng định dạng"})
-		return
-	}
-
-	audioURL, _ := respData["audio_url"].(string)
-	taiLieuID, _ := taiLieuMap["id"].(string)
-
-	durationFloat, _ := services.GetMP3DurationFromURL(audioURL)
-	totalSeconds := int(durationFloat)
-
-	// ✅ Tính toán VIP status ngay khi tạo
-	isVIP := false
-	if totalSeconds > 180 {
-		isVIP = true // Podcast dài > 3 phút
-	}
-	// Podcast mới luôn là VIP (trong 7 ngày)
-	isVIP = true
-
-	podcast := models.Podcast{
-		ID:             uuid.New().String(),
-		TailieuID:      taiLieuID,
+	opts := services.EnqueueOptions{
+		Voice:          voice,
+		SpeakingRate:   rateValue,
 		TieuDe:         tieuDe,
 		MoTa:           moTa,
-		DuongDanAudio:  audioURL,
-		ThoiLuongGiay:  totalSeconds,
-		HinhAnhDaiDien: hinhAnh,
 		DanhMucID:      danhMucID,
-		TrangThai:      "Tắt",
-		NguoiTao:       userID,
+		HinhAnhDaiDien: hinhAnh,
 		TheTag:         theTag,
-		LuotXem:        0,
-		IsVIP:          isVIP, // ✅ Set VIP status
 	}
-
-	if err := db.Create(&podcast).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo podcast", "details": err.Error()})
+	if !readPromptOptions(c, db, &opts) {
 		return
 	}
 
-	message := fmt.Sprintf("Admin %s đã tạo podcast: %s", userID, tieuDe)
-	services.CreateNotification(userID, podcast.ID, "create_podcast", message)
+	// Tài liệu được xử lý nền, podcast được tạo khi job hoàn tất
+	doc, job, err := services.EnqueueDocumentJob(db, file, userID, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tải lên tài liệu", "details": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Tạo podcast thành công",
-		"podcast": podcast,
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Đã nhận yêu cầu, podcast sẽ được tạo khi xử lý xong",
+		"job_id":     job.ID,
+		"podcast_id": job.PodcastID,
+		"tai_lieu":   doc,
 	})
 }
 
@@ -919,6 +919,8 @@ func GetPodcast(c *gin.Context) {
 		query = query.Where("danh_muc_id = ?", categoryID)
 	}
 
+	query = filterPodcastLanguage(c, query, "ngon_ngu")
+
 	if status != "" && role == "admin" {
 		switch status {
 		case "Bật":
@@ -1066,11 +1068,44 @@ func GetPodcastByID(c *gin.Context) {
 	// Attach summary
 	if podcast.TailieuID != "" {
 		podcast.TomTat = podcast.TaiLieu.TomTat
+		podcast.CauChinh = services.DecodeKeySentences(podcast.TaiLieu.CauChinh)
 	}
 
-	// Lấy podcast liên quan
+	// Podcast thuộc series: dùng tóm tắt riêng của tập và trả về danh sách các tập cùng tài liệu
+	series := []SeriesEpisode{}
+	if podcast.SoTap > 0 {
+		var tap models.TapTaiLieu
+		if err := db.First(&tap, "podcast_id = ?", podcast.ID).Error; err == nil && tap.TomTat != "" {
+			podcast.TomTat = tap.TomTat
+			podcast.CauChinh = services.DecodeKeySentences(tap.CauChinh)
+		}
+
+		query := db.Model(&models.Podcast{}).
+			Select("id, tieu_de, so_tap, thoi_luong_giay, is_vip")
+		if podcast.BanGocID != "" {
+			// Bản dịch của 1 tập: series gồm các bản dịch cùng ngôn ngữ của các tập trong series gốc
+			gocTaiLieu := db.Model(&models.Podcast{}).Select("tailieu_id").Where("id = ?", podcast.BanGocID)
+			gocTap := db.Model(&models.Podcast{}).Select("id").Where("tailieu_id = (?) AND so_tap > 0", gocTaiLieu)
+			query = query.Where("ban_goc_id IN (?) AND ngon_ngu = ?", gocTap, podcast.NgonNgu)
+		} else {
+			query = query.Where("tailieu_id = ? AND so_tap > 0", podcast.TailieuID)
+		}
+		if role != "admin" {
+			query = query.Where("trang_thai = ?", "Bật")
+		}
+		query.Order("so_tap").Scan(&series)
+	}
+
+	// Bản gốc + các bản dịch (người dùng chỉ thấy bản đang bật)
+	languages := services.PodcastLanguages(db, &podcast, role != "admin")
+
+	// Lấy podcast liên quan (cùng ngôn ngữ)
+	lang := podcast.NgonNgu
+	if lang == "" {
+		lang = "vi"
+	}
 	var related []models.Podcast
-	db.Preload("TaiLieu").Preload("DanhMuc").
+	whereLanguage(db.Preload("TaiLieu").Preload("DanhMuc"), "ngon_ngu", lang).
 		Where("danh_muc_id = ? AND id != ?", podcast.DanhMucID, podcast.ID).
 		Order("ngay_tao_ra DESC").Limit(5).Find(&related)
 
@@ -1079,8 +1114,10 @@ func GetPodcastByID(c *gin.Context) {
 	AttachSummary(db, related)
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":    podcast,
-		"suggest": related,
+		"data":              podcast,
+		"series":            series,
+		"ngon_ngu_kha_dung": languages,
+		"suggest":           related,
 	})
 }
 
@@ -1267,6 +1304,7 @@ func SearchPodcast(c *gin.Context) {
 	if status != "" {
 		query = query.Where("trang_thai = ?", status)
 	}
+	query = filterPodcastLanguage(c, query, "ngon_ngu")
 
 	if err := query.Find(&podcasts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tìm kiếm podcast"})
@@ -1286,7 +1324,7 @@ func GetDisabledPodcasts(c *gin.Context) {
 	db := config.DB
 	var podcasts []models.Podcast
 
-	if err := db.Where("trang_thai = ?", "Tắt").
+	if err := filterPodcastLanguage(c, db.Where("trang_thai = ?", "Tắt"), "ngon_ngu").
 		Preload("TaiLieu").Preload("DanhMuc").
 		Order("ngay_tao_ra DESC").Find(&podcasts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -1327,7 +1365,16 @@ func GetRecommendedPodcasts(c *gin.Context) {
 
 	var recommendations []PodcastWithStats
 
-	if err := db.Table("podcasts p").
+	// ?lang= rỗng thì đề xuất podcast cùng ngôn ngữ với podcast đang nghe
+	lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(c.Query("lang"))), "-")
+	if lang == "" {
+		lang = current.NgonNgu
+	}
+	if lang == "" {
+		lang = "vi"
+	}
+
+	if err := whereLanguage(db.Table("podcasts p"), "p.ngon_ngu", lang).
 		Select(`p.*, COALESCE(AVG(d.sao),0) AS avg_rating, COUNT(d.id) AS total_votes`).
 		Joins("LEFT JOIN danh_gias d ON d.podcast_id = p.id").
 		Where("p.danh_muc_id = ? AND p.id != ? AND p.trang_thai = ?", current.DanhMucID, current.ID, "Bật").
@@ -1348,7 +1395,7 @@ func GetRecommendedPodcasts(c *gin.Context) {
 	}
 
 	if len(recommendations) == 0 {
-		db.Table("podcasts p").
+		whereLanguage(db.Table("podcasts p"), "p.ngon_ngu", lang).
 			Select(`p.*, COALESCE(AVG(d.sao),0) AS avg_rating, COUNT(d.id) AS total_votes`).
 			Joins("LEFT JOIN danh_gias d ON d.podcast_id = p.id").
 			Where("p.id != ? AND p.trang_thai = ?", current.ID, "Bật").
@@ -1377,7 +1424,7 @@ func GetFeaturedPodcasts(c *gin.Context) {
 	// Lấy top 10 podcast có lượt xem cao nhất trong 30 ngày gần đây
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
 
-	if err := db.Where("trang_thai = ? AND ngay_tao_ra >= ?", "Bật", thirtyDaysAgo).
+	if err := filterPodcastLanguage(c, db.Where("trang_thai = ? AND ngay_tao_ra >= ?", "Bật", thirtyDaysAgo), "ngon_ngu").
 		Preload("TaiLieu").
 		Preload("DanhMuc").
 		Order("luot_xem DESC").
@@ -1400,3 +1447,82 @@ func GetFeaturedPodcasts(c *gin.Context) {
 		"data": podcasts,
 	})
 }
+
+// Lấy danh sách chương (mốc thời gian các phần) của podcast cho player
+func GetPodcastChapters(c *gin.Context) {
+	db := config.DB
+	var podcast models.Podcast
+	if err := db.Select("id", "thoi_luong_giay", "chuong_muc").First(&podcast, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy thông tin podcast"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"podcast_id":      podcast.ID,
+		"thoi_luong_giay": podcast.ThoiLuongGiay,
+		"data":            services.DecodeChapters(podcast.ChuongMuc),
+	})
+}
+
+// Lấy phụ đề của podcast: /transcript (JSON), /transcript/vtt (WebVTT), /transcript/srt (SubRip).
+// Phụ đề chứa toàn bộ nội dung nên podcast VIP cần tài khoản VIP như khi nghe
+func GetPodcastTranscript(c *gin.Context) {
+	db := config.DB
+	format := c.Param("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "vtt" && format != "srt" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng phụ đề không hỗ trợ (json, vtt, srt)"})
+		return
+	}
+
+	var podcast models.Podcast
+	if err := db.First(&podcast, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy thông tin podcast"})
+		}
+		return
+	}
+
+	UpdatePodcastVIPStatus(db, &podcast)
+	if role, _ := c.Get("vai_tro"); role != "admin" && podcast.IsVIP {
+		userID := c.GetString("user_id")
+		var user models.NguoiDung
+		if userID == "" || db.First(&user, "id = ?", userID).Error != nil || !IsUserVIP(&user) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":           "VIP Required",
+				"message":         "Phụ đề của podcast này chỉ dành cho thành viên VIP.",
+				"is_vip_required": true,
+				"requires_login":  userID == "",
+			})
+			return
+		}
+	}
+
+	lines := services.DecodeTranscript(podcast.PhuDe)
+	if len(lines) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Podcast chưa có phụ đề"})
+		return
+	}
+
+	switch format {
+	case "vtt":
+		c.Data(http.StatusOK, "text/vtt; charset=utf-8", []byte(services.FormatWebVTT(lines)))
+	case "srt":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.srt"`, podcast.ID))
+		c.Data(http.StatusOK, "application/x-subrip; charset=utf-8", []byte(services.FormatSRT(lines)))
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"podcast_id":      podcast.ID,
+			"thoi_luong_giay": podcast.ThoiLuongGiay,
+			"data":            lines,
+		})
+	}
+}
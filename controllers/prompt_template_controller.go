@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/Huong3203/APIPodcast/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+)
+
+// Admin xem các mẫu prompt (phiên bản hiện hành), lọc theo loại / danh mục / ngôn ngữ
+func GetPromptTemplates(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền xem mẫu prompt"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	query := db.Model(&models.MauPrompt{}).Where("hien_hanh = ?", true)
+	if loai := c.Query("loai"); loai != "" {
+		query = query.Where("loai = ?", loai)
+	}
+	if ngonNgu := c.Query("ngon_ngu"); ngonNgu != "" {
+		query = query.Where("ngon_ngu = ?", ngonNgu)
+	}
+	if danhMucID := c.Query("danh_muc_id"); danhMucID != "" {
+		query = query.Where("danh_muc_id = ?", danhMucID)
+	}
+
+	var templates []models.MauPrompt
+	if err := query.Order("loai, ma_mau").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách mẫu prompt", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": templates})
+}
+
+// Admin xem lịch sử phiên bản của 1 mẫu
+func GetPromptTemplateVersions(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền xem mẫu prompt"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	var versions []models.MauPrompt
+	db.Where("ma_mau = ?", c.Param("ma")).Order("phien_ban DESC").Find(&versions)
+	if len(versions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy mẫu prompt"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": versions})
+}
+
+// Admin tạo mẫu prompt mới (phiên bản 1)
+func CreatePromptTemplate(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền tạo mẫu prompt"})
+		return
+	}
+
+	var input struct {
+		MaMau     string `json:"ma_mau"`
+		Loai      string `json:"loai" binding:"required"` // clean | script | summary | dialogue
+		TenMau    string `json:"ten_mau" binding:"required"`
+		NgonNgu   string `json:"ngon_ngu"`
+		DanhMucID string `json:"danh_muc_id"`
+		NoiDung   string `json:"noi_dung" binding:"required"`
+		GiongVan  string `json:"giong_van"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	if input.MaMau == "" {
+		input.MaMau = slug.Make(input.TenMau)
+	}
+	var count int64
+	db.Model(&models.MauPrompt{}).Where("ma_mau = ?", input.MaMau).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Mã mẫu đã tồn tại, hãy cập nhật để tạo phiên bản mới"})
+		return
+	}
+	if !checkCategoryExists(c, db, input.DanhMucID) {
+		return
+	}
+
+	tmpl := models.MauPrompt{
+		MaMau:    input.MaMau,
+		Loai:     input.Loai,
+		TenMau:   input.TenMau,
+		NgonNgu:  input.NgonNgu,
+		NoiDung:  input.NoiDung,
+		GiongVan: input.GiongVan,
+		NguoiTao: c.GetString("user_id"),
+	}
+	if input.DanhMucID != "" {
+		tmpl.DanhMucID = &input.DanhMucID
+	}
+	if err := services.CreatePromptTemplate(db, &tmpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể tạo mẫu prompt", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Tạo mẫu prompt thành công", "data": tmpl})
+}
+
+// Admin sửa mẫu prompt: lưu thành phiên bản mới, phiên bản cũ vẫn được giữ
+func UpdatePromptTemplate(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền sửa mẫu prompt"})
+		return
+	}
+
+	var input struct {
+		TenMau    *string `json:"ten_mau"`
+		NgonNgu   *string `json:"ngon_ngu"`
+		DanhMucID *string `json:"danh_muc_id"` // "" = dùng cho mọi danh mục
+		NoiDung   *string `json:"noi_dung"`
+		GiongVan  *string `json:"giong_van"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	if input.DanhMucID != nil && !checkCategoryExists(c, db, *input.DanhMucID) {
+		return
+	}
+
+	userID := c.GetString("user_id")
+	tmpl, err := services.NewPromptTemplateVersion(db, c.Param("ma"), func(next *models.MauPrompt) {
+		if input.TenMau != nil {
+			next.TenMau = *input.TenMau
+		}
+		if input.NgonNgu != nil && *input.NgonNgu != "" {
+			next.NgonNgu = *input.NgonNgu
+		}
+		if input.DanhMucID != nil {
+			next.DanhMucID = nil
+			if *input.DanhMucID != "" {
+				next.DanhMucID = input.DanhMucID
+			}
+		}
+		if input.NoiDung != nil {
+			next.NoiDung = *input.NoiDung
+		}
+		if input.GiongVan != nil {
+			next.GiongVan = *input.GiongVan
+		}
+		next.NguoiTao = userID
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrPromptTemplateMissing) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy mẫu prompt"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể cập nhật mẫu prompt", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Đã lưu phiên bản %d", tmpl.PhienBan),
+		"data":    tmpl,
+	})
+}
+
+// Admin bật/tắt mẫu prompt (mẫu tắt không được chọn tự động theo danh mục)
+func TogglePromptTemplateStatus(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền sửa mẫu prompt"})
+		return
+	}
+
+	var body struct {
+		KichHoat bool `json:"kich_hoat"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	res := db.Model(&models.MauPrompt{}).Where("ma_mau = ?", c.Param("ma")).Update("kich_hoat", body.KichHoat)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật mẫu prompt", "details": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy mẫu prompt"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cập nhật trạng thái mẫu prompt thành công"})
+}
+
+func checkCategoryExists(c *gin.Context, db *gorm.DB, danhMucID string) bool {
+	if danhMucID == "" {
+		return true
+	}
+	var count int64
+	db.Model(&models.DanhMuc{}).Where("id = ?", danhMucID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Danh mục không tồn tại"})
+		return false
+	}
+	return true
+}
+
+// readPromptOptions đọc định dạng và mẫu prompt chọn riêng khi upload
+// (form: format, voice_b, clean_template, script_template, summary_template, tone), kiểm tra cả giọng đã chọn
+func readPromptOptions(c *gin.Context, db *gorm.DB, opts *services.EnqueueOptions) bool {
+	opts.DinhDang = c.PostForm("format")
+	opts.VoiceB = c.PostForm("voice_b")
+	opts.MauLamSach = c.PostForm("clean_template")
+	opts.MauKichBan = c.PostForm("script_template")
+	opts.MauTomTat = c.PostForm("summary_template")
+	opts.GiongVan = c.PostForm("tone")
+
+	if !services.IsValidFormat(opts.DinhDang) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidFormat.Error()})
+		return false
+	}
+	if !checkVoiceChoices(c, opts.Voice, opts.VoiceB) {
+		return false
+	}
+	// Mẫu viết nội dung phải đúng loại với định dạng đã chọn
+	scriptType := services.PromptScript
+	if opts.DinhDang == services.FormatDialogue {
+		scriptType = services.PromptDialogue
+	}
+
+	for loai, ref := range map[string]string{
+		services.PromptClean:   opts.MauLamSach,
+		scriptType:             opts.MauKichBan,
+		services.PromptSummary: opts.MauTomTat,
+	} {
+		if err := services.CheckPromptChoice(db, loai, ref); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mẫu prompt không hợp lệ", "details": err.Error()})
+			return false
+		}
+	}
+	return true
+}
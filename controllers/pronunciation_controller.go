@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/Huong3203/APIPodcast/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type pronunciationInput struct {
+	TuKhoa            string `json:"tu_khoa" binding:"required"`
+	CachDoc           string `json:"cach_doc"`
+	PhienAm           string `json:"phien_am"`
+	DanhMucID         string `json:"danh_muc_id"` // Rỗng = áp dụng cho mọi danh mục
+	PhanBietHoaThuong *bool  `json:"phan_biet_hoa_thuong"`
+	KichHoat          *bool  `json:"kich_hoat"`
+}
+
+// Admin xem từ điển phát âm (tìm theo từ khoá, lọc theo danh mục, phân trang)
+func GetPronunciations(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền xem từ điển phát âm"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 50
+	}
+
+	query := db.Model(&models.TuDienPhatAm{})
+	if search := c.Query("search"); search != "" {
+		query = query.Where("LOWER(tu_khoa) LIKE ?", "%"+strings.ToLower(search)+"%")
+	}
+	if danhMucID := c.Query("danh_muc_id"); danhMucID != "" {
+		query = query.Where("danh_muc_id = ?", danhMucID)
+	}
+
+	var total int64
+	var entries []models.TuDienPhatAm
+	query.Count(&total)
+	query.Offset((page - 1) * limit).Limit(limit).Order("tu_khoa").Find(&entries)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": entries,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": int((total + int64(limit) - 1) / int64(limit)),
+		},
+	})
+}
+
+// Admin thêm từ vào từ điển phát âm
+func CreatePronunciation(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền sửa từ điển phát âm"})
+		return
+	}
+
+	var input pronunciationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	entry := models.TuDienPhatAm{
+		ID:                uuid.New().String(),
+		PhanBietHoaThuong: true,
+		KichHoat:          true,
+		NguoiTao:          c.GetString("user_id"),
+	}
+	if !applyPronunciationInput(c, db, &entry, input) {
+		return
+	}
+
+	// Select("*") để lưu cả giá trị false (cột có default:true)
+	if err := db.Select("*").Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thêm từ", "details": err.Error()})
+		return
+	}
+	services.InvalidateLexiconCache()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Thêm từ thành công", "data": entry})
+}
+
+// Admin sửa 1 mục trong từ điển phát âm
+func UpdatePronunciation(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền sửa từ điển phát âm"})
+		return
+	}
+
+	var input pronunciationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	var entry models.TuDienPhatAm
+	if err := db.First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy từ"})
+		return
+	}
+	if !applyPronunciationInput(c, db, &entry, input) {
+		return
+	}
+
+	if err := db.Save(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật từ", "details": err.Error()})
+		return
+	}
+	services.InvalidateLexiconCache()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cập nhật thành công", "data": entry})
+}
+
+// Admin xoá 1 mục trong từ điển phát âm
+func DeletePronunciation(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền sửa từ điển phát âm"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	res := db.Delete(&models.TuDienPhatAm{}, "id = ?", c.Param("id"))
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xoá từ", "details": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy từ"})
+		return
+	}
+	services.InvalidateLexiconCache()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã xoá từ"})
+}
+
+// Admin xem trước văn bản sau khi áp dụng từ điển và chuẩn hoá (văn bản thuần và SSML)
+func PreviewPronunciation(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền xem trước"})
+		return
+	}
+
+	var input struct {
+		Text      string `json:"text" binding:"required"`
+		DanhMucID string `json:"danh_muc_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	lex := services.LoadLexicon(input.DanhMucID)
+	c.JSON(http.StatusOK, gin.H{
+		"text": services.PrepareSpeechText(input.Text, lex, "vi-VN"),
+		"ssml": services.BuildSSML(input.Text, lex, "vi-VN"),
+	})
+}
+
+func applyPronunciationInput(c *gin.Context, db *gorm.DB, entry *models.TuDienPhatAm, input pronunciationInput) bool {
+	input.TuKhoa = strings.TrimSpace(input.TuKhoa)
+	input.CachDoc = strings.TrimSpace(input.CachDoc)
+	input.PhienAm = strings.TrimSpace(input.PhienAm)
+	if input.TuKhoa == "" || (input.CachDoc == "" && input.PhienAm == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có từ khoá và cách đọc hoặc phiên âm"})
+		return false
+	}
+	if !checkCategoryExists(c, db, input.DanhMucID) {
+		return false
+	}
+
+	// Mỗi từ chỉ có 1 mục trong cùng phạm vi (chung hoặc 1 danh mục)
+	dup := db.Model(&models.TuDienPhatAm{}).Where("tu_khoa = ? AND id <> ?", input.TuKhoa, entry.ID)
+	if input.DanhMucID == "" {
+		dup = dup.Where("danh_muc_id IS NULL")
+	} else {
+		dup = dup.Where("danh_muc_id = ?", input.DanhMucID)
+	}
+	var count int64
+	dup.Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Từ này đã có trong từ điển"})
+		return false
+	}
+
+	entry.TuKhoa = input.TuKhoa
+	entry.CachDoc = input.CachDoc
+	entry.PhienAm = input.PhienAm
+	entry.DanhMucID = nil
+	if input.DanhMucID != "" {
+		danhMucID := input.DanhMucID
+		entry.DanhMucID = &danhMucID
+	}
+	if input.PhanBietHoaThuong != nil {
+		entry.PhanBietHoaThuong = *input.PhanBietHoaThuong
+	}
+	if input.KichHoat != nil {
+		entry.KichHoat = *input.KichHoat
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Huong3203/APIPodcast/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Admin tạo bản dịch của podcast sang ngôn ngữ khác (dịch nội dung bằng LLM rồi đọc bằng giọng của ngôn ngữ đó).
+// Podcast bản dịch được tạo ở trạng thái Tắt khi xử lý xong, liên kết với podcast gốc
+func TranslatePodcast(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền dịch podcast"})
+		return
+	}
+
+	var input struct {
+		NgonNgu      string  `json:"ngon_ngu" binding:"required"` // vi | en | fr | de | es
+		Voice        string  `json:"voice"`                       // Rỗng = giọng mặc định của ngôn ngữ đích
+		SpeakingRate float64 `json:"speaking_rate"`
+		VoiceB       string  `json:"voice_b"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ", "details": err.Error()})
+		return
+	}
+	if input.SpeakingRate != 0 && (input.SpeakingRate < 0.25 || input.SpeakingRate > 4.0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tốc độ đọc phải từ 0.25 đến 4.0"})
+		return
+	}
+	input.Voice, input.VoiceB = strings.TrimSpace(input.Voice), strings.TrimSpace(input.VoiceB)
+	if !checkVoiceChoices(c, input.Voice, input.VoiceB) {
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	doc, job, err := services.TranslatePodcast(db, c.Param("id"), c.GetString("user_id"), services.TranslateOptions{
+		NgonNgu:      input.NgonNgu,
+		Voice:        input.Voice,
+		VoiceB:       input.VoiceB,
+		SpeakingRate: input.SpeakingRate,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		case errors.Is(err, services.ErrUnsupportedLanguage), errors.Is(err, services.ErrSameLanguage),
+			errors.Is(err, services.ErrVoiceLanguage), errors.Is(err, services.ErrNoSourceText):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTranslationExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo bản dịch", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":     "Đã xếp hàng dịch podcast",
+		"tai_lieu_id": doc.ID,
+		"job_id":      job.ID,
+		"podcast_id":  job.PodcastID,
+		"ngon_ngu":    doc.NgonNgu,
+		"voice":       job.Voice,
+	})
+}
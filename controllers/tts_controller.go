@@ -4,8 +4,11 @@ import (
 	"encoding/base64"
 	"net/http"
 
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
 	"github.com/Huong3203/APIPodcast/services"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type TTSRequest struct {
@@ -21,16 +24,59 @@ func TextToSpeechHandler(c *gin.Context) {
 		return
 	}
 
+	if req.Voice == "" {
+		// Không chọn giọng: đọc bằng giọng của ngôn ngữ văn bản
+		req.Voice = services.DefaultVoiceFor("", services.DetectLanguage(req.Text))
+	}
 	audioContent, err := services.SynthesizeText(req.Text, req.Voice, req.SpeakingRate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	format := services.AudioMP3
+	if engine, err := services.DefaultTTSEngine(); err == nil {
+		format = engine.OutputFormat()
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":       true,
 		"voice_used":    req.Voice,
+		"audio_format":  format,
 		"audio_content": base64.StdEncoding.EncodeToString(audioContent),
 		"message":       "Text converted to speech successfully",
 	})
 }
+
+// Admin xem thống kê bộ nhớ đệm TTS: hit/miss kể từ khi server khởi động và tổng số mục đã lưu
+func GetTTSCacheStats(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền xem thống kê TTS"})
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	var stored struct {
+		Entries int64 `json:"entries"`
+		Bytes   int64 `json:"bytes"`
+		Reuses  int64 `json:"reuses"` // Tổng số lần dùng lại (kể cả trước khi server khởi động lại)
+	}
+	db.Model(&models.BoNhoDemTTS{}).
+		Select("COUNT(*) AS entries, COALESCE(SUM(kich_thuoc), 0) AS bytes, COALESCE(SUM(so_lan_dung), 0) AS reuses").
+		Scan(&stored)
+
+	stats := services.GetTTSCacheStats()
+	hitRate := 0.0
+	if total := stats.Hits + stats.Misses; total > 0 {
+		hitRate = float64(stats.Hits) / float64(total)
+	}
+
+	cfg := config.GetTTSConfig()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":  cfg.Cache,
+		"engine":   cfg.Engine,
+		"data":     stats,
+		"hit_rate": hitRate,
+		"stored":   stored,
+	})
+}
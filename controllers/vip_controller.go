@@ -85,7 +85,7 @@ func GetVIPPodcasts(c *gin.Context) {
 
 	// Lấy tất cả podcast đang bật
 	var allPodcasts []models.Podcast
-	db.Where("trang_thai = ?", "Bật").
+	filterPodcastLanguage(c, db.Where("trang_thai = ?", "Bật"), "ngon_ngu").
 		Preload("TaiLieu").
 		Preload("DanhMuc").
 		Order("ngay_tao_ra DESC").
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/Huong3203/APIPodcast/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Danh sách giọng đọc (lọc theo ngôn ngữ: ?lang=vi). Người dùng chỉ thấy giọng đang bật, admin thêm ?all=true để xem cả giọng đã tắt.
+// ?danh_muc_id= đánh dấu giọng mặc định của danh mục (theo ngôn ngữ đang lọc)
+func GetVoices(c *gin.Context) {
+	catalog, err := services.ListVoiceCatalog(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Không lấy được danh sách giọng đọc", "details": err.Error()})
+		return
+	}
+
+	role, _ := c.Get("vai_tro")
+	showAll := role == "admin" && c.Query("all") == "true"
+	lang := strings.ToLower(c.Query("lang"))
+	baseLang, _, _ := strings.Cut(lang, "-")
+	defaultVoice := services.DefaultVoiceFor(c.Query("danh_muc_id"), baseLang)
+
+	type voiceItem struct {
+		services.VoiceInfo
+		MacDinh bool `json:"mac_dinh"`
+	}
+	voices := []voiceItem{}
+	for _, v := range catalog {
+		if !v.KichHoat && !showAll {
+			continue
+		}
+		if lang != "" && !strings.HasPrefix(strings.ToLower(v.LanguageCode), lang) {
+			continue
+		}
+		voices = append(voices, voiceItem{VoiceInfo: v, MacDinh: v.Name == defaultVoice})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"engine":        config.GetTTSConfig().Engine,
+		"default_voice": defaultVoice,
+		"data":          voices,
+	})
+}
+
+// Nghe thử giọng đọc với câu mẫu (?rate=1.0), trả về file audio
+func PreviewVoice(c *gin.Context) {
+	name := c.Param("name")
+	v, ok, err := services.FindVoice(c.Request.Context(), name)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Không lấy được danh sách giọng đọc", "details": err.Error()})
+		return
+	}
+	role, _ := c.Get("vai_tro")
+	if !ok || (!v.KichHoat && role != "admin") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy giọng đọc"})
+		return
+	}
+
+	// Làm tròn tốc độ theo bước 0.05 để giới hạn số bản nghe thử cần lưu
+	rate, err := strconv.ParseFloat(c.DefaultQuery("rate", "1.0"), 64)
+	if err != nil || rate < 0.25 || rate > 4.0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tốc độ đọc phải từ 0.25 đến 4.0"})
+		return
+	}
+	rate = math.Round(rate*20) / 20
+
+	audio, format, err := services.VoicePreview(name, rate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo audio nghe thử", "details": err.Error()})
+		return
+	}
+
+	contentType := "audio/mpeg"
+	if format == services.AudioWAV {
+		contentType = "audio/wav"
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, contentType, audio)
+}
+
+// Admin bật/tắt giọng đọc hoặc đặt tên hiển thị
+func UpdateVoiceSetting(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền cấu hình giọng đọc"})
+		return
+	}
+
+	var input struct {
+		KichHoat   *bool   `json:"kich_hoat"`
+		TenHienThi *string `json:"ten_hien_thi"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || (input.KichHoat == nil && input.TenHienThi == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	v, ok, err := services.FindVoice(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Không lấy được danh sách giọng đọc", "details": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy giọng đọc"})
+		return
+	}
+
+	setting := models.CauHinhGiong{
+		Ten:          v.Name,
+		Engine:       v.Engine,
+		TenHienThi:   v.TenHienThi,
+		KichHoat:     v.KichHoat,
+		NguoiCapNhat: c.GetString("user_id"),
+	}
+	if input.KichHoat != nil {
+		setting.KichHoat = *input.KichHoat
+	}
+	if input.TenHienThi != nil {
+		setting.TenHienThi = strings.TrimSpace(*input.TenHienThi)
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	// Select("*") để lưu cả giá trị false (cột có default:true)
+	if err := db.Select("*").Clauses(clause.OnConflict{UpdateAll: true}).Create(&setting).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật giọng đọc", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cập nhật giọng đọc thành công", "data": setting})
+}
+
+// Admin đặt giọng mặc định cho danh mục (voice rỗng = dùng giọng mặc định của hệ thống)
+func SetCategoryDefaultVoice(c *gin.Context) {
+	if role, _ := c.Get("vai_tro"); role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin mới có quyền cấu hình giọng đọc"})
+		return
+	}
+
+	var input struct {
+		Voice string `json:"voice"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+	input.Voice = strings.TrimSpace(input.Voice)
+	if !checkVoiceChoices(c, input.Voice) {
+		return
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	res := db.Model(&models.DanhMuc{}).Where("id = ?", c.Param("id")).Update("giong_mac_dinh", input.Voice)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật danh mục", "details": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		db.Model(&models.DanhMuc{}).Where("id = ?", c.Param("id")).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy danh mục"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Đã cập nhật giọng mặc định của danh mục",
+		"giong_mac_dinh": input.Voice,
+		"giong_su_dung":  services.DefaultVoiceFor(c.Param("id"), services.VoiceLanguage(input.Voice)),
+	})
+}
+
+// checkVoiceChoices trả lỗi 400 nếu 1 trong các giọng đã chọn không tồn tại hoặc đã bị tắt
+func checkVoiceChoices(c *gin.Context, voices ...string) bool {
+	for _, voice := range voices {
+		if err := services.CheckVoiceChoice(voice); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Phiên bản audio của podcast: mỗi lần đọc lại với giọng/tốc độ mới tạo 1 phiên bản, admin có thể chuyển qua lại.
+// Phiên bản đang dùng là phiên bản có DuongDanAudio trùng với audio hiện tại của podcast
+type PhienBanAudio struct {
+	ID            string    `gorm:"type:char(36);primaryKey" json:"id"`
+	PodcastID     string    `gorm:"type:char(36);not null;index" json:"podcast_id"`
+	SoPhienBan    int       `gorm:"type:int" json:"so_phien_ban"` // Bắt đầu từ 1 (audio ban đầu)
+	DuongDanAudio string    `gorm:"type:text" json:"duong_dan_audio"`
+	ThoiLuongGiay int       `gorm:"type:int" json:"thoi_luong_giay"`
+	Voice         string    `gorm:"type:varchar(100)" json:"voice"`
+	VoiceB        string    `gorm:"type:varchar(100)" json:"voice_b"`
+	DinhDang      string    `gorm:"type:varchar(20)" json:"dinh_dang"`
+	SpeakingRate  float64   `json:"speaking_rate"`
+	ChuongMuc     string    `gorm:"type:longtext" json:"-"`
+	PhuDe         string    `gorm:"type:longtext" json:"-"`
+	TrangThai     string    `gorm:"type:varchar(20);default:'processing'" json:"trang_thai"` // processing | ready | failed
+	LoiXuLy       string    `gorm:"type:text" json:"loi_xu_ly"`
+	KichHoatNgay  bool      `gorm:"default:false" json:"kich_hoat_ngay"` // Dùng ngay làm audio của podcast khi đọc xong
+	NguoiTao      string    `gorm:"type:char(36)" json:"nguoi_tao"`
+	NgayTao       time.Time `gorm:"autoCreateTime" json:"ngay_tao"`
+}
@@ -3,10 +3,11 @@ package models
 import "time"
 
 type DanhMuc struct {
-	ID         string    `gorm:"type:char(36);primaryKey;default:(UUID())" json:"id"`
-	TenDanhMuc string    `gorm:"type:varchar(255)" json:"ten_danh_muc"`
-	MoTa       string    `gorm:"type:text" json:"mo_ta"`
-	Slug       string    `gorm:"type:varchar(100);uniqueIndex" json:"slug"`
-	NgayTao    time.Time `gorm:"autoCreateTime" json:"ngay_tao"`
-	KichHoat   bool      `gorm:"default:true" json:"kich_hoat"`
+	ID           string    `gorm:"type:char(36);primaryKey;default:(UUID())" json:"id"`
+	TenDanhMuc   string    `gorm:"type:varchar(255)" json:"ten_danh_muc"`
+	MoTa         string    `gorm:"type:text" json:"mo_ta"`
+	Slug         string    `gorm:"type:varchar(100);uniqueIndex" json:"slug"`
+	NgayTao      time.Time `gorm:"autoCreateTime" json:"ngay_tao"`
+	KichHoat     bool      `gorm:"default:true" json:"kich_hoat"`
+	GiongMacDinh string    `gorm:"type:varchar(100)" json:"giong_mac_dinh"` // Giọng khi upload không chọn giọng (rỗng = mặc định hệ thống)
 }
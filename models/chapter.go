@@ -0,0 +1,9 @@
+package models
+
+// Mốc chương trong audio, lưu dạng JSON ở cột ChuongMuc của tài liệu, tập và podcast
+type ChuongAudio struct {
+	ThuTu     int    `json:"thu_tu"` // Bắt đầu từ 1
+	TieuDe    string `json:"tieu_de"`
+	BatDauMs  int    `json:"bat_dau_ms"`
+	KetThucMs int    `json:"ket_thuc_ms"`
+}
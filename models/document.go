@@ -12,6 +12,15 @@ type TaiLieu struct {
 	KichThuocFile    int64      `gorm:"type:int" json:"kich_thuoc_file"`
 	NoiDungTrichXuat string     `gorm:"type:longtext" json:"noi_dung_trich_xuat"`
 	TomTat           string     `gorm:"type:longtext" json:"tom_tat"`
+	CauChinh         string     `gorm:"type:longtext" json:"-"`               // JSON []string: câu quan trọng nhất (TextRank), trả về ở podcast.cau_chinh
+	MauKichBan       string     `gorm:"type:varchar(64)" json:"mau_kich_ban"` // Phiên bản mẫu prompt đã viết NoiDungTrichXuat ("builtin" = mẫu mặc định)
+	MauTomTat        string     `gorm:"type:varchar(64)" json:"mau_tom_tat"`  // Phiên bản mẫu prompt đã tạo TomTat ("builtin", "extractive" = tóm tắt offline)
+	DuongDanAudio    string     `gorm:"type:text" json:"duong_dan_audio"`
+	ChuongMuc        string     `gorm:"type:longtext" json:"-"`           // JSON []ChuongAudio: mốc thời gian các phần trong audio
+	PhuDe            string     `gorm:"type:longtext" json:"-"`           // JSON []DongPhuDe: lời đọc kèm mốc thời gian từng câu
+	NgonNgu          string     `gorm:"type:varchar(10)" json:"ngon_ngu"` // Ngôn ngữ nhận diện từ nội dung trích xuất ("vi", "en"...), rỗng = chưa rõ
+	DichTuID         string     `gorm:"type:char(36)" json:"dich_tu_id"`  // Bản dịch: ID tài liệu gốc, nội dung được dịch thay vì trích xuất từ file
+	SoTap            int        `gorm:"type:int;default:0" json:"so_tap"` // Số tập khi tài liệu được tách thành series (0 = 1 podcast duy nhất)
 	TrangThai        string     `gorm:"type:enum('Đã tải lên', 'Đã kiểm tra', 'Đã trích xuất', 'Đã xử lý AI', 'Hoàn thành', 'Đã xuất bản')" json:"trang_thai"`
 	NguoiTaiLen      string     `gorm:"type:char(36);not null" json:"nguoi_tai_len"`
 	NgayTaiLen       time.Time  `gorm:"autoCreateTime" json:"ngay_tai_len"`
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Một tập (episode) được tách từ tài liệu dài, mỗi tập có tóm tắt + audio riêng
+type TapTaiLieu struct {
+	ID            string    `gorm:"type:char(36);primaryKey" json:"id"`
+	TaiLieuID     string    `gorm:"type:char(36);not null;uniqueIndex:idx_tap_tai_lieu" json:"tai_lieu_id"`
+	SoTap         int       `gorm:"not null;uniqueIndex:idx_tap_tai_lieu" json:"so_tap"` // Bắt đầu từ 1
+	TieuDe        string    `gorm:"type:varchar(255)" json:"tieu_de"`                    // Tiêu đề chương/mục tương ứng
+	NoiDungTho    string    `gorm:"type:longtext" json:"-"`                              // Văn bản thô của phần này
+	NoiDung       string    `gorm:"type:longtext" json:"noi_dung"`                       // Văn bản đã làm sạch, dùng để đọc
+	TomTat        string    `gorm:"type:longtext" json:"tom_tat"`
+	CauChinh      string    `gorm:"type:longtext" json:"-"`              // JSON []string: câu quan trọng nhất của tập
+	MauTomTat     string    `gorm:"type:varchar(64)" json:"mau_tom_tat"` // Phiên bản mẫu prompt đã tạo TomTat
+	DuongDanAudio string    `gorm:"type:text" json:"duong_dan_audio"`
+	ThoiLuongGiay int       `gorm:"type:int" json:"thoi_luong_giay"`
+	ChuongMuc     string    `gorm:"type:longtext" json:"-"` // JSON []ChuongAudio
+	PhuDe         string    `gorm:"type:longtext" json:"-"` // JSON []DongPhuDe
+	PodcastID     string    `gorm:"type:char(36);index" json:"podcast_id"`
+	NgayTao       time.Time `gorm:"autoCreateTime" json:"ngay_tao"`
+}
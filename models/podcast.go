@@ -19,6 +19,14 @@ type Podcast struct {
 	NgayXuatBan    *time.Time `json:"ngay_xuat_ban"`
 	TheTag         string     `gorm:"type:varchar(255)" json:"the_tag"`
 	LuotXem        int        `gorm:"type:int;default:0" json:"luot_xem"`
+	SoTap          int        `gorm:"type:int;default:0" json:"so_tap"` // Số thứ tự tập trong series của tài liệu (0 = không thuộc series)
+	ChuongMuc      string     `gorm:"type:longtext" json:"-"`           // JSON []ChuongAudio, xem GET /api/podcasts/:id/chapters
+	PhuDe          string     `gorm:"type:longtext" json:"-"`           // JSON []DongPhuDe, xem GET /api/podcasts/:id/transcript
+
+	// Ngôn ngữ của audio ("vi", "en"...), rỗng = podcast tạo trước khi có nhận diện ngôn ngữ (tiếng Việt).
+	// Bản dịch trỏ về podcast gốc qua BanGocID (rỗng = bản gốc)
+	NgonNgu  string `gorm:"type:varchar(10);index" json:"ngon_ngu"`
+	BanGocID string `gorm:"type:char(36);index" json:"ban_goc_id"`
 
 	// ⭐ Field VIP (đã fix chuẩn MySQL)
 	IsVIP bool `gorm:"column:is_vip;type:TINYINT(1);default:0" json:"is_vip"`
@@ -33,6 +41,8 @@ type Podcast struct {
 
 	// Lấy tóm tắt từ TaiLieu (không lưu vào DB)
 	TomTat string `gorm:"-" json:"tom_tat"`
+	// Câu chính của tài liệu/tập (không lưu vào DB, xem TaiLieu.CauChinh)
+	CauChinh []string `gorm:"-" json:"cau_chinh"`
 }
 
 // package models
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Job xử lý tài liệu chạy nền: trích xuất -> làm sạch -> tóm tắt -> audio
+type ProcessingJob struct {
+	ID           string     `gorm:"type:char(36);primaryKey" json:"id"`
+	TaiLieuID    string     `gorm:"type:char(36);not null;index" json:"tai_lieu_id"`
+	Status       string     `gorm:"type:enum('PENDING','RUNNING','DONE','FAILED');default:'PENDING';index:idx_job_pick" json:"status"`
+	Stage        string     `gorm:"type:varchar(30);default:'extract'" json:"stage"`
+	Attempts     int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts  int        `gorm:"default:5" json:"max_attempts"`
+	LastError    string     `gorm:"type:text" json:"last_error"`
+	NextRunAt    time.Time  `gorm:"index:idx_job_pick" json:"next_run_at"`
+	LockedAt     *time.Time `json:"locked_at"`
+	NoiDungTho   string     `gorm:"type:longtext" json:"-"` // Văn bản thô sau bước trích xuất
+	Voice        string     `gorm:"type:varchar(100)" json:"voice"`
+	VoiceB       string     `gorm:"type:varchar(100)" json:"voice_b"`                      // Giọng người dẫn thứ 2 (định dạng hội thoại)
+	DinhDang     string     `gorm:"type:varchar(20);default:'narration'" json:"dinh_dang"` // narration | dialogue
+	SpeakingRate float64    `gorm:"default:1" json:"speaking_rate"`
+	NguoiTao     string     `gorm:"type:char(36);not null" json:"nguoi_tao"`
+	Reprocess    bool       `gorm:"default:false" json:"reprocess"`      // Job xử lý lại do admin yêu cầu
+	ForceStage   string     `gorm:"type:varchar(30)" json:"force_stage"` // Bước bắt buộc chạy lại dù đã có kết quả, có thể nhiều bước ("summary,audio")
+	DaTaoAudio   bool       `gorm:"default:false" json:"da_tao_audio"`   // Job đã tạo audio mới (không bỏ qua bước audio)
+
+	// Mẫu prompt chọn khi upload (mã mẫu hoặc ID phiên bản), rỗng = theo danh mục / mặc định
+	MauLamSach string `gorm:"type:varchar(100)" json:"mau_lam_sach"`
+	MauKichBan string `gorm:"type:varchar(100)" json:"mau_kich_ban"`
+	MauTomTat  string `gorm:"type:varchar(100)" json:"mau_tom_tat"`
+	GiongVan   string `gorm:"type:varchar(255)" json:"giong_van"`
+
+	// Thông tin podcast (chỉ có khi tạo từ CreatePodcastWithUpload)
+	TieuDe         string `gorm:"type:varchar(255)" json:"tieu_de"`
+	MoTa           string `gorm:"type:text" json:"mo_ta"`
+	DanhMucID      string `gorm:"type:char(36)" json:"danh_muc_id"`
+	HinhAnhDaiDien string `gorm:"type:text" json:"hinh_anh_dai_dien"`
+	TheTag         string `gorm:"type:varchar(255)" json:"the_tag"`
+	PodcastID      string `gorm:"type:char(36)" json:"podcast_id"`
+	BanGocID       string `gorm:"type:char(36)" json:"ban_goc_id"` // Job tạo bản dịch: podcast gốc cần liên kết
+
+	// Job đọc lại podcast (PodcastID) thành phiên bản audio PhienBanAudioID, không tạo lại nội dung tài liệu
+	Revoice         bool   `gorm:"default:false" json:"revoice"`
+	PhienBanAudioID string `gorm:"type:char(36);index" json:"phien_ban_audio_id"`
+
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Mẫu prompt cho LLM do admin quản lý. Mỗi lần sửa tạo 1 phiên bản mới (cùng MaMau, PhienBan tăng dần),
+// các phiên bản cũ được giữ lại để biết tài liệu nào được tạo bằng phiên bản nào
+type MauPrompt struct {
+	ID        string    `gorm:"type:char(36);primaryKey" json:"id"`
+	MaMau     string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_mau_phien_ban" json:"ma_mau"`
+	PhienBan  int       `gorm:"not null;uniqueIndex:idx_mau_phien_ban" json:"phien_ban"`
+	HienHanh  bool      `gorm:"default:true;index" json:"hien_hanh"`         // Phiên bản mới nhất của mẫu
+	Loai      string    `gorm:"type:varchar(30);not null;index" json:"loai"` // clean | script | summary | dialogue | translate
+	TenMau    string    `gorm:"type:varchar(255)" json:"ten_mau"`
+	NgonNgu   string    `gorm:"type:varchar(10);default:'vi'" json:"ngon_ngu"`
+	DanhMucID *string   `gorm:"type:char(36);index" json:"danh_muc_id"` // nil = mẫu mặc định cho mọi danh mục
+	NoiDung   string    `gorm:"type:text;not null" json:"noi_dung"`     // text/template: {{.TieuDe}}, {{.DanhMuc}}, {{.DoDai}}, {{.GiongVan}}, {{.NgonNgu}}
+	GiongVan  string    `gorm:"type:varchar(255)" json:"giong_van"`     // Giọng văn mặc định khi upload không chỉ định
+	KichHoat  bool      `gorm:"default:true" json:"kich_hoat"`
+	NguoiTao  string    `gorm:"type:char(36)" json:"nguoi_tao"`
+	NgayTao   time.Time `gorm:"autoCreateTime" json:"ngay_tao"`
+}
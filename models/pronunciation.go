@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Từ điển phát âm: thay từ viết tắt, tên riêng, thuật ngữ tiếng Anh bằng cách đọc trước khi tổng hợp giọng nói
+type TuDienPhatAm struct {
+	ID                string    `gorm:"type:char(36);primaryKey" json:"id"`
+	TuKhoa            string    `gorm:"type:varchar(255);not null;index" json:"tu_khoa"` // Từ trong văn bản, VD "AI", "iPhone"
+	CachDoc           string    `gorm:"type:varchar(255)" json:"cach_doc"`               // Cách đọc, VD "ây ai"
+	PhienAm           string    `gorm:"type:varchar(255)" json:"phien_am"`               // Phiên âm IPA, chỉ dùng khi gửi SSML
+	DanhMucID         *string   `gorm:"type:char(36);index" json:"danh_muc_id"`          // nil = áp dụng cho mọi danh mục
+	PhanBietHoaThuong bool      `gorm:"default:true" json:"phan_biet_hoa_thuong"`        // "AI" khác "ai"
+	KichHoat          bool      `gorm:"default:true" json:"kich_hoat"`
+	NguoiTao          string    `gorm:"type:char(36)" json:"nguoi_tao"`
+	NgayTao           time.Time `gorm:"autoCreateTime" json:"ngay_tao"`
+	NgayCapNhat       time.Time `gorm:"autoUpdateTime" json:"ngay_cap_nhat"`
+}
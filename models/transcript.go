@@ -0,0 +1,9 @@
+package models
+
+// 1 dòng phụ đề (thường là 1 câu), lưu dạng JSON ở cột PhuDe của tài liệu, tập và podcast
+type DongPhuDe struct {
+	ThuTu     int    `json:"thu_tu"` // Bắt đầu từ 1
+	BatDauMs  int    `json:"bat_dau_ms"`
+	KetThucMs int    `json:"ket_thuc_ms"`
+	NoiDung   string `json:"noi_dung"`
+}
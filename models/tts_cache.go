@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Chỉ mục bộ nhớ đệm audio TTS: mỗi chunk văn bản đã tổng hợp được lưu 1 lần trên storage, khoá theo mã băm
+type BoNhoDemTTS struct {
+	MaBam       string    `gorm:"type:char(64);primaryKey" json:"ma_bam"` // sha256(engine, định dạng, giọng, ngôn ngữ, tốc độ, SSML, văn bản)
+	Engine      string    `gorm:"type:varchar(32);index" json:"engine"`
+	Giong       string    `gorm:"type:varchar(100)" json:"giong"`
+	TocDo       float64   `json:"toc_do"`
+	DuongDan    string    `gorm:"type:varchar(255)" json:"duong_dan"` // Đường dẫn object trong bucket uploads
+	KichThuoc   int       `gorm:"type:int" json:"kich_thuoc"`
+	SoLanDung   int       `gorm:"type:int;default:0" json:"so_lan_dung"` // Số lần lấy lại từ bộ nhớ đệm
+	LanDungCuoi time.Time `json:"lan_dung_cuoi"`
+	NgayTao     time.Time `gorm:"autoCreateTime" json:"ngay_tao"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Cấu hình giọng đọc do admin chỉnh (bật/tắt, tên hiển thị). Giọng chưa có bản ghi được coi là đang bật
+type CauHinhGiong struct {
+	Ten          string    `gorm:"type:varchar(100);primaryKey" json:"ten"` // Tên giọng của engine, VD "vi-VN-Chirp3-HD-Puck"
+	Engine       string    `gorm:"type:varchar(32);primaryKey" json:"engine"`
+	TenHienThi   string    `gorm:"type:varchar(255)" json:"ten_hien_thi"`
+	KichHoat     bool      `gorm:"default:true" json:"kich_hoat"`
+	NguoiCapNhat string    `gorm:"type:char(36)" json:"nguoi_cap_nhat"`
+	NgayCapNhat  time.Time `gorm:"autoUpdateTime" json:"ngay_cap_nhat"`
+}
@@ -92,9 +92,30 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		admin.GET("/vip-users", controllers.GetVIPUsers(db))
 		admin.POST("/documents/upload", controllers.UploadDocument)
 		admin.GET("/documents", controllers.ListDocumentStatus)
+		admin.POST("/documents/:id/reprocess", controllers.ReprocessDocument)
+		admin.GET("/jobs/:id", controllers.GetProcessingJob)
+		admin.GET("/llm/usage", controllers.GetLLMUsage)
+		admin.GET("/tts/cache", controllers.GetTTSCacheStats)
+		admin.PATCH("/tts/voices/:name", controllers.UpdateVoiceSetting)
+		admin.PUT("/categories/:id/voice", controllers.SetCategoryDefaultVoice)
+		admin.GET("/prompt-templates", controllers.GetPromptTemplates)
+		admin.POST("/prompt-templates", controllers.CreatePromptTemplate)
+		admin.GET("/prompt-templates/:ma/versions", controllers.GetPromptTemplateVersions)
+		admin.PUT("/prompt-templates/:ma", controllers.UpdatePromptTemplate)
+		admin.PATCH("/prompt-templates/:ma/status", controllers.TogglePromptTemplateStatus)
+		admin.GET("/pronunciations", controllers.GetPronunciations)
+		admin.POST("/pronunciations", controllers.CreatePronunciation)
+		admin.POST("/pronunciations/preview", controllers.PreviewPronunciation)
+		admin.PUT("/pronunciations/:id", controllers.UpdatePronunciation)
+		admin.DELETE("/pronunciations/:id", controllers.DeletePronunciation)
 		admin.POST("/podcasts", controllers.CreatePodcastWithUpload)
 		admin.PUT("/podcasts/:id", controllers.UpdatePodcast)
 		admin.PATCH("/podcasts/:id/toggle-vip", controllers.TogglePodcastVIPStatus)
+		admin.POST("/podcasts/:id/revoice", controllers.RevoicePodcast)
+		admin.POST("/podcasts/:id/translations", controllers.TranslatePodcast)
+		admin.GET("/podcasts/:id/audio-versions", controllers.GetAudioVersions)
+		admin.POST("/podcasts/:id/audio-versions/rollback", controllers.RollbackAudioVersion)
+		admin.POST("/podcasts/:id/audio-versions/:version_id/activate", controllers.ActivateAudioVersion)
 		admin.POST("/podcasts/sync-vip", controllers.SyncAllVIPStatus)
 		admin.GET("/stats", controllers.GetAdminStats)
 		admin.GET("/ratings/stats", controllers.GetAdminRatingsStats)
@@ -114,6 +135,13 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		}
 	}
 
+	// ---------------- TTS ----------------
+	tts := api.Group("/tts")
+	{
+		tts.GET("/voices", middleware.OptionalAuthMiddleware(), controllers.GetVoices)
+		tts.GET("/voices/:name/preview", middleware.AuthMiddleware(), controllers.PreviewVoice)
+	}
+
 	// ---------------- CATEGORY ----------------
 	category := api.Group("/categories")
 	{
@@ -145,6 +173,9 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		publicPodcast.GET("/:id/check-vip", middleware.OptionalAuthMiddleware(), controllers.CheckPodcastVIPRequirement)
 		publicPodcast.GET("/:id/ratings", controllers.GetPodcastRatings)
 		publicPodcast.GET("/:id/recommendations", controllers.GetRecommendedPodcasts)
+		publicPodcast.GET("/:id/chapters", controllers.GetPodcastChapters)
+		publicPodcast.GET("/:id/transcript", middleware.OptionalAuthMiddleware(), controllers.GetPodcastTranscript)
+		publicPodcast.GET("/:id/transcript/:format", middleware.OptionalAuthMiddleware(), controllers.GetPodcastTranscript)
 
 		// ✅ Generic :id route MUST be LAST
 		publicPodcast.GET("/:id", middleware.OptionalAuthMiddleware(), controllers.GetPodcastByID)
@@ -1,8 +1,11 @@
 package services
 
 import (
+	"bytes"
 	"io"
+	"log"
 	"net/http"
+	"time"
 
 	tcmp3 "github.com/tcolgate/mp3"
 )
@@ -16,11 +19,21 @@ func GetMP3DurationFromURL(url string) (float64, error) {
 	}
 	defer resp.Body.Close()
 
+	return getMP3Duration(resp.Body)
+}
+
+// Tính thời lượng MP3 từ dữ liệu đã có sẵn trong bộ nhớ, trả về số giây
+func GetMP3DurationFromBytes(data []byte) (float64, error) {
+	return getMP3Duration(bytes.NewReader(data))
+}
+
+func getMP3Duration(r io.Reader) (float64, error) {
 	var (
 		dur     float64
-		dec     = tcmp3.NewDecoder(resp.Body)
+		dec     = tcmp3.NewDecoder(skipID3Reader(r))
 		frame   tcmp3.Frame
 		skipped int
+		first   = true
 	)
 
 	for {
@@ -30,8 +43,44 @@ func GetMP3DurationFromURL(url string) (float64, error) {
 			}
 			return 0, err
 		}
+		// Frame Xing/Info chỉ chứa thông tin, không phải audio
+		if first {
+			first = false
+			if buf, _ := io.ReadAll(frame.Reader()); isXingFrame(buf) {
+				continue
+			}
+		}
 		dur += frame.Duration().Seconds()
 	}
 
 	return dur, nil
 }
+
+// Ảnh bìa gắn vào file MP3 tối đa 2MB
+const maxCoverImageBytes = 2 * 1024 * 1024
+
+// fetchCoverImage tải ảnh bìa để gắn vào thẻ ID3, trả về nil nếu lỗi (audio vẫn được lưu không kèm ảnh)
+func fetchCoverImage(url string) ([]byte, string) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		log.Println("Không tải được ảnh bìa:", err)
+		return nil, ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Println("Không tải được ảnh bìa, status:", resp.StatusCode)
+		return nil, ""
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverImageBytes+1))
+	if err != nil || len(data) > maxCoverImageBytes {
+		log.Println("Ảnh bìa lỗi hoặc quá lớn, bỏ qua")
+		return nil, ""
+	}
+	mime := http.DetectContentType(data)
+	if mime != "image/jpeg" && mime != "image/png" {
+		return nil, ""
+	}
+	return data, mime
+}
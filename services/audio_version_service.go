@@ -0,0 +1,388 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/Huong3203/APIPodcast/utils"
+	"github.com/Huong3203/APIPodcast/ws"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Đọc lại podcast đã xuất bản với giọng/tốc độ mới từ nội dung đã làm sạch (không trích xuất, làm sạch lại),
+// giữ các phiên bản audio cũ để chuyển qua lại hoặc quay về bản trước
+
+const (
+	AudioVersionProcessing = "processing"
+	AudioVersionReady      = "ready"
+	AudioVersionFailed     = "failed"
+)
+
+var (
+	ErrRevoiceActive       = errors.New("podcast đang được đọc lại, vui lòng chờ")
+	ErrNoSourceText        = errors.New("podcast không có nội dung văn bản để đọc lại")
+	ErrAudioVersionInvalid = errors.New("phiên bản audio không tồn tại hoặc chưa sẵn sàng")
+	ErrNoPreviousVersion   = errors.New("không có phiên bản audio trước đó để quay lại")
+)
+
+type RevoiceOptions struct {
+	Voice        string // Rỗng = giữ giọng của lần tạo audio trước
+	SpeakingRate float64
+	VoiceB       string
+	Activate     bool // Dùng ngay phiên bản mới khi đọc xong
+}
+
+// Phiên bản audio kèm cờ đang dùng (trả về cho admin)
+type AudioVersionInfo struct {
+	models.PhienBanAudio
+	DangSuDung bool `json:"dang_su_dung"`
+}
+
+// revoiceSource: nội dung và thông tin cần để đọc lại 1 podcast
+type revoiceSource struct {
+	podcast models.Podcast
+	doc     models.TaiLieu
+	ep      *models.TapTaiLieu // Podcast là 1 tập trong series
+	text    string
+	lastJob models.ProcessingJob
+}
+
+func loadRevoiceSource(db *gorm.DB, podcastID string) (*revoiceSource, error) {
+	src := &revoiceSource{}
+	if err := db.First(&src.podcast, "id = ?", podcastID).Error; err != nil {
+		return nil, err
+	}
+	if src.podcast.TailieuID == "" || db.First(&src.doc, "id = ?", src.podcast.TailieuID).Error != nil {
+		return nil, ErrNoSourceText
+	}
+	src.text = src.doc.NoiDungTrichXuat
+	// Bản dịch của 1 tập có tài liệu riêng (không tách tập): nội dung nằm ngay trong tài liệu
+	if src.podcast.SoTap > 0 && src.doc.SoTap > 0 {
+		var ep models.TapTaiLieu
+		if err := db.First(&ep, "podcast_id = ?", src.podcast.ID).Error; err != nil {
+			return nil, ErrNoSourceText
+		}
+		src.ep, src.text = &ep, ep.NoiDung
+	}
+	if strings.TrimSpace(src.text) == "" {
+		return nil, ErrNoSourceText
+	}
+	// Job đọc lại không đổi nội dung tài liệu: lấy cấu hình từ job xử lý tài liệu gần nhất
+	db.Where("tai_lieu_id = ? AND revoice = ?", src.doc.ID, false).Order("created_at desc").First(&src.lastJob)
+	return src, nil
+}
+
+// RevoicePodcast tạo phiên bản audio mới (trạng thái processing) và job đọc lại trong hàng đợi xử lý tài liệu
+func RevoicePodcast(db *gorm.DB, podcastID, userID string, opts RevoiceOptions) (*models.PhienBanAudio, *models.ProcessingJob, error) {
+	src, err := loadRevoiceSource(db, podcastID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Cấu hình đọc: chỉ đổi phần admin chỉ định, định dạng giữ theo nội dung đã viết
+	job := src.lastJob
+	job.ID = uuid.New().String()
+	job.TaiLieuID = src.doc.ID
+	job.Status = JobPending
+	job.Stage = StageRevoice
+	job.Attempts = 0
+	job.MaxAttempts = config.GetJobQueueConfig().MaxAttempts
+	job.LastError = ""
+	job.NextRunAt = time.Now()
+	job.LockedAt = nil
+	job.NoiDungTho = ""
+	job.NguoiTao = userID
+	job.Reprocess = false
+	job.ForceStage = ""
+	job.PodcastID = podcastID
+	job.Revoice = true
+	job.CreatedAt, job.UpdatedAt, job.FinishedAt = time.Time{}, time.Time{}, nil
+	if job.DinhDang == "" {
+		job.DinhDang = FormatNarration
+	}
+	if job.DanhMucID == "" {
+		job.DanhMucID = src.podcast.DanhMucID
+	}
+	if opts.Voice != "" {
+		job.Voice = opts.Voice
+	}
+	if job.Voice == "" {
+		job.Voice = DefaultVoiceFor(job.DanhMucID, DetectLanguage(src.text))
+	}
+	if opts.VoiceB != "" {
+		job.VoiceB = opts.VoiceB
+	}
+	if opts.SpeakingRate > 0 {
+		job.SpeakingRate = opts.SpeakingRate
+	}
+	if job.SpeakingRate <= 0 {
+		job.SpeakingRate = 1.0
+	}
+
+	// Audio hiện tại chưa thuộc phiên bản nào (audio ban đầu, audio sau khi xử lý lại tài liệu): lưu lại để có thể quay về
+	if err := snapshotCurrentAudio(db, &src.podcast, src.lastJob, userID); err != nil {
+		return nil, nil, err
+	}
+
+	version := models.PhienBanAudio{
+		ID:           uuid.New().String(),
+		PodcastID:    podcastID,
+		Voice:        job.Voice,
+		VoiceB:       job.VoiceB,
+		DinhDang:     job.DinhDang,
+		SpeakingRate: job.SpeakingRate,
+		TrangThai:    AudioVersionProcessing,
+		KichHoatNgay: opts.Activate,
+		NguoiTao:     userID,
+	}
+	job.PhienBanAudioID = version.ID
+
+	// Khoá dòng tài liệu để kiểm tra job đang chạy và tạo job mới trong cùng 1 giao dịch (như ReprocessDocument)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var locked models.TaiLieu
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, "id = ?", src.doc.ID).Error; err != nil {
+			return err
+		}
+		var active []models.ProcessingJob
+		if err := tx.Select("id, revoice, podcast_id").
+			Where("tai_lieu_id = ? AND status IN ?", src.doc.ID, []string{JobPending, JobRunning}).
+			Find(&active).Error; err != nil {
+			return err
+		}
+		for _, a := range active {
+			if a.Revoice && a.PodcastID == podcastID {
+				return ErrRevoiceActive
+			}
+		}
+		if len(active) > 0 {
+			return ErrJobActive
+		}
+
+		version.SoPhienBan = nextAudioVersionNumber(tx, podcastID)
+		if err := tx.Create(&version).Error; err != nil {
+			return fmt.Errorf("không thể tạo phiên bản audio: %w", err)
+		}
+		if err := tx.Create(&job).Error; err != nil {
+			return fmt.Errorf("không thể tạo job đọc lại: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ws.SendStatusUpdate(src.doc.ID, fmt.Sprintf("Đã xếp hàng đọc lại podcast (phiên bản %d)", version.SoPhienBan), 0, "")
+	wakeDocumentWorkers()
+	return &version, &job, nil
+}
+
+// runRevoiceStage đọc lại podcast của job và lưu vào phiên bản audio của job (dùng ngay nếu admin chọn)
+func runRevoiceStage(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu) error {
+	var version models.PhienBanAudio
+	if err := db.First(&version, "id = ?", job.PhienBanAudioID).Error; err != nil {
+		return fmt.Errorf("không tìm thấy phiên bản audio: %w", err)
+	}
+
+	// Lần chạy trước đã lưu xong audio nhưng lỗi khi chuyển phiên bản: không đọc lại
+	if version.TrangThai != AudioVersionReady {
+		src, err := loadRevoiceSource(db, job.PodcastID)
+		if err != nil {
+			return err
+		}
+
+		ws.SendStatusUpdate(doc.ID, fmt.Sprintf("Đang đọc lại podcast (phiên bản %d)...", version.SoPhienBan), 0, "")
+		result, err := synthesizeForJob(job, src.text, func(done, total int) {
+			ws.SendStatusUpdate(doc.ID, fmt.Sprintf("Đang đọc lại podcast (%d/%d đoạn)...", done, total), progressBetween(0, 90, done, total), "")
+		})
+		if err != nil {
+			return err
+		}
+		reportTTSCache(job, result, 90)
+
+		tags := audioTagsForJob(db, job, &src.doc, src.ep)
+		if src.ep == nil {
+			tags.Title = src.podcast.TieuDe // Tài liệu có thể dùng cho nhiều podcast: gắn đúng podcast đang đọc lại
+		}
+		tags.Chapters = result.Chapters
+		audioData := TagMP3(result.Audio, tags)
+
+		filename := fmt.Sprintf("%s-v%d-%d.mp3", src.podcast.ID, version.SoPhienBan, time.Now().Unix())
+		audioURL, err := utils.UploadBytesToSupabase(audioData, filename, "audio/mp3")
+		if err != nil {
+			return err
+		}
+		durationFloat, _ := GetMP3DurationFromBytes(audioData)
+
+		version.DuongDanAudio = audioURL
+		version.ThoiLuongGiay = int(durationFloat)
+		version.ChuongMuc = EncodeChapters(result.Chapters)
+		version.PhuDe = EncodeTranscript(result.Transcript)
+		version.TrangThai = AudioVersionReady
+		if err := db.Model(&version).Updates(map[string]interface{}{
+			"duong_dan_audio": version.DuongDanAudio,
+			"thoi_luong_giay": version.ThoiLuongGiay,
+			"chuong_muc":      version.ChuongMuc,
+			"phu_de":          version.PhuDe,
+			"trang_thai":      AudioVersionReady,
+			"loi_xu_ly":       "",
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	if version.KichHoatNgay {
+		if err := ActivateAudioVersion(db, job.PodcastID, version.ID, version.NguoiTao); err != nil {
+			return err
+		}
+	}
+	ws.SendStatusUpdate(doc.ID, fmt.Sprintf("Đã đọc lại podcast (phiên bản %d)", version.SoPhienBan), 100, "")
+	return nil
+}
+
+// ListAudioVersions trả về các phiên bản audio của podcast, mới nhất trước
+func ListAudioVersions(db *gorm.DB, podcastID string) ([]AudioVersionInfo, error) {
+	var podcast models.Podcast
+	if err := db.Select("id", "duong_dan_audio").First(&podcast, "id = ?", podcastID).Error; err != nil {
+		return nil, err
+	}
+	var versions []models.PhienBanAudio
+	if err := db.Where("podcast_id = ?", podcastID).Order("so_phien_ban desc").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	infos := make([]AudioVersionInfo, 0, len(versions))
+	for _, v := range versions {
+		infos = append(infos, AudioVersionInfo{
+			PhienBanAudio: v,
+			DangSuDung:    v.TrangThai == AudioVersionReady && v.DuongDanAudio == podcast.DuongDanAudio,
+		})
+	}
+	return infos, nil
+}
+
+// ActivateAudioVersion dùng 1 phiên bản đã sẵn sàng làm audio của podcast (kèm thời lượng, chương, phụ đề)
+func ActivateAudioVersion(db *gorm.DB, podcastID, versionID, userID string) error {
+	var version models.PhienBanAudio
+	if err := db.First(&version, "id = ? AND podcast_id = ? AND trang_thai = ?", versionID, podcastID, AudioVersionReady).Error; err != nil {
+		return ErrAudioVersionInvalid
+	}
+
+	var podcast models.Podcast
+	if err := db.First(&podcast, "id = ?", podcastID).Error; err != nil {
+		return err
+	}
+	if podcast.DuongDanAudio == version.DuongDanAudio {
+		return nil
+	}
+
+	// Audio hiện tại chưa có phiên bản (vd vừa xử lý lại tài liệu) thì lưu lại trước khi chuyển
+	var lastJob models.ProcessingJob
+	if podcast.TailieuID != "" {
+		db.Where("tai_lieu_id = ? AND revoice = ?", podcast.TailieuID, false).Order("created_at desc").First(&lastJob)
+	}
+	if err := snapshotCurrentAudio(db, &podcast, lastJob, userID); err != nil {
+		return err
+	}
+
+	return db.Model(&podcast).Updates(map[string]interface{}{
+		"duong_dan_audio": version.DuongDanAudio,
+		"thoi_luong_giay": version.ThoiLuongGiay,
+		"chuong_muc":      version.ChuongMuc,
+		"phu_de":          version.PhuDe,
+	}).Error
+}
+
+// RollbackAudioVersion quay về phiên bản sẵn sàng gần nhất trước phiên bản đang dùng
+func RollbackAudioVersion(db *gorm.DB, podcastID, userID string) (*models.PhienBanAudio, error) {
+	versions, err := ListAudioVersions(db, podcastID)
+	if err != nil {
+		return nil, err
+	}
+	current := -1
+	for i, v := range versions {
+		if v.DangSuDung {
+			current = i
+			break
+		}
+	}
+	for _, v := range versions[current+1:] {
+		if v.TrangThai == AudioVersionReady && !v.DangSuDung {
+			if err := ActivateAudioVersion(db, podcastID, v.ID, userID); err != nil {
+				return nil, err
+			}
+			return &v.PhienBanAudio, nil
+		}
+	}
+	return nil, ErrNoPreviousVersion
+}
+
+// snapshotCurrentAudio lưu audio hiện tại của podcast thành 1 phiên bản nếu chưa có
+func snapshotCurrentAudio(db *gorm.DB, podcast *models.Podcast, lastJob models.ProcessingJob, userID string) error {
+	if podcast.DuongDanAudio == "" {
+		return nil
+	}
+	var count int64
+	db.Model(&models.PhienBanAudio{}).
+		Where("podcast_id = ? AND duong_dan_audio = ?", podcast.ID, podcast.DuongDanAudio).
+		Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	// Thời lượng tính lại từ file để phiên bản cũ có số liệu đúng khi được chọn lại
+	duration := podcast.ThoiLuongGiay
+	if d, err := GetMP3DurationFromURL(podcast.DuongDanAudio); err == nil && d > 0 {
+		duration = int(d)
+	}
+	version := models.PhienBanAudio{
+		ID:            uuid.New().String(),
+		PodcastID:     podcast.ID,
+		SoPhienBan:    nextAudioVersionNumber(db, podcast.ID),
+		DuongDanAudio: podcast.DuongDanAudio,
+		ThoiLuongGiay: duration,
+		Voice:         lastJob.Voice,
+		VoiceB:        lastJob.VoiceB,
+		DinhDang:      lastJob.DinhDang,
+		SpeakingRate:  lastJob.SpeakingRate,
+		ChuongMuc:     podcast.ChuongMuc,
+		PhuDe:         podcast.PhuDe,
+		TrangThai:     AudioVersionReady,
+		NguoiTao:      userID,
+	}
+	if err := db.Create(&version).Error; err != nil {
+		return fmt.Errorf("không thể lưu phiên bản audio hiện tại: %w", err)
+	}
+	return nil
+}
+
+func nextAudioVersionNumber(db *gorm.DB, podcastID string) int {
+	var maxVersion int
+	db.Model(&models.PhienBanAudio{}).Where("podcast_id = ?", podcastID).
+		Select("COALESCE(MAX(so_phien_ban), 0)").Scan(&maxVersion)
+	return maxVersion + 1
+}
+
+// FailOrphanedRevoices đánh dấu lỗi các phiên bản processing không còn job đọc lại đang chờ/chạy
+// (lần đọc lại chạy nền trước khi chuyển sang hàng đợi job). Job bị dừng do server tắt được worker lấy lại khi khoá hết hạn
+func FailOrphanedRevoices(db *gorm.DB) {
+	active := db.Model(&models.ProcessingJob{}).Select("phien_ban_audio_id").
+		Where("revoice = ? AND status IN ?", true, []string{JobPending, JobRunning})
+	db.Model(&models.PhienBanAudio{}).Where("trang_thai = ? AND id NOT IN (?)", AudioVersionProcessing, active).
+		Updates(map[string]interface{}{"trang_thai": AudioVersionFailed, "loi_xu_ly": "Không còn job đọc lại cho phiên bản này"})
+}
+
+// failRevoiceVersion đánh dấu lỗi phiên bản audio khi job đọc lại thất bại hẳn
+func failRevoiceVersion(db *gorm.DB, job *models.ProcessingJob, jobErr error) {
+	if !job.Revoice || job.PhienBanAudioID == "" {
+		return
+	}
+	log.Printf("Đọc lại podcast %s (phiên bản %s) lỗi: %v\n", job.PodcastID, job.PhienBanAudioID, jobErr)
+	db.Model(&models.PhienBanAudio{}).Where("id = ? AND trang_thai = ?", job.PhienBanAudioID, AudioVersionProcessing).
+		Updates(map[string]interface{}{"trang_thai": AudioVersionFailed, "loi_xu_ly": jobErr.Error()})
+}
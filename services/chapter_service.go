@@ -0,0 +1,28 @@
+package services
+
+import (
+	"encoding/json"
+
+	"github.com/Huong3203/APIPodcast/models"
+)
+
+// EncodeChapters chuyển danh sách chương thành JSON để lưu vào cột ChuongMuc ("" nếu không có chương)
+func EncodeChapters(chapters []models.ChuongAudio) string {
+	if len(chapters) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(chapters)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// DecodeChapters đọc cột ChuongMuc, luôn trả về slice (không nil) để API trả về [] thay vì null
+func DecodeChapters(raw string) []models.ChuongAudio {
+	chapters := []models.ChuongAudio{}
+	if raw != "" {
+		_ = json.Unmarshal([]byte(raw), &chapters)
+	}
+	return chapters
+}
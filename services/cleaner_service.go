@@ -1,84 +1,96 @@
 package services
 
 import (
+	"context"
 	"regexp"
 	"strings"
+)
 
-	"github.com/Huong3203/APIPodcast/utils"
+var (
+	// Dòng tiêu đề mục lục đứng riêng
+	tocHeadingRe = regexp.MustCompile(`(?i)^(mục lục|table of contents|contents)[ \t]*:?$`)
+	// Mục trong mục lục: kết thúc bằng số trang, thường có dấu chấm dẫn ("Chương 1 ........ 5")
+	tocEntryRe = regexp.MustCompile(`^.{0,200}?[\p{L}\p{N}).:]([ \t]*[.…·_]{2,}[ \t]*|[ \t]+)(\d{1,4}|[ivxlc]{1,6})$`)
+	// Dòng code/thẻ HTML lẫn trong khối mục lục
+	tocCodeRe = regexp.MustCompile(`(?i)^((const|function|class|import|var|let|def|public|private)[ \t].*|<[^>]+>.*)$`)
+
+	rePageNumber   = regexp.MustCompile(`(?im)^[ \t\-–—]*((trang|page)[ \t]*)?\d+([ \t]*(/|of|trên)[ \t]*\d+)?[ \t\-–—]*$`)
+	reSpecialLines = regexp.MustCompile(`(?m)^[^\p{L}\n]*$`)
+	reMultiNewLine = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
 )
 
-// PreCleanText xử lý thô: loại mục lục, số trang, code, khoảng trắng
+// PreCleanText xử lý thô: loại khối mục lục, dòng số trang, khoảng trắng. Câu văn bình thường được giữ nguyên
+// (header/footer của PDF đã được bỏ khi trích xuất, phần còn lại do bước làm sạch bằng LLM xử lý)
 func PreCleanText(text string) string {
-	cleaned := text
-
-	// Xoá các dòng chứa "Mục lục" hoặc "Table of Contents"
-	reTOC := regexp.MustCompile(`(?i)^(.*mục lục.*|.*table of contents.*)$`)
-	cleaned = reTOC.ReplaceAllString(cleaned, "")
+	cleaned := stripTOCBlocks(text)
 
-	// Xoá các dòng chứa "Trang X" hoặc "Page X"
-	rePageNumber := regexp.MustCompile(`(?i)^.*(trang|page)[^\d]*\d+.*$`)
+	// Xoá các dòng chỉ gồm số trang ("Trang 3", "Page 3 of 10", "- 3 -"), không đụng vào câu có chữ "trang"
 	cleaned = rePageNumber.ReplaceAllString(cleaned, "")
 
 	// Xoá dòng chỉ có số, ký tự đặc biệt hoặc khoảng trắng
-	reSpecialLines := regexp.MustCompile(`^[\s\W\d]*$`)
 	cleaned = reSpecialLines.ReplaceAllString(cleaned, "")
 
-	// Xoá dòng có chứa code hoặc từ khoá lập trình
-	reCode := regexp.MustCompile(`(?i)^.*(const |function |class |<[^>]+>).*?$`)
-	cleaned = reCode.ReplaceAllString(cleaned, "")
-
-	// Xoá nhiều dòng trống liên tiếp
-	reMultiNewLine := regexp.MustCompile(`\n{2,}`)
-	cleaned = reMultiNewLine.ReplaceAllString(cleaned, "\n")
+	// Gộp nhiều dòng trống liên tiếp, giữ 1 dòng trống giữa các đoạn
+	cleaned = reMultiNewLine.ReplaceAllString(cleaned, "\n\n")
 
 	return strings.TrimSpace(cleaned)
 }
 
-// CleanWithGemini sử dụng Gemini để làm sạch sâu, chuẩn hoá văn bản
-func CleanWithGemini(text string) (string, error) {
-	prompt := `Bạn là công cụ xử lý văn bản trích xuất từ tài liệu.
-	Hãy xử lý văn bản sau với yêu cầu:
-	- Xoá phần mục lục, các dòng chứa số trang, tiêu đề lặp lại
-	- Xoá code, ví dụ mã lệnh, hoặc các ký hiệu kỹ thuật
-	- Làm gọn văn bản: không có dòng trống thừa, không có ký tự lạ
-	- Ngắt đoạn hợp lý, dễ đọc, phù hợp để chuyển thành nội dung podcast
-	- Giữ nguyên nội dung, không thêm bớt, không giải thích
-	- Không in đậm, in nghiêng, không sử dụng markdown, chỉ trả về văn bản thuần tuý
-	Văn bản cần làm sạch:`
-
-	fullPrompt := prompt + "\n\n" + text
-
-	return utils.GeminiGenerateText(fullPrompt)
+// stripTOCBlocks bỏ khối mục lục: dòng tiêu đề "Mục lục"/"Table of contents" đứng riêng cùng các dòng mục
+// (kết thúc bằng số trang) và dòng code/thẻ HTML ngay sau nó. Khối kết thúc ở dòng đầu tiên không phải mục lục
+func stripTOCBlocks(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	inTOC := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if tocHeadingRe.MatchString(trimmed) {
+			inTOC = true
+			continue
+		}
+		if inTOC {
+			if trimmed == "" || tocEntryRe.MatchString(trimmed) || tocCodeRe.MatchString(trimmed) {
+				continue
+			}
+			inTOC = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
 }
 
-func SummarizeText(text string) (string, error) {
-	prompt := `Tôi có một đoạn văn bản, bạn hãy giúp tôi tóm tắt lại nội dung một cách ngắn gọn, rõ ràng, dễ nghe khi được chuyển thành giọng nói (audio).
-	Yêu cầu:
-	1. Không lược bỏ nội dung chính, không tự ý thêm thông tin không có trong văn bản, đảm bảo đủ nội dung quan trọng
-	2. Ngôn ngữ tự nhiên, gần gũi, không quá khô khan
-	3. Có thể thêm câu chuyển đoạn ngắn để mạch lạc hơn
-	4. Không sử dụng từ ngữ chuyên môn quá khó hiểu
-	5. Giọng văn trung tính, nhẹ nhàng, phù hợp để đọc lên
-	6. Không sử dụng markdown, không in đậm, không in nghiêng, chỉ trả về văn bản thuần tuý
-	7. Không bình luận, không giải thích, chỉ trả về nội dung tóm tắt phù hợp để chuyển thành audio podcast
-	8. Có thể bắt đầu bằng câu "Chào mừng bạn đến sonify, trong tập này..." để rõ ràng hơn
-	Đoạn văn bản cần tóm tắt:`
-
-	fullPrompt := prompt + "\n\n" + text
+// CleanWithGemini sử dụng LLM (mặc định Gemini, xem LLM_PROVIDER) để làm sạch sâu, chuẩn hoá văn bản
+func CleanWithGemini(text string, pc PromptContext) (string, error) {
+	prompt, err := pc.Render(PromptClean)
+	if err != nil {
+		return "", err
+	}
+	return LLMGenerateText(context.Background(), prompt.Text, text)
+}
 
-	return utils.GeminiGenerateText(fullPrompt)
+// SummarizeText viết lại văn bản thành nội dung podcast, trả kèm phiên bản mẫu prompt đã dùng
+func SummarizeText(text string, pc PromptContext) (string, string, error) {
+	prompt, err := pc.Render(PromptScript)
+	if err != nil {
+		return "", "", err
+	}
+	script, err := LLMGenerateText(context.Background(), prompt.Text, text)
+	if err != nil {
+		return "", "", err
+	}
+	return script, prompt.Source, nil
 }
 
-// CleanTextPipeline là pipeline chính: Regex + Gemini
-func CleanTextPipeline(rawText string) (string, error) {
+// CleanTextPipeline là pipeline chính: Regex + Gemini, trả kèm phiên bản mẫu prompt đã viết nội dung.
+// format = dialogue: nội dung là kịch bản 2 người dẫn ("A: ...", "B: ...")
+func CleanTextPipeline(rawText string, pc PromptContext, format string) (string, string, error) {
 	preCleaned := PreCleanText(rawText)
-	finalCleaned, err := CleanWithGemini(preCleaned)
+	finalCleaned, err := CleanWithGemini(preCleaned, pc)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	summary, err := SummarizeText(finalCleaned)
-	if err != nil {
-		return "", err
+	if format == FormatDialogue {
+		return WriteDialogueScript(finalCleaned, pc)
 	}
-	return summary, nil
+	return SummarizeText(finalCleaned, pc)
 }
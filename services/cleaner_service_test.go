@@ -0,0 +1,63 @@
+package services
+
+import "testing"
+
+func TestPreCleanTextKeepsProse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{
+			name: "bài viết tiếng Anh",
+			text: `Introduction
+
+Public libraries have changed a lot over the last decade. Class sizes in nearby schools grew, and librarians had to adapt.
+
+Import duties on paper also rose, so many libraries moved their catalogues online. Let us look at how that happened.
+
+Private donors funded most of the early projects. Function rooms were turned into digital labs for students.
+
+Def Leppard once played a charity concert to support the programme, and the table of contents of the annual report lists every donor.
+
+Const Smith, the head librarian, says the work is far from over.`,
+		},
+		{
+			name: "bài viết tiếng Việt",
+			text: `Giới thiệu
+
+Trang web của thư viện có mục lục điện tử giúp bạn đọc tìm sách nhanh hơn.
+
+Trang 3 của báo cáo nói rằng số lượt mượn sách tăng 20% trong năm qua.`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PreCleanText(tt.text); got != tt.text {
+				t.Errorf("PreCleanText làm thay đổi văn bản thường:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestPreCleanTextStripsTOCAndPageNumbers(t *testing.T) {
+	text := `MỤC LỤC
+Chương 1. Mở đầu ........ 3
+Chương 2. Lịch sử ....... 10
+<div class="toc"></div>
+
+Chương 1. Mở đầu
+
+Nội dung chương một.
+Trang 3
+- 4 -
+Page 5 of 10
+Nội dung tiếp theo.`
+	want := `Chương 1. Mở đầu
+
+Nội dung chương một.
+
+Nội dung tiếp theo.`
+	if got := PreCleanText(text); got != want {
+		t.Errorf("PreCleanText =\n%s\nmuốn\n%s", got, want)
+	}
+}
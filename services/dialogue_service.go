@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+)
+
+// Định dạng nội dung podcast
+const (
+	FormatNarration = "narration" // 1 người đọc (mặc định)
+	FormatDialogue  = "dialogue"  // 2 người dẫn trò chuyện
+)
+
+// Người dẫn trong kịch bản hội thoại
+const (
+	SpeakerA = "A" // Người dẫn chính, dùng giọng của job
+	SpeakerB = "B" // Người dẫn phụ
+)
+
+// Giọng mặc định của người dẫn phụ (khác giọng người dẫn chính)
+const (
+	defaultDialogueVoiceB   = "vi-VN-Chirp3-HD-Aoede"
+	alternateDialogueVoiceB = "vi-VN-Chirp3-HD-Charon"
+)
+
+var ErrInvalidDialogueScript = errors.New("kịch bản hội thoại không hợp lệ: cần các lượt nói của cả người dẫn A và B")
+
+// Một lượt nói trong kịch bản hội thoại
+type DialogueTurn struct {
+	Speaker string `json:"speaker"`
+	Text    string `json:"text"`
+}
+
+// Nhãn người nói ở đầu dòng: "A:", "**B:**", "Host A:", "Người dẫn 2 -"
+var dialogueLabelRe = regexp.MustCompile(`(?i)^[\s*_]*(?:(?:host|người dẫn|mc)\s*)?([ab12])[\s*_]*[:：\-–][\s*_]*(.*)$`)
+
+func IsValidFormat(format string) bool {
+	return format == "" || format == FormatNarration || format == FormatDialogue
+}
+
+// ParseDialogueScript tách kịch bản thành các lượt nói; dòng không có nhãn được nối vào lượt trước
+func ParseDialogueScript(script string) []DialogueTurn {
+	var turns []DialogueTurn
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		speaker, text := "", line
+		if m := dialogueLabelRe.FindStringSubmatch(line); m != nil {
+			speaker, text = SpeakerA, strings.TrimSpace(m[2])
+			if l := strings.ToUpper(m[1]); l == "B" || l == "2" {
+				speaker = SpeakerB
+			}
+		}
+		text = strings.Trim(text, "*_ ")
+		if text == "" {
+			continue
+		}
+
+		switch {
+		case len(turns) == 0:
+			if speaker == "" {
+				// Lời mở đầu không có nhãn: giao cho người dẫn chính
+				speaker = SpeakerA
+			}
+			turns = append(turns, DialogueTurn{Speaker: speaker, Text: text})
+		case speaker == "" || speaker == turns[len(turns)-1].Speaker:
+			turns[len(turns)-1].Text += " " + text
+		default:
+			turns = append(turns, DialogueTurn{Speaker: speaker, Text: text})
+		}
+	}
+	return turns
+}
+
+// FormatDialogueScript ghi kịch bản ở dạng chuẩn "A: ...", mỗi lượt 1 đoạn
+func FormatDialogueScript(turns []DialogueTurn) string {
+	lines := make([]string, 0, len(turns))
+	for _, t := range turns {
+		lines = append(lines, t.Speaker+": "+t.Text)
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+// DialoguePlainText bỏ nhãn người nói, dùng cho tóm tắt
+func DialoguePlainText(script string) string {
+	turns := ParseDialogueScript(script)
+	texts := make([]string, 0, len(turns))
+	for _, t := range turns {
+		texts = append(texts, t.Text)
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// scriptPlainText trả về văn bản không nhãn theo định dạng của job
+func scriptPlainText(text, format string) string {
+	if format == FormatDialogue {
+		return DialoguePlainText(text)
+	}
+	return text
+}
+
+// WriteDialogueScript nhờ LLM viết văn bản đã làm sạch thành kịch bản 2 người dẫn
+func WriteDialogueScript(text string, pc PromptContext) (string, string, error) {
+	prompt, err := pc.Render(PromptDialogue)
+	if err != nil {
+		return "", "", err
+	}
+	script, err := LLMGenerateText(context.Background(), prompt.Text, text)
+	if err != nil {
+		return "", "", err
+	}
+
+	turns := ParseDialogueScript(script)
+	speakers := map[string]bool{}
+	for _, t := range turns {
+		speakers[t.Speaker] = true
+	}
+	if !speakers[SpeakerA] || !speakers[SpeakerB] {
+		return "", "", ErrInvalidDialogueScript
+	}
+	return FormatDialogueScript(turns), prompt.Source, nil
+}
+
+// DialogueVoices chọn giọng cho 2 người dẫn, đảm bảo 2 giọng khác nhau.
+// Người dẫn chính đọc ngôn ngữ khác tiếng Việt thì giọng mặc định của người dẫn phụ lấy cùng dòng giọng ở ngôn ngữ đó
+func DialogueVoices(voiceA, voiceB string) (string, string) {
+	if voiceA == "" {
+		voiceA = defaultVoice
+	}
+	defaultB, alternateB := defaultDialogueVoiceB, alternateDialogueVoiceB
+	if lang := VoiceLanguage(voiceA); lang != VoiceLanguage(defaultDialogueVoiceB) {
+		if v := LanguageVoice(lang, defaultDialogueVoiceB); v != "" {
+			defaultB = v
+		}
+		if v := LanguageVoice(lang, alternateDialogueVoiceB); v != "" {
+			alternateB = v
+		}
+	}
+	if voiceB == "" {
+		voiceB = defaultB
+	}
+	if voiceB == voiceA {
+		voiceB = alternateB
+		if voiceB == voiceA {
+			voiceB = defaultB
+		}
+	}
+	return voiceA, voiceB
+}
+
+// DialogueSegments gán giọng cho từng lượt nói
+func DialogueSegments(script, voiceA, voiceB string) []SpeechSegment {
+	voiceA, voiceB = DialogueVoices(voiceA, voiceB)
+	var segments []SpeechSegment
+	for _, t := range ParseDialogueScript(script) {
+		voice := voiceA
+		if t.Speaker == SpeakerB {
+			voice = voiceB
+		}
+		segments = append(segments, SpeechSegment{Text: t.Text, Voice: voice})
+	}
+	return segments
+}
+
+// synthesizeForJob tạo audio theo định dạng của job: hội thoại đọc từng lượt bằng giọng riêng
+// (progress nhận số chunk đã xong / tổng số chunk), kèm mốc chương nếu văn bản có tiêu đề phần/chương
+func synthesizeForJob(job *models.ProcessingJob, text string, progress func(done, total int)) (*SynthesisResult, error) {
+	// Podcast được lưu và tính thời lượng dưới dạng MP3
+	if engine, err := DefaultTTSEngine(); err != nil {
+		return nil, err
+	} else if engine.OutputFormat() != AudioMP3 {
+		return nil, fmt.Errorf("TTS engine %s xuất %s, podcast cần MP3", engine.Name(), engine.OutputFormat())
+	}
+	opts := SynthesisOptions{
+		SpeakingRate:   job.SpeakingRate,
+		DanhMucID:      job.DanhMucID,
+		Progress:       progress,
+		SwitchLanguage: config.GetTTSConfig().SwitchLanguage,
+	}
+	if job.DinhDang == FormatDialogue {
+		segments := DialogueSegments(text, job.Voice, job.VoiceB)
+		if len(segments) == 0 {
+			return nil, ErrInvalidDialogueScript
+		}
+		return SynthesizeSpeech(segments, opts)
+	}
+	if len(text) == 0 {
+		return nil, errors.New("text is empty")
+	}
+	return SynthesizeSpeech([]SpeechSegment{{Text: text, Voice: job.Voice}}, opts)
+}
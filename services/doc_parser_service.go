@@ -4,58 +4,108 @@ import (
 	"archive/zip"
 	"bytes"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
-	"os"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 func ExtractTextFromDOCX(fileHeader *multipart.FileHeader) (string, error) {
-	// Tạo file tạm
-	tmpFile, err := os.CreateTemp("", "upload-*.docx")
+	src, err := fileHeader.Open()
 	if err != nil {
 		return "", err
 	}
-	defer os.Remove(tmpFile.Name())
-	defer tmpFile.Close()
+	defer src.Close()
 
-	// Lưu nội dung file vào file tạm
-	src, err := fileHeader.Open()
+	data, err := io.ReadAll(src)
 	if err != nil {
 		return "", err
 	}
-	defer src.Close()
-	if _, err := io.Copy(tmpFile, src); err != nil {
+	return ExtractTextFromDOCXBytes(data)
+}
+
+// ExtractTextFromDOCXBytes trích xuất văn bản từ nội dung .docx đã đọc sẵn, giữ ranh giới đoạn văn
+func ExtractTextFromDOCXBytes(data []byte) (string, error) {
+	doc, err := ParseDOCXStructured(data)
+	if err != nil {
 		return "", err
 	}
+	return doc.PlainText(), nil
+}
+
+// Thông tin style trong word/styles.xml cần để nhận diện tiêu đề
+type docxStyle struct {
+	ID   string `xml:"styleId,attr"`
+	Name struct {
+		Val string `xml:"val,attr"`
+	} `xml:"name"`
+	BasedOn struct {
+		Val string `xml:"val,attr"`
+	} `xml:"basedOn"`
+	OutlineLvl *struct {
+		Val string `xml:"val,attr"`
+	} `xml:"pPr>outlineLvl"`
+}
 
+type docxStyles struct {
+	Styles []docxStyle `xml:"style"`
+}
+
+var docxHeadingNameRe = regexp.MustCompile(`(?i)^(heading|tiêu đề|titre|überschrift)\s*(\d)$`)
+
+// Trạng thái của đoạn <w:p> đang đọc
+type docxParagraph struct {
+	style      string
+	outlineLvl int // -1 = không khai báo
+	isList     bool
+	listLevel  int
+	text       strings.Builder
+}
+
+// Trạng thái của bảng <w:tbl> đang đọc (có thể lồng nhau)
+type docxTable struct {
+	rows [][]string
+	cell []string
+}
+
+// ParseDOCXStructured đọc word/document.xml theo thứ tự: tiêu đề (theo style), đoạn văn, mục liệt kê, bảng
+func ParseDOCXStructured(data []byte) (*StructuredDocument, error) {
 	// Mở file zip (.docx là file zip!)
-	r, err := zip.OpenReader(tmpFile.Name())
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer r.Close()
 
-	// Tìm file document.xml
-	var docFile *zip.File
+	var docFile, stylesFile *zip.File
 	for _, f := range r.File {
-		if f.Name == "word/document.xml" {
+		switch f.Name {
+		case "word/document.xml":
 			docFile = f
-			break
+		case "word/styles.xml":
+			stylesFile = f
 		}
 	}
 	if docFile == nil {
-		return "", err
+		return nil, errors.New("không tìm thấy word/document.xml trong file docx")
 	}
 
+	headingLevels := readDOCXHeadingStyles(stylesFile)
+
 	rc, err := docFile.Open()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer rc.Close()
 
-	// Đọc XML & trích xuất <w:t> tag (văn bản)
-	var buf bytes.Buffer
+	doc := &StructuredDocument{}
+	var para *docxParagraph
+	var outer []*docxParagraph // Đoạn bao ngoài khi gặp <w:p> lồng (text box)
+	var tables []*docxTable
+	inRun := 0
+
 	decoder := xml.NewDecoder(rc)
 	for {
 		tok, err := decoder.Token()
@@ -63,18 +113,222 @@ func ExtractTextFromDOCX(fileHeader *multipart.FileHeader) (string, error) {
 			break
 		}
 		if err != nil {
-			return "", err
+			return nil, fmt.Errorf("không đọc được document.xml: %w", err)
 		}
+
 		switch se := tok.(type) {
 		case xml.StartElement:
-			if se.Name.Local == "t" { // <w:t>
+			// Chỉ đọc phần tử WordprocessingML: bỏ chữ của DrawingML (a:p, a:t trong biểu đồ, SmartArt) và công thức (m:t)
+			if !isWordprocessingML(se.Name.Space) {
+				continue
+			}
+			switch se.Name.Local {
+			case "p":
+				if para != nil {
+					outer = append(outer, para)
+				}
+				para = &docxParagraph{outlineLvl: -1}
+			case "pStyle":
+				if para != nil {
+					para.style = docxVal(se)
+				}
+			case "outlineLvl":
+				if para != nil {
+					if lvl, err := strconv.Atoi(docxVal(se)); err == nil {
+						para.outlineLvl = lvl
+					}
+				}
+			case "numPr":
+				if para != nil {
+					para.isList = true
+				}
+			case "ilvl":
+				if para != nil {
+					para.listLevel, _ = strconv.Atoi(docxVal(se))
+				}
+			case "r":
+				inRun++
+			case "t": // <w:t>
 				var text string
-				if err := decoder.DecodeElement(&text, &se); err == nil {
-					buf.WriteString(text + " ")
+				if err := decoder.DecodeElement(&text, &se); err == nil && para != nil {
+					para.text.WriteString(text)
 				}
+			case "tab", "br", "cr":
+				// Chỉ tính tab/xuống dòng trong run, bỏ qua định nghĩa tab trong pPr
+				if inRun > 0 && para != nil {
+					para.text.WriteString(" ")
+				}
+			case "tbl":
+				tables = append(tables, &docxTable{})
+			case "tr":
+				if len(tables) > 0 {
+					t := tables[len(tables)-1]
+					t.rows = append(t.rows, nil)
+				}
+			case "tc":
+				if len(tables) > 0 {
+					tables[len(tables)-1].cell = nil
+				}
+			}
+
+		case xml.EndElement:
+			if !isWordprocessingML(se.Name.Space) {
+				continue
 			}
+			switch se.Name.Local {
+			case "r":
+				inRun--
+			case "p":
+				if para == nil {
+					continue
+				}
+				text := normalizeSpaces(para.text.String())
+				if len(tables) > 0 {
+					t := tables[len(tables)-1]
+					if text != "" {
+						t.cell = append(t.cell, text)
+					}
+				} else {
+					emitDOCXParagraph(doc, para, text, headingLevels)
+				}
+				para = nil
+				if len(outer) > 0 {
+					para = outer[len(outer)-1]
+					outer = outer[:len(outer)-1]
+				}
+			case "tc":
+				if len(tables) > 0 {
+					t := tables[len(tables)-1]
+					if len(t.rows) == 0 {
+						t.rows = append(t.rows, nil)
+					}
+					last := len(t.rows) - 1
+					t.rows[last] = append(t.rows[last], strings.Join(t.cell, " "))
+					t.cell = nil
+				}
+			case "tbl":
+				if len(tables) == 0 {
+					continue
+				}
+				t := tables[len(tables)-1]
+				tables = tables[:len(tables)-1]
+				sentences := renderTableSentences(t.rows)
+				if len(tables) > 0 {
+					// Bảng lồng: gộp vào ô của bảng cha
+					parent := tables[len(tables)-1]
+					parent.cell = append(parent.cell, sentences...)
+				} else if len(sentences) > 0 {
+					doc.AddBlock(DocBlock{Kind: BlockTable, Text: strings.Join(sentences, " ")})
+				}
+			}
+		}
+	}
+
+	if len(doc.Sections) == 0 {
+		return nil, errors.New("file docx không có nội dung văn bản")
+	}
+	return doc, nil
+}
+
+func emitDOCXParagraph(doc *StructuredDocument, para *docxParagraph, text string, headingLevels map[string]int) {
+	if text == "" {
+		return
+	}
+
+	level := 0
+	if para.outlineLvl >= 0 && para.outlineLvl < 9 {
+		level = para.outlineLvl + 1
+	} else if lvl, ok := headingLevels[para.style]; ok {
+		level = lvl
+	}
+
+	switch {
+	case level > 0:
+		doc.AddHeading(text, level)
+	case para.isList:
+		doc.AddBlock(DocBlock{Kind: BlockListItem, Level: para.listLevel, Text: text})
+	default:
+		doc.AddBlock(DocBlock{Kind: BlockParagraph, Text: text})
+	}
+}
+
+// readDOCXHeadingStyles trả về styleId -> cấp tiêu đề, lần theo basedOn khi style kế thừa từ Heading
+// (f == nil: file không có styles.xml, chỉ dùng các styleId chuẩn)
+func readDOCXHeadingStyles(f *zip.File) map[string]int {
+	levels := map[string]int{}
+	var styles docxStyles
+	if f != nil {
+		if rc, err := f.Open(); err == nil {
+			xml.NewDecoder(rc).Decode(&styles)
+			rc.Close()
 		}
 	}
 
-	return strings.TrimSpace(buf.String()), nil
+	byID := make(map[string]docxStyle, len(styles.Styles))
+	for _, s := range styles.Styles {
+		byID[s.ID] = s
+	}
+
+	ownLevel := func(s docxStyle) int {
+		name := strings.TrimSpace(s.Name.Val)
+		if strings.EqualFold(name, "title") {
+			return 1
+		}
+		if m := docxHeadingNameRe.FindStringSubmatch(name); m != nil {
+			lvl, _ := strconv.Atoi(m[2])
+			return lvl
+		}
+		if s.OutlineLvl != nil {
+			if lvl, err := strconv.Atoi(s.OutlineLvl.Val); err == nil && lvl < 9 {
+				return lvl + 1
+			}
+		}
+		return 0
+	}
+
+	for id, s := range byID {
+		cur := s
+		for depth := 0; depth < 10; depth++ {
+			if lvl := ownLevel(cur); lvl > 0 {
+				levels[id] = lvl
+				break
+			}
+			parent, ok := byID[cur.BasedOn.Val]
+			if !ok {
+				break
+			}
+			cur = parent
+		}
+	}
+
+	// Tài liệu không có styles.xml chuẩn vẫn thường dùng styleId "Heading1", "Title"
+	for i := 1; i <= 9; i++ {
+		id := "Heading" + strconv.Itoa(i)
+		if _, ok := levels[id]; !ok {
+			levels[id] = i
+		}
+	}
+	if _, ok := levels["Title"]; !ok {
+		levels["Title"] = 1
+	}
+	return levels
+}
+
+// Namespace WordprocessingML (bản Transitional mà Word thường lưu và bản Strict)
+var wordprocessingMLNamespaces = map[string]bool{
+	"http://schemas.openxmlformats.org/wordprocessingml/2006/main": true,
+	"http://purl.oclc.org/ooxml/wordprocessingml/main":             true,
+}
+
+func isWordprocessingML(space string) bool {
+	return wordprocessingMLNamespaces[space]
+}
+
+func docxVal(se xml.StartElement) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == "val" {
+			return a.Value
+		}
+	}
+	return ""
 }
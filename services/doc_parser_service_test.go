@@ -0,0 +1,54 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// buildTestDOCX tạo file .docx tối thiểu chỉ có word/document.xml
+func buildTestDOCX(t *testing.T, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+ xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"
+ xmlns:m="http://schemas.openxmlformats.org/officeDocument/2006/math"><w:body>` + body + `</w:body></w:document>`))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseDOCXStructuredIgnoresDrawingAndMath(t *testing.T) {
+	data := buildTestDOCX(t, `
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Chương 1</w:t></w:r></w:p>
+<w:p><w:r><w:t>Đoạn văn bình thường.</w:t></w:r></w:p>
+<w:p><w:r><w:drawing><a:graphic><a:graphicData><a:p><a:r><a:t>Nhãn biểu đồ</a:t></a:r></a:p></a:graphicData></a:graphic></w:drawing></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Công thức </w:t></w:r><m:oMath><m:r><m:t>x=1</m:t></m:r></m:oMath></w:p>`)
+
+	doc, err := ParseDOCXStructured(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := doc.PlainText()
+	for _, want := range []string{"Chương 1", "Đoạn văn bình thường.", "Công thức"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("thiếu %q trong %q", want, text)
+		}
+	}
+	for _, leak := range []string{"Nhãn biểu đồ", "x=1"} {
+		if strings.Contains(text, leak) {
+			t.Errorf("chữ %q không thuộc WordprocessingML bị lấy vào: %q", leak, text)
+		}
+	}
+	if len(doc.Sections) == 0 || doc.Sections[0].Title != "Chương 1" {
+		t.Errorf("tiêu đề phần đầu = %+v", doc.Sections)
+	}
+}
@@ -0,0 +1,686 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/Huong3203/APIPodcast/utils"
+	"github.com/Huong3203/APIPodcast/ws"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Trạng thái job
+const (
+	JobPending = "PENDING"
+	JobRunning = "RUNNING"
+	JobDone    = "DONE"
+	JobFailed  = "FAILED"
+)
+
+// Các bước xử lý, chạy theo đúng thứ tự
+const (
+	StageExtract  = "extract"
+	StageClean    = "clean"
+	StageSummary  = "summary"
+	StageAudio    = "audio"
+	StageFinalize = "finalize"
+	StageDone     = "done"
+
+	// Bước đầu của job tạo bản dịch, thay cho trích xuất + làm sạch (xem TranslatePodcast)
+	StageTranslate = "translate"
+	// Bước duy nhất của job đọc lại podcast thành phiên bản audio mới (xem RevoicePodcast)
+	StageRevoice = "revoice"
+)
+
+var jobStages = []string{StageExtract, StageClean, StageSummary, StageAudio, StageFinalize, StageDone}
+
+// Thông báo lỗi hiển thị qua WebSocket theo từng bước
+var stageErrorMessages = map[string]string{
+	StageExtract:  "Lỗi khi trích xuất nội dung",
+	StageClean:    "Lỗi khi làm sạch nội dung",
+	StageSummary:  "Lỗi tạo tóm tắt",
+	StageAudio:    "Lỗi khi tạo audio",
+	StageFinalize: "Lỗi khi hoàn tất xử lý",
+
+	StageTranslate: "Lỗi khi dịch nội dung",
+	StageRevoice:   "Lỗi khi đọc lại podcast",
+}
+
+// Tiến độ (%) khi bắt đầu mỗi bước
+var stageProgress = map[string]float64{
+	StageExtract:  20,
+	StageClean:    30,
+	StageSummary:  45,
+	StageAudio:    50,
+	StageFinalize: 70,
+
+	StageTranslate: 30,
+}
+
+// Đánh thức worker ngay khi có job mới thay vì chờ chu kỳ quét
+var jobWakeup = make(chan struct{}, 1)
+
+// Tuỳ chọn khi đưa tài liệu vào hàng đợi
+type EnqueueOptions struct {
+	Voice        string // Rỗng = chọn theo ngôn ngữ nhận diện được khi trích xuất
+	SpeakingRate float64
+
+	// Nếu có TieuDe + DanhMucID thì worker tạo podcast khi xử lý xong
+	TieuDe         string
+	MoTa           string
+	DanhMucID      string
+	HinhAnhDaiDien string
+	TheTag         string
+
+	// Định dạng nội dung (narration | dialogue), VoiceB là giọng người dẫn thứ 2 khi hội thoại
+	DinhDang string
+	VoiceB   string
+
+	// Mẫu prompt chọn riêng cho lần upload này (mã mẫu hoặc ID phiên bản)
+	MauLamSach string
+	MauKichBan string
+	MauTomTat  string
+	GiongVan   string
+}
+
+// EnqueueDocumentJob tải file lên Supabase, tạo TaiLieu và job xử lý nền
+func EnqueueDocumentJob(db *gorm.DB, file *multipart.FileHeader, userID string, opts EnqueueOptions) (*models.TaiLieu, *models.ProcessingJob, error) {
+	id := uuid.New().String()
+	ws.SendStatusUpdate(id, "Đang tải lên tài liệu...", 0, "")
+
+	publicURL, err := utils.UploadFileToSupabase(file, id)
+	if err != nil {
+		ws.SendStatusUpdate(id, "Lỗi khi tải lên Supabase", 0, err.Error())
+		return nil, nil, fmt.Errorf("lỗi upload Supabase: %w", err)
+	}
+
+	doc := models.TaiLieu{
+		ID:            id,
+		TenFileGoc:    file.Filename,
+		DuongDanFile:  publicURL,
+		LoaiFile:      strings.TrimPrefix(filepath.Ext(file.Filename), "."),
+		KichThuocFile: file.Size,
+		TrangThai:     "Đã tải lên",
+		NguoiTaiLen:   userID,
+	}
+	return enqueueJob(db, doc, userID, opts)
+}
+
+// EnqueueURLJob tạo TaiLieu từ URL bài viết, nội dung được tải ở bước trích xuất
+func EnqueueURLJob(db *gorm.DB, rawURL, userID string, opts EnqueueOptions) (*models.TaiLieu, *models.ProcessingJob, error) {
+	u, err := ValidateArticleURL(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := u.String()
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	doc := models.TaiLieu{
+		ID:           uuid.New().String(),
+		TenFileGoc:   name,
+		DuongDanFile: u.String(),
+		LoaiFile:     string(InputURL),
+		TrangThai:    "Đã tải lên",
+		NguoiTaiLen:  userID,
+	}
+	return enqueueJob(db, doc, userID, opts)
+}
+
+func enqueueJob(db *gorm.DB, doc models.TaiLieu, userID string, opts EnqueueOptions) (*models.TaiLieu, *models.ProcessingJob, error) {
+	if opts.SpeakingRate <= 0 {
+		opts.SpeakingRate = 1.0
+	}
+	if opts.DinhDang == "" {
+		opts.DinhDang = FormatNarration
+	}
+
+	job := models.ProcessingJob{
+		ID:             uuid.New().String(),
+		TaiLieuID:      doc.ID,
+		Status:         JobPending,
+		Stage:          StageExtract,
+		MaxAttempts:    config.GetJobQueueConfig().MaxAttempts,
+		NextRunAt:      time.Now(),
+		Voice:          opts.Voice,
+		SpeakingRate:   opts.SpeakingRate,
+		NguoiTao:       userID,
+		TieuDe:         opts.TieuDe,
+		MoTa:           opts.MoTa,
+		DanhMucID:      opts.DanhMucID,
+		HinhAnhDaiDien: opts.HinhAnhDaiDien,
+		TheTag:         opts.TheTag,
+		DinhDang:       opts.DinhDang,
+		VoiceB:         opts.VoiceB,
+		MauLamSach:     opts.MauLamSach,
+		MauKichBan:     opts.MauKichBan,
+		MauTomTat:      opts.MauTomTat,
+		GiongVan:       opts.GiongVan,
+	}
+	if opts.TieuDe != "" && opts.DanhMucID != "" {
+		// Cấp sẵn ID để client theo dõi podcast ngay từ lúc upload
+		job.PodcastID = uuid.New().String()
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&doc).Error; err != nil {
+			return err
+		}
+		return tx.Create(&job).Error
+	})
+	if err != nil {
+		ws.SendStatusUpdate(doc.ID, "Không thể lưu tài liệu vào database", 0, err.Error())
+		return nil, nil, fmt.Errorf("không lưu được tài liệu: %w", err)
+	}
+
+	ws.SendStatusUpdate(doc.ID, "Đã tải lên", 10, "")
+	ws.BroadcastDocumentListChanged()
+	wakeDocumentWorkers()
+
+	return &doc, &job, nil
+}
+
+// StartDocumentWorkers khởi chạy các worker xử lý tài liệu nền
+func StartDocumentWorkers(db *gorm.DB) {
+	cfg := config.GetJobQueueConfig()
+	for i := 0; i < cfg.Workers; i++ {
+		go runDocumentWorker(db, cfg)
+	}
+	log.Printf("Đã khởi chạy %d worker xử lý tài liệu\n", cfg.Workers)
+}
+
+func wakeDocumentWorkers() {
+	select {
+	case jobWakeup <- struct{}{}:
+	default:
+	}
+}
+
+func runDocumentWorker(db *gorm.DB, cfg config.JobQueueConfig) {
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-jobWakeup:
+		}
+
+		// Xử lý hết job đến hạn rồi mới quay lại chờ
+		for {
+			job, err := claimNextJob(db, cfg)
+			if err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					log.Println("Lỗi lấy job:", err)
+				}
+				break
+			}
+			processJob(db, cfg, job)
+		}
+	}
+}
+
+// claimNextJob giữ 1 job đến hạn (hoặc job RUNNING bị treo quá StaleAfter)
+func claimNextJob(db *gorm.DB, cfg config.JobQueueConfig) (*models.ProcessingJob, error) {
+	now := time.Now()
+	staleBefore := now.Add(-cfg.StaleAfter)
+
+	for {
+		var job models.ProcessingJob
+		err := db.Where("(status = ? AND next_run_at <= ?) OR (status = ? AND locked_at < ?)",
+			JobPending, now, JobRunning, staleBefore).
+			Order("next_run_at").
+			First(&job).Error
+		if err != nil {
+			return nil, err
+		}
+
+		// Chỉ 1 worker cập nhật thành công nhờ điều kiện trên status + locked_at
+		query := db.Model(&models.ProcessingJob{}).Where("id = ? AND status = ?", job.ID, job.Status)
+		if job.Status == JobPending {
+			query = query.Where("locked_at IS NULL")
+		} else {
+			query = query.Where("locked_at < ?", staleBefore)
+		}
+		res := query.Updates(map[string]interface{}{
+			"status":    JobRunning,
+			"locked_at": now,
+		})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 1 {
+			job.Status = JobRunning
+			job.LockedAt = &now
+			return &job, nil
+		}
+		// Worker khác đã lấy job này, thử job tiếp theo
+	}
+}
+
+func processJob(db *gorm.DB, cfg config.JobQueueConfig, job *models.ProcessingJob) {
+	defer startJobHeartbeat(db, cfg, job.ID)()
+
+	var doc models.TaiLieu
+	if err := db.First(&doc, "id = ?", job.TaiLieuID).Error; err != nil {
+		failJob(db, job, fmt.Errorf("không tìm thấy tài liệu: %w", err))
+		return
+	}
+
+	for job.Stage != StageDone {
+		if shouldSkipStage(job, &doc) {
+			ws.SendStatusUpdate(doc.ID, "Bỏ qua bước đã có kết quả: "+job.Stage, stageProgress[job.Stage], "")
+		} else if err := runJobStage(db, job, &doc); err != nil {
+			handleStageError(db, cfg, job, err)
+			return
+		}
+
+		now := time.Now()
+		job.Stage = nextStage(job.Stage)
+		job.Attempts = 0
+		db.Model(job).Updates(map[string]interface{}{
+			"stage":        job.Stage,
+			"attempts":     0,
+			"last_error":   "",
+			"da_tao_audio": job.DaTaoAudio,
+			"locked_at":    now, // Gia hạn khoá sau mỗi bước
+		})
+	}
+
+	now := time.Now()
+	db.Model(job).Updates(map[string]interface{}{
+		"status":       JobDone,
+		"locked_at":    nil,
+		"finished_at":  &now,
+		"noi_dung_tho": "",
+	})
+}
+
+// startJobHeartbeat gia hạn khoá định kỳ trong khi job chạy, để bước dài (tạo audio) không bị worker khác
+// coi là treo quá StaleAfter và lấy lại. Gọi hàm trả về để dừng
+func startJobHeartbeat(db *gorm.DB, cfg config.JobQueueConfig, jobID string) func() {
+	interval := cfg.StaleAfter / 3
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				db.Model(&models.ProcessingJob{}).
+					Where("id = ? AND status = ?", jobID, JobRunning).
+					Update("locked_at", time.Now())
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func nextStage(stage string) string {
+	switch stage {
+	case StageTranslate:
+		return StageSummary
+	case StageRevoice:
+		return StageDone
+	}
+	for i, s := range jobStages {
+		if s == stage && i+1 < len(jobStages) {
+			return jobStages[i+1]
+		}
+	}
+	return StageDone
+}
+
+func runJobStage(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu) error {
+	switch job.Stage {
+	case StageExtract:
+		ws.SendStatusUpdate(doc.ID, "Đang trích xuất nội dung...", 20, "")
+		input, err := documentInputSource(doc)
+		if err != nil {
+			return err
+		}
+		structured, err := NormalizeInputStructured(input)
+		if err != nil {
+			return err
+		}
+		noiDung := structured.PlainText()
+		if strings.TrimSpace(noiDung) == "" {
+			return errors.New("không trích xuất được nội dung văn bản")
+		}
+		// Tài liệu dài được tách thành nhiều tập theo chương/mục
+		if err := saveEpisodePlan(db, doc, PlanEpisodes(structured, config.GetSeriesConfig())); err != nil {
+			return err
+		}
+		// Ngôn ngữ của tài liệu quyết định mẫu prompt và giọng đọc (job không chọn giọng thì chọn theo ngôn ngữ)
+		doc.NgonNgu = DetectLanguage(noiDung)
+		db.Model(doc).Update("ngon_ngu", doc.NgonNgu)
+		if job.Voice == "" {
+			job.Voice = DefaultVoiceFor(job.DanhMucID, doc.NgonNgu)
+		}
+		job.NoiDungTho = noiDung
+		return db.Model(job).Updates(map[string]interface{}{
+			"noi_dung_tho": noiDung,
+			"voice":        job.Voice,
+		}).Error
+
+	case StageTranslate:
+		return runTranslateStage(db, job, doc)
+
+	case StageRevoice:
+		return runRevoiceStage(db, job, doc)
+
+	case StageClean:
+		if doc.SoTap > 0 {
+			return runSeriesClean(db, job, doc)
+		}
+		ws.SendStatusUpdate(doc.ID, "Đang làm sạch nội dung...", 30, "")
+		cleanedContent, source, err := CleanTextPipeline(job.NoiDungTho, buildPromptContext(db, job, doc), job.DinhDang)
+		if err != nil {
+			return err
+		}
+		doc.NoiDungTrichXuat = cleanedContent
+		doc.MauKichBan = source
+		doc.TrangThai = "Đã trích xuất"
+		db.Model(doc).Updates(map[string]interface{}{
+			"TrangThai":        doc.TrangThai,
+			"NoiDungTrichXuat": cleanedContent,
+			"MauKichBan":       source,
+		})
+		ws.SendStatusUpdate(doc.ID, "Đã trích xuất", 40, "")
+		ws.BroadcastDocumentListChanged()
+		return nil
+
+	case StageSummary:
+		if doc.SoTap > 0 {
+			return runSeriesSummary(db, job, doc)
+		}
+		ws.SendStatusUpdate(doc.ID, "Đang tạo tóm tắt...", 45, "")
+		summary, source, err := GenerateSummary(scriptPlainText(doc.NoiDungTrichXuat, job.DinhDang), buildPromptContext(db, job, doc))
+		if err != nil {
+			return err
+		}
+		doc.TomTat = summary.Summary
+		doc.CauChinh = KeySentencesJSON(summary.KeySentences)
+		doc.MauTomTat = source
+		doc.TrangThai = "Đã xử lý AI"
+		db.Model(doc).Updates(map[string]interface{}{
+			"TrangThai": doc.TrangThai,
+			"TomTat":    doc.TomTat,
+			"CauChinh":  doc.CauChinh,
+			"MauTomTat": source,
+		})
+		ws.SendStatusUpdate(doc.ID, "Đã tạo tóm tắt", 47, "")
+		ws.BroadcastDocumentListChanged()
+		return nil
+
+	case StageAudio:
+		if doc.SoTap > 0 {
+			return runSeriesAudio(db, job, doc)
+		}
+		ws.SendStatusUpdate(doc.ID, "Đang tạo audio...", 50, "")
+		result, err := synthesizeForJob(job, doc.NoiDungTrichXuat, func(done, total int) {
+			ws.SendStatusUpdate(doc.ID, fmt.Sprintf("Đang tạo audio (%d/%d đoạn)...", done, total), progressBetween(50, 60, done, total), "")
+		})
+		if err != nil {
+			return err
+		}
+		reportTTSCache(job, result, 60)
+
+		tags := audioTagsForJob(db, job, doc, nil)
+		tags.Chapters = result.Chapters
+		audioData := TagMP3(result.Audio, tags)
+
+		ws.SendStatusUpdate(doc.ID, "Đang lưu audio...", 60, "")
+		filename := doc.ID + ".mp3"
+		if doc.DuongDanAudio != "" {
+			// Xử lý lại: không ghi đè file cũ để tránh CDN trả bản cache
+			filename = fmt.Sprintf("%s-%d.mp3", doc.ID, time.Now().Unix())
+		}
+		audioURL, err := utils.UploadBytesToSupabase(audioData, filename, "audio/mp3")
+		if err != nil {
+			return err
+		}
+		doc.DuongDanAudio = audioURL
+		doc.ChuongMuc = EncodeChapters(result.Chapters)
+		doc.PhuDe = EncodeTranscript(result.Transcript)
+		job.DaTaoAudio = true
+		db.Model(doc).Updates(map[string]interface{}{
+			"DuongDanAudio": audioURL,
+			"ChuongMuc":     doc.ChuongMuc,
+			"PhuDe":         doc.PhuDe,
+		})
+		ws.SendStatusUpdate(doc.ID, "Đã lưu audio", 70, "")
+		return nil
+
+	case StageFinalize:
+		if doc.SoTap > 0 {
+			// Series: mỗi tập 1 podcast, cần thông tin podcast từ lúc upload
+			if job.PodcastID != "" || (job.TieuDe != "" && job.DanhMucID != "") {
+				if err := createSeriesPodcasts(db, job, doc); err != nil {
+					return err
+				}
+			}
+			if syncsPodcastAudio(job) {
+				if err := syncSeriesPodcastAudio(db, doc); err != nil {
+					return err
+				}
+			}
+		} else {
+			if job.PodcastID != "" {
+				if err := createPodcastForJob(db, job, doc); err != nil {
+					return err
+				}
+			}
+			if syncsPodcastAudio(job) {
+				if err := syncPodcastAudio(db, doc); err != nil {
+					return err
+				}
+			}
+		}
+
+		now := time.Now()
+		doc.TrangThai = "Hoàn thành"
+		db.Model(doc).Updates(map[string]interface{}{
+			"TrangThai":    doc.TrangThai,
+			"NgayXuLyXong": &now,
+		})
+
+		// Tạo thông báo realtime
+		action := "upload_document"
+		message := fmt.Sprintf("Người dùng %s đã tải lên tài liệu: %s", job.NguoiTao, doc.TenFileGoc)
+		if job.Reprocess {
+			action = "reprocess_document"
+			message = fmt.Sprintf("Tài liệu %s đã được xử lý lại", doc.TenFileGoc)
+		} else if job.BanGocID != "" {
+			action = "translate_podcast"
+			message = fmt.Sprintf("Đã tạo bản %s cho podcast: %s", LanguageName(doc.NgonNgu), job.TieuDe)
+		}
+		if err := CreateNotification(job.NguoiTao, doc.ID, action, message); err != nil {
+			fmt.Println("Lỗi khi tạo thông báo:", err)
+		}
+
+		ws.SendStatusUpdate(doc.ID, "Hoàn thành", 100, "")
+		ws.BroadcastDocumentListChanged()
+		return nil
+	}
+
+	return fmt.Errorf("bước xử lý không hợp lệ: %s", job.Stage)
+}
+
+// createPodcastForJob tạo podcast với ID đã cấp sẵn, bỏ qua nếu đã tồn tại (chạy lại an toàn)
+func createPodcastForJob(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu) error {
+	var count int64
+	db.Model(&models.Podcast{}).Where("id = ?", job.PodcastID).Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	durationFloat, _ := GetMP3DurationFromURL(doc.DuongDanAudio)
+
+	podcast := models.Podcast{
+		ID:             job.PodcastID,
+		TailieuID:      doc.ID,
+		TieuDe:         job.TieuDe,
+		MoTa:           job.MoTa,
+		DuongDanAudio:  doc.DuongDanAudio,
+		ThoiLuongGiay:  int(durationFloat),
+		ChuongMuc:      doc.ChuongMuc,
+		PhuDe:          doc.PhuDe,
+		HinhAnhDaiDien: job.HinhAnhDaiDien,
+		DanhMucID:      job.DanhMucID,
+		TrangThai:      "Tắt",
+		NguoiTao:       job.NguoiTao,
+		TheTag:         job.TheTag,
+		IsVIP:          true, // Podcast mới luôn là VIP (trong 7 ngày)
+		NgonNgu:        doc.NgonNgu,
+		BanGocID:       job.BanGocID,
+	}
+	if job.BanGocID != "" {
+		// Bản dịch của 1 tập giữ số tập để ghép thành series cùng ngôn ngữ
+		var goc models.Podcast
+		if err := db.Select("so_tap").First(&goc, "id = ?", job.BanGocID).Error; err == nil {
+			podcast.SoTap = goc.SoTap
+		}
+	}
+	if err := db.Create(&podcast).Error; err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Admin %s đã tạo podcast: %s", job.NguoiTao, job.TieuDe)
+	CreateNotification(job.NguoiTao, podcast.ID, "create_podcast", message)
+	return nil
+}
+
+// audioTagsForJob lấy thông tin gắn thẻ ID3: ưu tiên podcast đã có (xử lý lại), ngược lại dùng thông tin lúc upload.
+// ep != nil: audio của 1 tập trong series
+func audioTagsForJob(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu, ep *models.TapTaiLieu) MP3Tags {
+	var podcast models.Podcast
+	found := false
+	switch {
+	case ep != nil && ep.PodcastID != "":
+		found = db.Preload("DanhMuc").First(&podcast, "id = ?", ep.PodcastID).Error == nil
+	case ep == nil:
+		found = db.Preload("DanhMuc").Where("tailieu_id = ? AND so_tap <= 1", doc.ID).First(&podcast).Error == nil
+	}
+
+	tags := MP3Tags{Title: job.TieuDe}
+	coverURL := job.HinhAnhDaiDien
+	if found {
+		tags.Title, tags.Genre, coverURL = podcast.TieuDe, podcast.DanhMuc.TenDanhMuc, podcast.HinhAnhDaiDien
+	} else {
+		if ep != nil {
+			tags.Title = seriesEpisodeTitle(job.TieuDe, ep)
+		}
+		var danhMuc models.DanhMuc
+		if job.DanhMucID != "" && db.First(&danhMuc, "id = ?", job.DanhMucID).Error == nil {
+			tags.Genre = danhMuc.TenDanhMuc
+		}
+	}
+	if tags.Title == "" {
+		tags.Title = doc.TenFileGoc
+	}
+	if ep != nil {
+		tags.Album = job.TieuDe
+	}
+	if coverURL != "" {
+		tags.Cover, tags.CoverMIME = fetchCoverImage(coverURL)
+	}
+	return tags
+}
+
+// reportTTSCache ghi log và báo số đoạn TTS lấy từ bộ nhớ đệm thay vì tổng hợp lại
+func reportTTSCache(job *models.ProcessingJob, result *SynthesisResult, progress float64) {
+	total := result.CacheHits + result.CacheMisses
+	log.Printf("Job %s: TTS dùng lại %d/%d đoạn từ bộ nhớ đệm\n", job.ID, result.CacheHits, total)
+	if result.CacheHits > 0 {
+		ws.SendStatusUpdate(job.TaiLieuID, fmt.Sprintf("Dùng lại %d/%d đoạn audio từ bộ nhớ đệm", result.CacheHits, total), progress, "")
+	}
+}
+
+// handleStageError thử lại bước lỗi với backoff luỹ thừa, quá số lần thì đánh dấu FAILED
+func handleStageError(db *gorm.DB, cfg config.JobQueueConfig, job *models.ProcessingJob, stageErr error) {
+	job.Attempts++
+	label := stageErrorMessages[job.Stage]
+	log.Printf("Job %s lỗi ở bước %s (lần %d/%d): %v\n", job.ID, job.Stage, job.Attempts, job.MaxAttempts, stageErr)
+
+	if job.Attempts >= job.MaxAttempts {
+		failJob(db, job, stageErr)
+		return
+	}
+
+	backoff := cfg.BaseBackoff << (job.Attempts - 1)
+	db.Model(job).Updates(map[string]interface{}{
+		"status":      JobPending,
+		"attempts":    job.Attempts,
+		"last_error":  stageErr.Error(),
+		"next_run_at": time.Now().Add(backoff),
+		"locked_at":   nil,
+	})
+	ws.SendStatusUpdate(job.TaiLieuID, fmt.Sprintf("%s, sẽ thử lại sau %s", label, backoff), 0, stageErr.Error())
+}
+
+func failJob(db *gorm.DB, job *models.ProcessingJob, jobErr error) {
+	now := time.Now()
+	db.Model(job).Updates(map[string]interface{}{
+		"status":      JobFailed,
+		"attempts":    job.Attempts,
+		"last_error":  jobErr.Error(),
+		"locked_at":   nil,
+		"finished_at": &now,
+	})
+	failRevoiceVersion(db, job, jobErr)
+
+	label := stageErrorMessages[job.Stage]
+	if label == "" {
+		label = "Xử lý tài liệu thất bại"
+	}
+	ws.SendStatusUpdate(job.TaiLieuID, label, 0, jobErr.Error())
+}
+
+// documentInputSource dựng nguồn input cho tài liệu: URL bài viết hoặc file gốc trên Supabase
+func documentInputSource(doc *models.TaiLieu) (InputSource, error) {
+	if doc.LoaiFile == string(InputURL) {
+		return InputSource{Type: InputURL, URL: doc.DuongDanFile}, nil
+	}
+
+	inputType, err := GetInputTypeFromExt("." + doc.LoaiFile)
+	if err != nil {
+		return InputSource{}, err
+	}
+	data, err := downloadDocumentFile(doc.DuongDanFile)
+	if err != nil {
+		return InputSource{}, err
+	}
+	return InputSource{Type: inputType, Data: data}, nil
+}
+
+// downloadDocumentFile tải lại file gốc đã lưu trên Supabase
+func downloadDocumentFile(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("không thể tải file gốc: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("không thể tải file gốc, status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
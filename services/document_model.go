@@ -0,0 +1,190 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Loại khối nội dung trong tài liệu có cấu trúc
+type BlockKind string
+
+const (
+	BlockParagraph BlockKind = "paragraph"
+	BlockListItem  BlockKind = "list_item"
+	BlockTable     BlockKind = "table" // Bảng đã được diễn giải thành câu
+)
+
+// Một khối nội dung (đoạn văn, mục liệt kê, bảng)
+type DocBlock struct {
+	Kind  BlockKind `json:"kind"`
+	Level int       `json:"level,omitempty"` // Cấp lồng của mục liệt kê (0 = cấp ngoài cùng)
+	Text  string    `json:"text"`
+}
+
+// Một phần của tài liệu, bắt đầu bằng tiêu đề (Level 0 = phần mở đầu không có tiêu đề)
+type DocSection struct {
+	Title  string     `json:"title"`
+	Level  int        `json:"level"`
+	Blocks []DocBlock `json:"blocks"`
+}
+
+// Mô hình tài liệu có cấu trúc dùng chung cho các bộ trích xuất
+type StructuredDocument struct {
+	Sections []DocSection `json:"sections"`
+}
+
+// AddHeading mở phần mới với tiêu đề và cấp tiêu đề (1 = cao nhất)
+func (d *StructuredDocument) AddHeading(title string, level int) {
+	title = normalizeSpaces(title)
+	if title == "" {
+		return
+	}
+	if level < 1 {
+		level = 1
+	}
+	d.Sections = append(d.Sections, DocSection{Title: title, Level: level})
+}
+
+// AddBlock thêm khối nội dung vào phần hiện tại
+func (d *StructuredDocument) AddBlock(block DocBlock) {
+	block.Text = normalizeSpaces(block.Text)
+	if block.Text == "" {
+		return
+	}
+	if len(d.Sections) == 0 {
+		d.Sections = append(d.Sections, DocSection{})
+	}
+	last := &d.Sections[len(d.Sections)-1]
+	last.Blocks = append(last.Blocks, block)
+}
+
+// PlainText xuất văn bản thuần, mỗi tiêu đề/đoạn/mục là 1 đoạn cách nhau bởi dòng trống
+func (d *StructuredDocument) PlainText() string {
+	var paragraphs []string
+	for _, s := range d.Sections {
+		if s.Title != "" {
+			paragraphs = append(paragraphs, s.Title)
+		}
+		for _, b := range s.Blocks {
+			text := b.Text
+			if b.Kind == BlockListItem {
+				// Thêm dấu câu để TTS ngắt nghỉ giữa các mục
+				text = ensureSentenceEnd(text)
+			}
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// Dòng tiêu đề trong văn bản thuần: "Chương 3", "PHẦN II: ...", "Chapter 1" (cấp theo từ khoá).
+// Không nhận "năm" vì còn nghĩa là "năm (year)": "Phần năm nay..." không phải tiêu đề
+var plainHeadingRe = regexp.MustCompile(`(?i)^(quyển|tập|phần|part|chương|chapter|bài|mục|section)\s+([0-9]+|[ivxlc]+|một|hai|ba|bốn|sáu|bảy|tám|chín|mười)(\s|[:.)\-–]|$)`)
+
+var plainParagraphSplitRe = regexp.MustCompile(`\n\s*\n`)
+
+var plainHeadingLevels = map[string]int{
+	"quyển": 1, "tập": 1, "phần": 1, "part": 1,
+	"chương": 2, "chapter": 2,
+	"bài": 3, "mục": 3, "section": 3,
+}
+
+// StructuredFromPlainText dựng lại cấu trúc từ văn bản thuần: đoạn cách nhau bởi dòng trống, tiêu đề nhận theo từ khoá
+func StructuredFromPlainText(text string) *StructuredDocument {
+	doc := &StructuredDocument{}
+	for _, para := range plainParagraphSplitRe.Split(text, -1) {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if level, ok := plainHeadingLevel(para); ok {
+			doc.AddHeading(para, level)
+			continue
+		}
+		doc.AddBlock(DocBlock{Kind: BlockParagraph, Text: para})
+	}
+	return doc
+}
+
+// plainHeadingLevel nhận tiêu đề theo từ khoá: đoạn ngắn, không kết thúc bằng dấu chấm
+func plainHeadingLevel(para string) (int, bool) {
+	m := plainHeadingRe.FindStringSubmatch(para)
+	if m == nil || len([]rune(para)) > 120 || strings.HasSuffix(para, ".") {
+		return 0, false
+	}
+	return plainHeadingLevels[strings.ToLower(m[1])], true
+}
+
+// renderTableSentences diễn giải bảng thành câu: hàng đầu là tiêu đề cột, mỗi hàng sau thành "Cột: giá trị, ..."
+func renderTableSentences(rows [][]string) []string {
+	var cleaned [][]string
+	for _, row := range rows {
+		var cells []string
+		empty := true
+		for _, cell := range row {
+			cell = normalizeSpaces(cell)
+			if cell != "" {
+				empty = false
+			}
+			cells = append(cells, cell)
+		}
+		if !empty {
+			cleaned = append(cleaned, cells)
+		}
+	}
+	if len(cleaned) == 0 {
+		return nil
+	}
+
+	var sentences []string
+	if len(cleaned) == 1 {
+		return []string{ensureSentenceEnd(strings.Join(nonEmpty(cleaned[0]), ", "))}
+	}
+
+	header := cleaned[0]
+	for _, row := range cleaned[1:] {
+		var parts []string
+		for i, cell := range row {
+			if cell == "" {
+				continue
+			}
+			if i < len(header) && header[i] != "" && header[i] != cell {
+				parts = append(parts, header[i]+": "+cell)
+			} else {
+				parts = append(parts, cell)
+			}
+		}
+		if len(parts) > 0 {
+			sentences = append(sentences, ensureSentenceEnd(strings.Join(parts, ", ")))
+		}
+	}
+	return sentences
+}
+
+func nonEmpty(items []string) []string {
+	var out []string
+	for _, s := range items {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ensureSentenceEnd thêm dấu chấm nếu câu chưa kết thúc bằng dấu câu
+func ensureSentenceEnd(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return s
+	}
+	last := []rune(s)[len([]rune(s))-1]
+	if unicode.IsPunct(last) && last != ',' && last != ')' && last != '"' {
+		return s
+	}
+	return s + "."
+}
+
+func normalizeSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
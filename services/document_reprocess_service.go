@@ -0,0 +1,252 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/Huong3203/APIPodcast/ws"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrJobActive     = errors.New("tài liệu đang được xử lý")
+	ErrInvalidStage  = errors.New("bước xử lý không hợp lệ")
+	ErrInvalidFormat = errors.New("định dạng không hợp lệ (narration, dialogue)")
+)
+
+// Các bước bị buộc chạy lại khi admin chọn 1 bước: văn bản làm sạch thay đổi thì tóm tắt + audio cũng phải làm lại
+var forcedStagesByStage = map[string][]string{
+	StageExtract: {StageExtract, StageClean, StageSummary, StageAudio},
+	StageClean:   {StageClean, StageSummary, StageAudio},
+	StageSummary: {StageSummary},
+	StageAudio:   {StageAudio},
+}
+
+type ReprocessOptions struct {
+	ForceStage   string // Rỗng = chỉ chạy các bước còn thiếu kết quả
+	Voice        string // Rỗng = dùng lại giọng của lần xử lý trước
+	SpeakingRate float64
+	DinhDang     string // Rỗng = giữ định dạng cũ; đổi định dạng thì nội dung được viết lại từ bước làm sạch
+	VoiceB       string
+}
+
+// ReprocessDocument tạo job xử lý lại tài liệu, bỏ qua các bước đã có kết quả lưu trong DB
+func ReprocessDocument(db *gorm.DB, docID, userID string, opts ReprocessOptions) (*models.ProcessingJob, error) {
+	if opts.ForceStage != "" {
+		if _, ok := forcedStagesByStage[opts.ForceStage]; !ok {
+			return nil, ErrInvalidStage
+		}
+	}
+	if !IsValidFormat(opts.DinhDang) {
+		return nil, ErrInvalidFormat
+	}
+
+	var doc models.TaiLieu
+	if err := db.First(&doc, "id = ?", docID).Error; err != nil {
+		return nil, err
+	}
+
+	// Giọng/tốc độ audio hiện tại được tạo với job hoàn tất gần nhất (tài liệu cũ chưa có job: mọi lựa chọn đều là đổi)
+	var synthJob models.ProcessingJob
+	db.Where("tai_lieu_id = ? AND status = ? AND revoice = ?", docID, JobDone, false).Order("created_at desc").First(&synthJob)
+	voiceChanged := (opts.Voice != "" && opts.Voice != synthJob.Voice) ||
+		(opts.VoiceB != "" && opts.VoiceB != synthJob.VoiceB) ||
+		(opts.SpeakingRate > 0 && opts.SpeakingRate != synthJob.SpeakingRate)
+
+	// Lấy cấu hình giọng đọc từ job gần nhất nếu admin không chỉ định (job đọc lại podcast chỉ tạo phiên bản audio, không tính)
+	var lastJob models.ProcessingJob
+	db.Where("tai_lieu_id = ? AND revoice = ?", docID, false).Order("created_at desc").First(&lastJob)
+	if opts.Voice == "" {
+		opts.Voice = lastJob.Voice
+	}
+	if opts.Voice == "" {
+		opts.Voice = DefaultVoiceFor(lastJob.DanhMucID, doc.NgonNgu)
+	}
+	if opts.SpeakingRate <= 0 {
+		opts.SpeakingRate = lastJob.SpeakingRate
+	}
+	if opts.SpeakingRate <= 0 {
+		opts.SpeakingRate = 1.0
+	}
+	if opts.VoiceB == "" {
+		opts.VoiceB = lastJob.VoiceB
+	}
+	lastFormat := lastJob.DinhDang
+	if lastFormat == "" {
+		lastFormat = FormatNarration
+	}
+	if opts.DinhDang == "" {
+		opts.DinhDang = lastFormat
+	}
+	if doc.DichTuID != "" && opts.DinhDang != lastFormat {
+		return nil, ErrTranslationFormat
+	}
+	if opts.DinhDang != lastFormat && opts.ForceStage != StageExtract {
+		// Nội dung cũ viết theo định dạng khác: viết lại từ bước làm sạch
+		opts.ForceStage = StageClean
+	}
+	if voiceChanged && !forcesStage(opts.ForceStage, StageAudio) {
+		// Đổi giọng/tốc độ thì audio cũ không còn đúng, dù admin chỉ chọn chạy lại bước khác
+		opts.ForceStage = strings.TrimPrefix(opts.ForceStage+","+StageAudio, ",")
+	}
+
+	job := models.ProcessingJob{
+		ID:           uuid.New().String(),
+		TaiLieuID:    docID,
+		Status:       JobPending,
+		Stage:        StageExtract,
+		MaxAttempts:  config.GetJobQueueConfig().MaxAttempts,
+		NextRunAt:    time.Now(),
+		Voice:        opts.Voice,
+		SpeakingRate: opts.SpeakingRate,
+		NguoiTao:     userID,
+		Reprocess:    true,
+		ForceStage:   opts.ForceStage,
+		DinhDang:     opts.DinhDang,
+		VoiceB:       opts.VoiceB,
+
+		// Giữ thông tin podcast để tạo podcast cho tập mới nếu tài liệu được tách lại thành nhiều tập
+		TieuDe:         lastJob.TieuDe,
+		MoTa:           lastJob.MoTa,
+		DanhMucID:      lastJob.DanhMucID,
+		HinhAnhDaiDien: lastJob.HinhAnhDaiDien,
+		TheTag:         lastJob.TheTag,
+		MauLamSach:     lastJob.MauLamSach,
+		MauKichBan:     lastJob.MauKichBan,
+		MauTomTat:      lastJob.MauTomTat,
+		GiongVan:       lastJob.GiongVan,
+	}
+	if doc.DichTuID != "" {
+		// Bản dịch: giữ liên kết với podcast gốc (tạo podcast nếu lần trước lỗi trước khi hoàn tất),
+		// cần dịch lại thì lấy nội dung mới nhất của podcast gốc thay cho trích xuất + làm sạch
+		job.BanGocID, job.PodcastID = lastJob.BanGocID, lastJob.PodcastID
+		if isStageForced(&job, StageClean) || doc.NoiDungTrichXuat == "" {
+			src, err := loadRevoiceSource(db, lastJob.BanGocID)
+			if err != nil {
+				return nil, err
+			}
+			job.Stage = StageTranslate
+			job.NoiDungTho = src.text
+			job.TieuDe, job.MoTa = src.podcast.TieuDe, src.podcast.MoTa
+		}
+	}
+	// Khoá dòng tài liệu để kiểm tra job đang chạy và tạo job mới trong cùng 1 giao dịch (2 yêu cầu đồng thời không tạo 2 job)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var locked models.TaiLieu
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, "id = ?", docID).Error; err != nil {
+			return err
+		}
+		var active int64
+		if err := tx.Model(&models.ProcessingJob{}).
+			Where("tai_lieu_id = ? AND status IN ?", docID, []string{JobPending, JobRunning}).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrJobActive
+		}
+		if err := tx.Create(&job).Error; err != nil {
+			return fmt.Errorf("không thể tạo job xử lý lại: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ws.SendStatusUpdate(docID, "Đã xếp hàng xử lý lại", 0, "")
+	wakeDocumentWorkers()
+	return &job, nil
+}
+
+// shouldSkipStage cho biết bước hiện tại đã có kết quả lưu sẵn và không bị buộc chạy lại
+func shouldSkipStage(job *models.ProcessingJob, doc *models.TaiLieu) bool {
+	switch job.Stage {
+	case StageExtract:
+		// Văn bản thô chỉ cần khi bước làm sạch phải chạy
+		if isStageForced(job, StageExtract) {
+			return false
+		}
+		if job.NoiDungTho != "" {
+			return true
+		}
+		return doc.NoiDungTrichXuat != "" && !isStageForced(job, StageClean)
+	}
+
+	// Series: từng tập tự bỏ qua phần đã có kết quả bên trong bước
+	if doc.SoTap > 0 {
+		return false
+	}
+
+	switch job.Stage {
+	case StageClean:
+		return doc.NoiDungTrichXuat != "" && !isStageForced(job, StageClean)
+	case StageSummary:
+		return doc.TomTat != "" && !isStageForced(job, StageSummary)
+	case StageAudio:
+		return doc.DuongDanAudio != "" && !isStageForced(job, StageAudio)
+	}
+	return false
+}
+
+func isStageForced(job *models.ProcessingJob, stage string) bool {
+	return forcesStage(job.ForceStage, stage)
+}
+
+// forcesStage: ForceStage có thể gồm nhiều bước cách nhau dấu phẩy ("summary,audio")
+func forcesStage(forceStage, stage string) bool {
+	for _, f := range strings.Split(forceStage, ",") {
+		for _, s := range forcedStagesByStage[f] {
+			if s == stage {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncsPodcastAudio: xử lý lại chỉ đồng bộ audio sang podcast khi job đã tạo audio mới,
+// để không ghi đè phiên bản đọc lại admin đã kích hoạt khi chỉ chạy lại tóm tắt
+func syncsPodcastAudio(job *models.ProcessingJob) bool {
+	return job.Reprocess && job.DaTaoAudio
+}
+
+// syncPodcastAudio cập nhật audio + thời lượng + mốc chương + phụ đề cho các podcast dùng tài liệu này
+func syncPodcastAudio(db *gorm.DB, doc *models.TaiLieu) error {
+	var podcasts []models.Podcast
+	query := db.Where("tailieu_id = ? AND duong_dan_audio <> ?", doc.ID, doc.DuongDanAudio)
+	if doc.DichTuID == "" {
+		query = query.Where("so_tap <= 1")
+	}
+	if err := query.Find(&podcasts).Error; err != nil {
+		return err
+	}
+	if len(podcasts) == 0 {
+		return nil
+	}
+
+	durationFloat, _ := GetMP3DurationFromURL(doc.DuongDanAudio)
+	for i := range podcasts {
+		updates := map[string]interface{}{
+			"duong_dan_audio": doc.DuongDanAudio,
+			"thoi_luong_giay": int(durationFloat),
+			"chuong_muc":      doc.ChuongMuc,
+			"phu_de":          doc.PhuDe,
+			"so_tap":          0,
+		}
+		if doc.DichTuID != "" {
+			// Bản dịch của 1 tập giữ số tập của podcast gốc
+			delete(updates, "so_tap")
+		}
+		if err := db.Model(&podcasts[i]).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
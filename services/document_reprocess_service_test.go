@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/Huong3203/APIPodcast/models"
+)
+
+// Podcast đang phát phiên bản đọc lại đã kích hoạt (audio khác tài liệu), admin xử lý lại chỉ bước tóm tắt:
+// bước audio bị bỏ qua nên bước hoàn tất không được đồng bộ audio tài liệu đè lên podcast
+func TestReprocessSummaryKeepsActivatedAudioVersion(t *testing.T) {
+	doc := &models.TaiLieu{
+		NoiDungTrichXuat: "Nội dung đã làm sạch",
+		TomTat:           "Tóm tắt cũ",
+		DuongDanAudio:    "https://cdn/doc.mp3",
+	}
+	job := &models.ProcessingJob{Stage: StageExtract, Reprocess: true, ForceStage: StageSummary}
+
+	var ran []string
+	for job.Stage != StageFinalize {
+		if !shouldSkipStage(job, doc) {
+			ran = append(ran, job.Stage)
+		}
+		job.Stage = nextStage(job.Stage)
+	}
+	if len(ran) != 1 || ran[0] != StageSummary {
+		t.Fatalf("các bước chạy = %v, muốn chỉ [%s]", ran, StageSummary)
+	}
+	if syncsPodcastAudio(job) {
+		t.Error("xử lý lại không tạo audio mới nhưng vẫn đồng bộ audio sang podcast")
+	}
+}
+
+func TestReprocessAudioSyncsPodcastAudio(t *testing.T) {
+	doc := &models.TaiLieu{DuongDanAudio: "https://cdn/doc.mp3"}
+	job := &models.ProcessingJob{Stage: StageAudio, Reprocess: true, ForceStage: StageSummary + "," + StageAudio}
+	if shouldSkipStage(job, doc) {
+		t.Fatal("bước audio bị buộc chạy lại nhưng vẫn bị bỏ qua")
+	}
+	// runJobStage đánh dấu sau khi lưu audio mới
+	job.DaTaoAudio = true
+	if !syncsPodcastAudio(job) {
+		t.Error("xử lý lại đã tạo audio mới nhưng không đồng bộ sang podcast")
+	}
+
+	upload := &models.ProcessingJob{DaTaoAudio: true}
+	if syncsPodcastAudio(upload) {
+		t.Error("job upload lần đầu không cần đồng bộ audio podcast")
+	}
+}
@@ -0,0 +1,522 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/Huong3203/APIPodcast/utils"
+	"github.com/Huong3203/APIPodcast/ws"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kế hoạch 1 tập: tiêu đề chương/mục và văn bản thô của tập
+type EpisodePlan struct {
+	Title string
+	Text  string
+}
+
+// PlanEpisodes chia tài liệu thành các tập theo tiêu đề, gộp phần quá ngắn và cắt phần quá dài
+func PlanEpisodes(doc *StructuredDocument, cfg config.SeriesConfig) []EpisodePlan {
+	full := doc.PlainText()
+	if utf8.RuneCountInString(full) <= cfg.EpisodeMaxChars {
+		return []EpisodePlan{{Text: full}}
+	}
+
+	var groups []EpisodePlan
+	level := seriesSplitLevel(doc)
+	if level == 0 {
+		groups = []EpisodePlan{{Text: full}}
+	} else {
+		cur := &StructuredDocument{}
+		title, titleLevel := "", 0
+		started, hasBody := false, false
+		flush := func() {
+			if text := cur.PlainText(); text != "" {
+				groups = append(groups, EpisodePlan{Title: title, Text: text})
+			}
+		}
+		for _, s := range doc.Sections {
+			// Phần mở đầu trước tiêu đề đầu tiên được gộp vào tập 1;
+			// tiêu đề chưa có nội dung (vd "Phần I" ngay trước "Chương 1") đi cùng tập kế tiếp
+			if s.Level > 0 && s.Level <= level {
+				if started && hasBody {
+					flush()
+					cur = &StructuredDocument{}
+					hasBody = false
+					title = ""
+				}
+				// Tiêu đề cùng cấp thay cho tiêu đề trước đó không có nội dung (chương rỗng)
+				if title == "" || s.Level == titleLevel {
+					title, titleLevel = s.Title, s.Level
+				}
+				started = true
+			}
+			cur.Sections = append(cur.Sections, s)
+			if started && len(s.Blocks) > 0 {
+				hasBody = true
+			}
+		}
+		flush()
+	}
+
+	var plans []EpisodePlan
+	for _, g := range mergeShortEpisodes(groups, cfg) {
+		plans = append(plans, splitLongEpisode(g, cfg.EpisodeMaxChars)...)
+	}
+	return plans
+}
+
+// seriesSplitLevel chọn cấp tiêu đề cao nhất xuất hiện ít nhất 2 lần (0 = không có tiêu đề để tách)
+func seriesSplitLevel(doc *StructuredDocument) int {
+	counts := map[int]int{}
+	for _, s := range doc.Sections {
+		if s.Level > 0 {
+			counts[s.Level]++
+		}
+	}
+	for level := 1; level <= 9; level++ {
+		if counts[level] >= 2 {
+			return level
+		}
+	}
+	return 0
+}
+
+func mergeShortEpisodes(groups []EpisodePlan, cfg config.SeriesConfig) []EpisodePlan {
+	var merged []EpisodePlan
+	for _, g := range groups {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			lastLen := utf8.RuneCountInString(last.Text)
+			if lastLen < cfg.EpisodeMinChars && lastLen+utf8.RuneCountInString(g.Text) <= cfg.EpisodeMaxChars {
+				last.Text += "\n\n" + g.Text
+				if last.Title == "" {
+					last.Title = g.Title
+				}
+				continue
+			}
+		}
+		merged = append(merged, g)
+	}
+
+	// Tập cuối quá ngắn thì gộp ngược vào tập trước
+	if n := len(merged); n > 1 {
+		last, prev := merged[n-1], &merged[n-2]
+		if utf8.RuneCountInString(last.Text) < cfg.EpisodeMinChars &&
+			utf8.RuneCountInString(prev.Text)+utf8.RuneCountInString(last.Text) <= cfg.EpisodeMaxChars {
+			prev.Text += "\n\n" + last.Text
+			merged = merged[:n-1]
+		}
+	}
+	return merged
+}
+
+// splitLongEpisode cắt tập dài hơn giới hạn theo ranh giới đoạn văn
+func splitLongEpisode(g EpisodePlan, maxChars int) []EpisodePlan {
+	if utf8.RuneCountInString(g.Text) <= maxChars {
+		return []EpisodePlan{g}
+	}
+
+	var parts []string
+	var cur strings.Builder
+	curLen := 0
+	for _, para := range strings.Split(g.Text, "\n\n") {
+		pieces := []string{para}
+		if utf8.RuneCountInString(para) > maxChars {
+			// Đoạn quá dài: cắt theo dấu câu
+			pieces = splitTextToChunksByByte(para, maxChars)
+		}
+		for _, p := range pieces {
+			pLen := utf8.RuneCountInString(p)
+			if curLen > 0 && curLen+2+pLen > maxChars {
+				parts = append(parts, cur.String())
+				cur.Reset()
+				curLen = 0
+			}
+			if curLen > 0 {
+				cur.WriteString("\n\n")
+				curLen += 2
+			}
+			cur.WriteString(p)
+			curLen += pLen
+		}
+	}
+	if cur.Len() > 0 {
+		parts = append(parts, cur.String())
+	}
+
+	plans := make([]EpisodePlan, 0, len(parts))
+	for i, p := range parts {
+		title := g.Title
+		if title != "" {
+			title = fmt.Sprintf("%s (phần %d)", g.Title, i+1)
+		}
+		plans = append(plans, EpisodePlan{Title: title, Text: p})
+	}
+	return plans
+}
+
+// saveEpisodePlan lưu các tập của tài liệu; tài liệu chỉ có 1 tập thì xử lý như podcast đơn
+func saveEpisodePlan(db *gorm.DB, doc *models.TaiLieu, plans []EpisodePlan) error {
+	soTap := len(plans)
+	if soTap <= 1 {
+		soTap = 0
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, p := range plans {
+			if soTap == 0 {
+				break
+			}
+			var ep models.TapTaiLieu
+			err := tx.Where("tai_lieu_id = ? AND so_tap = ?", doc.ID, i+1).First(&ep).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ep = models.TapTaiLieu{
+					ID:         uuid.New().String(),
+					TaiLieuID:  doc.ID,
+					SoTap:      i + 1,
+					TieuDe:     truncateRunes(p.Title, 255),
+					NoiDungTho: p.Text,
+				}
+				if err := tx.Create(&ep).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if ep.NoiDungTho == p.Text {
+				continue // Nội dung không đổi: giữ kết quả đã xử lý để chạy tiếp
+			}
+			// Nội dung tập thay đổi: xoá kết quả cũ, giữ PodcastID để cập nhật podcast
+			if err := tx.Model(&ep).Updates(map[string]interface{}{
+				"TieuDe":        truncateRunes(p.Title, 255),
+				"NoiDungTho":    p.Text,
+				"NoiDung":       "",
+				"TomTat":        "",
+				"CauChinh":      "",
+				"MauTomTat":     "",
+				"DuongDanAudio": "",
+				"ThoiLuongGiay": 0,
+				"ChuongMuc":     "",
+				"PhuDe":         "",
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		var extra []models.TapTaiLieu
+		tx.Where("tai_lieu_id = ? AND so_tap > ?", doc.ID, soTap).Find(&extra)
+		for _, ep := range extra {
+			if ep.PodcastID != "" {
+				log.Printf("Tài liệu %s: tập %d không còn sau khi tách lại, podcast %s giữ nguyên\n", doc.ID, ep.SoTap, ep.PodcastID)
+			}
+		}
+		if err := tx.Where("tai_lieu_id = ? AND so_tap > ?", doc.ID, soTap).Delete(&models.TapTaiLieu{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(doc).Update("SoTap", soTap).Error
+	})
+	if err != nil {
+		return fmt.Errorf("không lưu được danh sách tập: %w", err)
+	}
+	doc.SoTap = soTap
+	return nil
+}
+
+func loadEpisodes(db *gorm.DB, docID string) ([]models.TapTaiLieu, error) {
+	var eps []models.TapTaiLieu
+	err := db.Where("tai_lieu_id = ?", docID).Order("so_tap").Find(&eps).Error
+	return eps, err
+}
+
+// progressBetween chia đều tiến độ [from, to) cho n tập
+func progressBetween(from, to float64, i, n int) float64 {
+	if n <= 0 {
+		return from
+	}
+	return from + (to-from)*float64(i)/float64(n)
+}
+
+// runSeriesClean làm sạch từng tập (tập đã có kết quả được giữ lại khi thử lại)
+func runSeriesClean(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu) error {
+	eps, err := loadEpisodes(db, doc.ID)
+	if err != nil {
+		return err
+	}
+	force := isStageForced(job, StageClean)
+	pc := buildPromptContext(db, job, doc)
+
+	var texts []string
+	for i := range eps {
+		ep := &eps[i]
+		if ep.NoiDung == "" || force {
+			ws.SendStatusUpdate(doc.ID, fmt.Sprintf("Đang làm sạch tập %d/%d...", i+1, len(eps)), progressBetween(30, 40, i, len(eps)), "")
+			cleaned, source, err := CleanTextPipeline(ep.NoiDungTho, episodePromptContext(pc, ep), job.DinhDang)
+			if err != nil {
+				return fmt.Errorf("tập %d: %w", ep.SoTap, err)
+			}
+			ep.NoiDung = cleaned
+			doc.MauKichBan = source
+			db.Model(ep).Update("NoiDung", cleaned)
+		}
+		texts = append(texts, ep.NoiDung)
+	}
+
+	doc.NoiDungTrichXuat = strings.Join(texts, "\n\n")
+	doc.TrangThai = "Đã trích xuất"
+	db.Model(doc).Updates(map[string]interface{}{
+		"TrangThai":        doc.TrangThai,
+		"NoiDungTrichXuat": doc.NoiDungTrichXuat,
+		"MauKichBan":       doc.MauKichBan,
+	})
+	ws.SendStatusUpdate(doc.ID, "Đã trích xuất", 40, "")
+	ws.BroadcastDocumentListChanged()
+	return nil
+}
+
+// runSeriesSummary tạo tóm tắt riêng cho từng tập và tóm tắt chung cho cả tài liệu
+func runSeriesSummary(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu) error {
+	eps, err := loadEpisodes(db, doc.ID)
+	if err != nil {
+		return err
+	}
+	force := isStageForced(job, StageSummary)
+	pc := buildPromptContext(db, job, doc)
+
+	for i := range eps {
+		ep := &eps[i]
+		if ep.TomTat != "" && !force {
+			continue
+		}
+		ws.SendStatusUpdate(doc.ID, fmt.Sprintf("Đang tạo tóm tắt tập %d/%d...", i+1, len(eps)), progressBetween(45, 47, i, len(eps)), "")
+		summary, source, err := GenerateSummary(scriptPlainText(ep.NoiDung, job.DinhDang), episodePromptContext(pc, ep))
+		if err != nil {
+			return fmt.Errorf("tập %d: %w", ep.SoTap, err)
+		}
+		ep.TomTat = summary.Summary
+		ep.CauChinh = KeySentencesJSON(summary.KeySentences)
+		ep.MauTomTat = source
+		db.Model(ep).Updates(map[string]interface{}{
+			"TomTat":    ep.TomTat,
+			"CauChinh":  ep.CauChinh,
+			"MauTomTat": source,
+		})
+	}
+
+	if doc.TomTat == "" || force {
+		summary, source, err := GenerateSummary(scriptPlainText(doc.NoiDungTrichXuat, job.DinhDang), pc)
+		if err != nil {
+			return err
+		}
+		doc.TomTat = summary.Summary
+		doc.CauChinh = KeySentencesJSON(summary.KeySentences)
+		doc.MauTomTat = source
+	}
+	doc.TrangThai = "Đã xử lý AI"
+	db.Model(doc).Updates(map[string]interface{}{
+		"TrangThai": doc.TrangThai,
+		"TomTat":    doc.TomTat,
+		"CauChinh":  doc.CauChinh,
+		"MauTomTat": doc.MauTomTat,
+	})
+	ws.SendStatusUpdate(doc.ID, "Đã tạo tóm tắt", 47, "")
+	ws.BroadcastDocumentListChanged()
+	return nil
+}
+
+// runSeriesAudio tạo audio cho từng tập, mỗi tập 1 file riêng
+func runSeriesAudio(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu) error {
+	eps, err := loadEpisodes(db, doc.ID)
+	if err != nil {
+		return err
+	}
+	force := isStageForced(job, StageAudio)
+
+	for i := range eps {
+		ep := &eps[i]
+		if ep.DuongDanAudio != "" && !force {
+			continue
+		}
+		from, to := progressBetween(50, 70, i, len(eps)), progressBetween(50, 70, i+1, len(eps))
+		ws.SendStatusUpdate(doc.ID, fmt.Sprintf("Đang tạo audio tập %d/%d...", i+1, len(eps)), from, "")
+		result, err := synthesizeForJob(job, ep.NoiDung, func(done, total int) {
+			ws.SendStatusUpdate(doc.ID, fmt.Sprintf("Đang tạo audio tập %d/%d (%d/%d đoạn)...", i+1, len(eps), done, total), progressBetween(from, to, done, total), "")
+		})
+		if err != nil {
+			return fmt.Errorf("tập %d: %w", ep.SoTap, err)
+		}
+		reportTTSCache(job, result, to)
+
+		tags := audioTagsForJob(db, job, doc, ep)
+		tags.Chapters = result.Chapters
+		audioData := TagMP3(result.Audio, tags)
+
+		filename := fmt.Sprintf("%s-tap-%d.mp3", doc.ID, ep.SoTap)
+		if ep.DuongDanAudio != "" {
+			// Xử lý lại: không ghi đè file cũ để tránh CDN trả bản cache
+			filename = fmt.Sprintf("%s-tap-%d-%d.mp3", doc.ID, ep.SoTap, time.Now().Unix())
+		}
+		audioURL, err := utils.UploadBytesToSupabase(audioData, filename, "audio/mp3")
+		if err != nil {
+			return err
+		}
+		durationFloat, _ := GetMP3DurationFromBytes(audioData)
+
+		ep.DuongDanAudio = audioURL
+		ep.ThoiLuongGiay = int(durationFloat)
+		job.DaTaoAudio = true
+		ep.ChuongMuc = EncodeChapters(result.Chapters)
+		ep.PhuDe = EncodeTranscript(result.Transcript)
+		db.Model(ep).Updates(map[string]interface{}{
+			"DuongDanAudio": audioURL,
+			"ThoiLuongGiay": ep.ThoiLuongGiay,
+			"ChuongMuc":     ep.ChuongMuc,
+			"PhuDe":         ep.PhuDe,
+		})
+	}
+
+	// Audio của tài liệu trỏ tới tập 1 để các API cũ vẫn có audio để phát
+	if len(eps) > 0 && doc.DuongDanAudio != eps[0].DuongDanAudio {
+		doc.DuongDanAudio = eps[0].DuongDanAudio
+		db.Model(doc).Update("DuongDanAudio", doc.DuongDanAudio)
+	}
+	ws.SendStatusUpdate(doc.ID, "Đã lưu audio", 70, "")
+	return nil
+}
+
+// createSeriesPodcasts tạo podcast cho các tập chưa có, tập 1 dùng PodcastID cấp sẵn (chạy lại an toàn)
+func createSeriesPodcasts(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu) error {
+	eps, err := loadEpisodes(db, doc.ID)
+	if err != nil {
+		return err
+	}
+
+	created := 0
+	for i := range eps {
+		ep := &eps[i]
+		if ep.PodcastID != "" {
+			continue
+		}
+
+		podcastID := ""
+		if ep.SoTap == 1 {
+			podcastID = job.PodcastID
+			// Tài liệu trước đây là podcast đơn: podcast cũ trở thành tập 1
+			var single models.Podcast
+			if err := db.Where("tailieu_id = ? AND so_tap = 0", doc.ID).First(&single).Error; err == nil {
+				podcastID = single.ID
+			}
+		}
+		if podcastID == "" {
+			podcastID = uuid.New().String()
+		}
+
+		var count int64
+		db.Model(&models.Podcast{}).Where("id = ?", podcastID).Count(&count)
+		if count == 0 {
+			moTa := job.MoTa
+			if moTa == "" {
+				moTa = ep.TomTat
+			}
+			podcast := models.Podcast{
+				ID:             podcastID,
+				TailieuID:      doc.ID,
+				TieuDe:         seriesEpisodeTitle(job.TieuDe, ep),
+				MoTa:           moTa,
+				DuongDanAudio:  ep.DuongDanAudio,
+				ThoiLuongGiay:  ep.ThoiLuongGiay,
+				ChuongMuc:      ep.ChuongMuc,
+				PhuDe:          ep.PhuDe,
+				HinhAnhDaiDien: job.HinhAnhDaiDien,
+				DanhMucID:      job.DanhMucID,
+				TrangThai:      "Tắt",
+				NguoiTao:       job.NguoiTao,
+				TheTag:         job.TheTag,
+				SoTap:          ep.SoTap,
+				IsVIP:          true, // Podcast mới luôn là VIP (trong 7 ngày)
+				NgonNgu:        doc.NgonNgu,
+			}
+			if err := db.Create(&podcast).Error; err != nil {
+				return err
+			}
+			created++
+		} else {
+			db.Model(&models.Podcast{}).Where("id = ?", podcastID).Updates(map[string]interface{}{
+				"so_tap":          ep.SoTap,
+				"duong_dan_audio": ep.DuongDanAudio,
+				"thoi_luong_giay": ep.ThoiLuongGiay,
+				"chuong_muc":      ep.ChuongMuc,
+				"phu_de":          ep.PhuDe,
+			})
+		}
+
+		ep.PodcastID = podcastID
+		if err := db.Model(ep).Update("PodcastID", podcastID).Error; err != nil {
+			return err
+		}
+	}
+
+	if created > 0 {
+		message := fmt.Sprintf("Admin %s đã tạo series podcast: %s (%d tập)", job.NguoiTao, job.TieuDe, len(eps))
+		CreateNotification(job.NguoiTao, eps[0].PodcastID, "create_podcast", message)
+	}
+	return nil
+}
+
+// syncSeriesPodcastAudio cập nhật audio + thời lượng + mốc chương + phụ đề của podcast từng tập sau khi xử lý lại
+func syncSeriesPodcastAudio(db *gorm.DB, doc *models.TaiLieu) error {
+	eps, err := loadEpisodes(db, doc.ID)
+	if err != nil {
+		return err
+	}
+	for _, ep := range eps {
+		if ep.PodcastID == "" {
+			continue
+		}
+		if err := db.Model(&models.Podcast{}).
+			Where("id = ? AND duong_dan_audio <> ?", ep.PodcastID, ep.DuongDanAudio).
+			Updates(map[string]interface{}{
+				"duong_dan_audio": ep.DuongDanAudio,
+				"thoi_luong_giay": ep.ThoiLuongGiay,
+				"chuong_muc":      ep.ChuongMuc,
+				"phu_de":          ep.PhuDe,
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seriesEpisodeTitle: "Tên series - Tập 2: Tên chương"
+func seriesEpisodeTitle(base string, ep *models.TapTaiLieu) string {
+	title := fmt.Sprintf("Tập %d", ep.SoTap)
+	if base != "" {
+		title = base + " - " + title
+	}
+	if ep.TieuDe != "" {
+		title += ": " + ep.TieuDe
+	}
+	return truncateRunes(title, 255)
+}
+
+// episodePromptContext dùng tiêu đề của tập làm biến TieuDe
+func episodePromptContext(pc PromptContext, ep *models.TapTaiLieu) PromptContext {
+	pc.Vars.TieuDe = seriesEpisodeTitle(pc.Vars.TieuDe, ep)
+	return pc
+}
+
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
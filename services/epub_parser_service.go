@@ -0,0 +1,300 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Một chương trong sách EPUB theo thứ tự spine
+type EPUBChapter struct {
+	Title string
+	Text  string
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Title    string `xml:"metadata>title"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type epubNavPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Children []epubNavPoint `xml:"navPoint"`
+}
+
+type epubNCX struct {
+	NavPoints []epubNavPoint `xml:"navMap>navPoint"`
+}
+
+// ExtractTextFromEPUBBytes trích xuất văn bản từ file .epub, mỗi chương mở đầu bằng tiêu đề của nó
+func ExtractTextFromEPUBBytes(data []byte) (string, error) {
+	chapters, err := ExtractEPUBChapters(data)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, ch := range chapters {
+		if ch.Title != "" && !strings.HasPrefix(ch.Text, ch.Title) {
+			parts = append(parts, ch.Title)
+		}
+		parts = append(parts, ch.Text)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// StructuredFromEPUBChapters chuyển các chương EPUB thành tài liệu có cấu trúc, mỗi chương là 1 phần cấp 1
+func StructuredFromEPUBChapters(chapters []EPUBChapter) *StructuredDocument {
+	doc := &StructuredDocument{}
+	for _, ch := range chapters {
+		text := ch.Text
+		if ch.Title != "" {
+			doc.AddHeading(ch.Title, 1)
+			text = strings.TrimSpace(strings.TrimPrefix(text, ch.Title))
+		}
+		for _, para := range strings.Split(text, "\n\n") {
+			doc.AddBlock(DocBlock{Kind: BlockParagraph, Text: para})
+		}
+	}
+	return doc
+}
+
+// ExtractEPUBChapters đọc OPF spine và trả về các chương XHTML theo đúng thứ tự đọc
+func ExtractEPUBChapters(data []byte) ([]EPUBChapter, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("file epub không hợp lệ: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	// META-INF/container.xml chỉ đường dẫn tới file OPF
+	var container epubContainer
+	if err := readEPUBXML(files, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 || container.Rootfiles[0].FullPath == "" {
+		return nil, errors.New("epub không có rootfile")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+
+	var pkg epubPackage
+	if err := readEPUBXML(files, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	opfDir := path.Dir(opfPath)
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	mediaTypes := make(map[string]string, len(pkg.Manifest))
+	navPath := ""
+	for _, item := range pkg.Manifest {
+		p := resolveEPUBHref(opfDir, item.Href)
+		hrefs[item.ID] = p
+		mediaTypes[item.ID] = item.MediaType
+		if strings.Contains(" "+item.Properties+" ", " nav ") {
+			navPath = p
+		}
+	}
+
+	// Tiêu đề chương lấy từ mục lục (EPUB3 nav, hoặc NCX của EPUB2)
+	titles := map[string]string{}
+	if navPath != "" {
+		titles = readEPUBNavTitles(files, navPath)
+	}
+	if len(titles) == 0 && pkg.Spine.Toc != "" {
+		titles = readEPUBNCXTitles(files, hrefs[pkg.Spine.Toc])
+	}
+
+	var chapters []EPUBChapter
+	for _, ref := range pkg.Spine.ItemRefs {
+		p, ok := hrefs[ref.IDRef]
+		if !ok || ref.Linear == "no" || p == navPath {
+			continue
+		}
+		if mt := mediaTypes[ref.IDRef]; mt != "application/xhtml+xml" && mt != "text/html" {
+			continue
+		}
+
+		f, ok := files[p]
+		if !ok {
+			continue
+		}
+		root, err := parseEPUBHTML(f)
+		if err != nil {
+			return nil, err
+		}
+
+		body := htmlFindFirst(root, "body")
+		if body == nil {
+			body = root
+		}
+		text := htmlToText(body)
+		if text == "" {
+			continue
+		}
+
+		title := titles[p]
+		if title == "" {
+			if h := htmlFindFirst(body, "h1", "h2", "h3"); h != nil {
+				title = htmlInlineText(h)
+			}
+		}
+		chapters = append(chapters, EPUBChapter{Title: title, Text: text})
+	}
+
+	if len(chapters) == 0 {
+		return nil, errors.New("epub không có nội dung đọc được")
+	}
+	return chapters, nil
+}
+
+func readEPUBXML(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("epub thiếu file %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	dec := xml.NewDecoder(rc)
+	dec.Strict = false
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("không đọc được %s: %w", name, err)
+	}
+	return nil
+}
+
+func parseEPUBHTML(f *zip.File) (*html.Node, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return html.Parse(bytes.NewReader(data))
+}
+
+// readEPUBNavTitles đọc <nav epub:type="toc"> của EPUB3: href -> tiêu đề
+func readEPUBNavTitles(files map[string]*zip.File, navPath string) map[string]string {
+	titles := map[string]string{}
+	f, ok := files[navPath]
+	if !ok {
+		return titles
+	}
+	root, err := parseEPUBHTML(f)
+	if err != nil {
+		return titles
+	}
+
+	var toc *html.Node
+	var findToc func(*html.Node)
+	findToc = func(n *html.Node) {
+		if toc != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "nav" &&
+			(htmlAttr(n, "epub:type") == "toc" || htmlAttr(n, "role") == "doc-toc") {
+			toc = n
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			findToc(c)
+		}
+	}
+	findToc(root)
+	if toc == nil {
+		return titles
+	}
+
+	navDir := path.Dir(navPath)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			p := resolveEPUBHref(navDir, htmlAttr(n, "href"))
+			if _, exists := titles[p]; !exists && p != "" {
+				titles[p] = htmlInlineText(n)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(toc)
+	return titles
+}
+
+// readEPUBNCXTitles đọc toc.ncx của EPUB2: src -> tiêu đề
+func readEPUBNCXTitles(files map[string]*zip.File, ncxPath string) map[string]string {
+	titles := map[string]string{}
+	var ncx epubNCX
+	if ncxPath == "" || readEPUBXML(files, ncxPath, &ncx) != nil {
+		return titles
+	}
+
+	ncxDir := path.Dir(ncxPath)
+	var walk func([]epubNavPoint)
+	walk = func(points []epubNavPoint) {
+		for _, np := range points {
+			p := resolveEPUBHref(ncxDir, np.Content.Src)
+			if _, exists := titles[p]; !exists && p != "" {
+				titles[p] = strings.Join(strings.Fields(np.Label), " ")
+			}
+			walk(np.Children)
+		}
+	}
+	walk(ncx.NavPoints)
+	return titles
+}
+
+// resolveEPUBHref chuyển href tương đối (có thể URL-encode, có #fragment) thành đường dẫn trong zip
+func resolveEPUBHref(baseDir, href string) string {
+	if i := strings.Index(href, "#"); i >= 0 {
+		href = href[:i]
+	}
+	if href == "" {
+		return ""
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return strings.TrimPrefix(path.Join(baseDir, href), "./")
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Các thẻ tạo ngắt đoạn khi chuyển HTML sang văn bản
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"li": true, "blockquote": true, "pre": true, "tr": true, "br": true, "hr": true,
+	"dt": true, "dd": true, "figcaption": true, "header": true, "footer": true,
+	"table": true, "ul": true, "ol": true, "aside": true,
+}
+
+// Các thẻ không chứa nội dung đọc được
+var htmlSkipTags = map[string]bool{
+	"script": true, "style": true, "head": true, "noscript": true,
+	"template": true, "svg": true, "iframe": true, "object": true,
+}
+
+// htmlToText lấy văn bản của node, mỗi khối (đoạn, tiêu đề, mục liệt kê) thành 1 đoạn riêng
+func htmlToText(n *html.Node) string {
+	var buf strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			buf.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if htmlSkipTags[n.Data] {
+				return
+			}
+			if htmlBlockTags[n.Data] {
+				buf.WriteString("\n")
+				defer buf.WriteString("\n")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	var paragraphs []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// htmlInlineText lấy văn bản của node trên 1 dòng (dùng cho tiêu đề, link)
+func htmlInlineText(n *html.Node) string {
+	var buf strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+			buf.WriteString(" ")
+		}
+		if n.Type == html.ElementNode && htmlSkipTags[n.Data] {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// htmlFindFirst tìm node đầu tiên (duyệt theo thứ tự tài liệu) có tên thẻ thuộc tags
+func htmlFindFirst(n *html.Node, tags ...string) *html.Node {
+	if n.Type == html.ElementNode {
+		for _, t := range tags {
+			if n.Data == t {
+				return n
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := htmlFindFirst(c, tags...); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// htmlAttr trả về giá trị thuộc tính của node, rỗng nếu không có
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// htmlAttrOK giống htmlAttr nhưng cho biết thuộc tính có tồn tại không (vd thuộc tính boolean hidden)
+func htmlAttrOK(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -1,16 +1,23 @@
 package services
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 // Hàm ánh xạ phần mở rộng file sang InputType
 func GetInputTypeFromExt(ext string) (InputType, error) {
-	switch ext {
+	switch strings.ToLower(ext) {
 	case ".pdf":
 		return InputPDF, nil
 	case ".docx":
 		return InputDOCX, nil
 	case ".txt":
 		return InputTXT, nil
+	case ".epub":
+		return InputEPUB, nil
+	case ".mp3", ".wav":
+		return InputAudio, nil
 	default:
 		return "", errors.New("định dạng file không hỗ trợ")
 	}
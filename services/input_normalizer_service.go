@@ -1,8 +1,12 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"io"
 	"mime/multipart"
+
+	"github.com/Huong3203/APIPodcast/config"
 )
 
 // Định nghĩa loại input
@@ -13,14 +17,18 @@ const (
 	InputTXT   InputType = "txt"
 	InputDOCX  InputType = "docx"
 	InputPDF   InputType = "pdf"
-	InputAudio InputType = "audio" // Dành cho bước sau (nếu cần tích hợp Speech-to-Text)
+	InputEPUB  InputType = "epub"
+	InputURL   InputType = "url"
+	InputAudio InputType = "audio" // File ghi âm mp3/wav, chuyển thành văn bản bằng Speech-to-Text
 )
 
 // Struct đại diện cho nguồn input
 type InputSource struct {
 	Type       InputType
 	FileHeader *multipart.FileHeader // Nếu là file (txt, docx, pdf, audio)
+	Data       []byte                // Nội dung file đã tải sẵn (worker nền), dùng khi không có FileHeader
 	Text       string                // Nếu người dùng nhập tay
+	URL        string                // Nếu là bài viết trên web
 }
 
 // Hàm xử lý input thành plain text
@@ -30,20 +38,101 @@ func NormalizeInput(input InputSource) (string, error) {
 		return input.Text, nil
 
 	case InputTXT:
-		return ExtractTextFromTXT(input.FileHeader)
+		data, err := readInputData(input)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
 
 	case InputPDF:
-		f, err := input.FileHeader.Open()
+		data, err := readInputData(input)
 		if err != nil {
 			return "", err
 		}
-		defer f.Close()
-		return ExtractTextFromPDF(f)
+		return ExtractTextFromPDFBytes(data)
 
 	case InputDOCX:
-		return ExtractTextFromDOCX(input.FileHeader)
+		data, err := readInputData(input)
+		if err != nil {
+			return "", err
+		}
+		return ExtractTextFromDOCXBytes(data)
+
+	case InputEPUB:
+		data, err := readInputData(input)
+		if err != nil {
+			return "", err
+		}
+		return ExtractTextFromEPUBBytes(data)
+
+	case InputURL:
+		cfg := config.GetURLFetchConfig()
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		defer cancel()
+		article, err := NewArticleFetcher(cfg).Fetch(ctx, input.URL)
+		if err != nil {
+			return "", err
+		}
+		if article.Title == "" {
+			return article.Text, nil
+		}
+		return article.Title + "\n\n" + article.Text, nil
+
+	case InputAudio:
+		data, err := readInputData(input)
+		if err != nil {
+			return "", err
+		}
+		return TranscribeAudio(data)
 
 	default:
 		return "", errors.New("loại input không được hỗ trợ")
 	}
 }
+
+// NormalizeInputStructured giống NormalizeInput nhưng giữ cấu trúc chương/mục (dùng để tách tập)
+func NormalizeInputStructured(input InputSource) (*StructuredDocument, error) {
+	switch input.Type {
+	case InputDOCX:
+		data, err := readInputData(input)
+		if err != nil {
+			return nil, err
+		}
+		return ParseDOCXStructured(data)
+
+	case InputEPUB:
+		data, err := readInputData(input)
+		if err != nil {
+			return nil, err
+		}
+		chapters, err := ExtractEPUBChapters(data)
+		if err != nil {
+			return nil, err
+		}
+		return StructuredFromEPUBChapters(chapters), nil
+
+	default:
+		text, err := NormalizeInput(input)
+		if err != nil {
+			return nil, err
+		}
+		return StructuredFromPlainText(text), nil
+	}
+}
+
+// readInputData lấy nội dung file từ FileHeader hoặc Data
+func readInputData(input InputSource) ([]byte, error) {
+	if input.FileHeader == nil {
+		if input.Data == nil {
+			return nil, errors.New("không có dữ liệu file")
+		}
+		return input.Data, nil
+	}
+
+	f, err := input.FileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Nhận diện ngôn ngữ offline theo n-gram ký tự: tần suất n-gram 1-3 ký tự của văn bản mẫu từng ngôn ngữ
+// cho xác suất mỗi n-gram (làm trơn Laplace), ngôn ngữ có tổng log xác suất các n-gram của văn bản lớn nhất được chọn
+
+const (
+	minDetectLetters = 20    // Văn bản ít chữ cái hơn thì không đủ để nhận diện
+	maxDetectRunes   = 20000 // Chỉ xét phần đầu văn bản dài
+	// Chênh lệch log xác suất trung bình mỗi n-gram giữa ngôn ngữ tốt nhất và thứ 2, nhỏ hơn thì coi là không chắc chắn
+	minDetectMargin = 0.03
+
+	// Đoạn văn chỉ được đọc bằng giọng khác ngôn ngữ của tài liệu khi đủ dài và chắc chắn hơn hẳn:
+	// tên riêng, thuật ngữ nước ngoài trong câu tiếng Việt dễ bị nhận nhầm với chênh lệch 0.1-0.2
+	minSwitchLetters = 60
+	minSwitchMargin  = 0.25
+)
+
+// Văn bản mẫu dựng hồ sơ n-gram của từng ngôn ngữ
+var languageSamples = map[string]string{
+	"vi": `Việt Nam là một quốc gia nằm ở phía đông bán đảo Đông Dương, có đường bờ biển dài và nhiều cảnh quan thiên nhiên đẹp.
+	Người dân Việt Nam có truyền thống hiếu học, coi trọng gia đình và tôn trọng người lớn tuổi. Trong những năm gần đây,
+	nền kinh tế phát triển nhanh chóng, đời sống của người dân ngày càng được cải thiện. Các thành phố lớn như Hà Nội,
+	Thành phố Hồ Chí Minh và Đà Nẵng thu hút rất nhiều khách du lịch trong và ngoài nước. Ẩm thực Việt Nam nổi tiếng với
+	phở, bánh mì, bún chả và nhiều món ăn dân dã khác. Trong tập này, chúng ta sẽ cùng tìm hiểu những điều thú vị về lịch sử,
+	văn hoá và con người của đất nước hình chữ S. Học sinh được khuyến khích đọc sách mỗi ngày để mở rộng hiểu biết,
+	rèn luyện tư duy và phát triển kỹ năng giao tiếp. Công nghệ thông tin đang thay đổi cách chúng ta làm việc, học tập
+	và giải trí. Những người trẻ tuổi luôn sẵn sàng tiếp nhận cái mới nhưng vẫn giữ gìn bản sắc dân tộc. Nếu bạn có thắc mắc,
+	hãy để lại câu hỏi để chúng tôi giải đáp trong những số tiếp theo. Cảm ơn các bạn đã lắng nghe và hẹn gặp lại.`,
+
+	"en": `The United Kingdom is an island nation in northwestern Europe with a long history and a rich cultural heritage.
+	People there have a strong tradition of education, and many of the oldest universities in the world are still teaching
+	students today. In recent years the economy has changed quickly, and technology is transforming the way we work, learn
+	and spend our free time. Large cities such as London, Manchester and Edinburgh attract millions of visitors from around
+	the world every year. In this episode we will explore some interesting facts about the history, the culture and the people
+	of the country. Students are encouraged to read books every day in order to broaden their knowledge, think more clearly
+	and improve their communication skills. Young people are always ready to embrace new ideas while still keeping their
+	traditions alive. If you have any questions, please leave them below and we will answer them in the next show. The report
+	shows that the number of companies which were using these tools has grown, although there is still a lot of work to do.
+	Thank you for listening, and we hope you will join us again next time.`,
+
+	"fr": `La France est un pays situé en Europe de l'Ouest, connu pour son histoire, sa langue et son patrimoine culturel.
+	Les habitants ont une longue tradition d'éducation et de nombreuses universités accueillent des étudiants du monde entier.
+	Ces dernières années, l'économie a beaucoup changé et la technologie transforme la façon dont nous travaillons, apprenons
+	et passons notre temps libre. Les grandes villes comme Paris, Lyon et Marseille attirent chaque année des millions de
+	visiteurs. Dans cet épisode, nous allons découvrir des faits intéressants sur l'histoire, la culture et les habitants
+	du pays. Les élèves sont encouragés à lire des livres tous les jours afin d'élargir leurs connaissances et d'améliorer
+	leur façon de communiquer. Les jeunes sont toujours prêts à accepter de nouvelles idées tout en gardant leurs traditions.
+	Si vous avez des questions, laissez-les ci-dessous et nous y répondrons dans la prochaine émission. Merci de nous avoir
+	écoutés et à bientôt.`,
+
+	"de": `Deutschland ist ein Land in der Mitte Europas, das für seine Geschichte, seine Sprache und sein kulturelles Erbe
+	bekannt ist. Die Menschen haben eine lange Tradition der Bildung, und viele Universitäten empfangen Studenten aus der
+	ganzen Welt. In den letzten Jahren hat sich die Wirtschaft schnell verändert, und die Technik verändert die Art und Weise,
+	wie wir arbeiten, lernen und unsere Freizeit verbringen. Große Städte wie Berlin, Hamburg und München ziehen jedes Jahr
+	Millionen von Besuchern an. In dieser Folge erfahren wir interessante Dinge über die Geschichte, die Kultur und die
+	Menschen des Landes. Schüler werden ermutigt, jeden Tag Bücher zu lesen, um ihr Wissen zu erweitern und besser zu
+	kommunizieren. Junge Menschen sind immer bereit, neue Ideen aufzunehmen, und bewahren dennoch ihre Traditionen. Wenn Sie
+	Fragen haben, schreiben Sie uns, und wir beantworten sie in der nächsten Sendung. Vielen Dank fürs Zuhören und bis bald.`,
+
+	"es": `España es un país situado en el suroeste de Europa, conocido por su historia, su lengua y su patrimonio cultural.
+	Sus habitantes tienen una larga tradición educativa y muchas universidades reciben a estudiantes de todo el mundo.
+	En los últimos años la economía ha cambiado rápidamente y la tecnología está transformando la manera en que trabajamos,
+	aprendemos y pasamos nuestro tiempo libre. Las grandes ciudades como Madrid, Barcelona y Sevilla atraen cada año a
+	millones de visitantes. En este episodio vamos a conocer datos interesantes sobre la historia, la cultura y la gente
+	del país. Se anima a los alumnos a leer libros todos los días para ampliar sus conocimientos y mejorar su forma de
+	comunicarse. Los jóvenes siempre están dispuestos a aceptar nuevas ideas sin dejar de lado sus tradiciones. Si tienen
+	alguna pregunta, déjenla abajo y la responderemos en el próximo programa. Gracias por escucharnos y hasta pronto.`,
+}
+
+// Mã vùng mặc định của mỗi ngôn ngữ, dùng để chọn giọng đọc
+var languageLocales = map[string]string{
+	"vi": "vi-VN",
+	"en": "en-US",
+	"fr": "fr-FR",
+	"de": "de-DE",
+	"es": "es-ES",
+}
+
+// Tên ngôn ngữ ghi vào prompt
+var languageNames = map[string]string{
+	"vi": "tiếng Việt",
+	"en": "tiếng Anh",
+	"fr": "tiếng Pháp",
+	"de": "tiếng Đức",
+	"es": "tiếng Tây Ban Nha",
+}
+
+// Tần suất n-gram của 1 ngôn ngữ
+type ngramModel struct {
+	counts map[string]int
+	total  int
+}
+
+var (
+	languageModels     map[string]ngramModel
+	languageVocabulary int // Số n-gram khác nhau của mọi văn bản mẫu, dùng khi làm trơn
+	languageModelsOnce sync.Once
+)
+
+// 1 đoạn liên tiếp cùng ngôn ngữ trong văn bản nhiều ngôn ngữ
+type languageRun struct {
+	lang string
+	text string
+}
+
+// DetectLanguage trả về mã ngôn ngữ ISO 639-1 ("vi", "en"...) của văn bản,
+// "" nếu văn bản quá ngắn hoặc không đủ chắc chắn
+func DetectLanguage(text string) string {
+	lang, margin, _ := detectLanguageScore(text)
+	if margin < minDetectMargin {
+		return ""
+	}
+	return lang
+}
+
+// detectLanguageScore trả về ngôn ngữ có điểm cao nhất, chênh lệch điểm với ngôn ngữ thứ 2 và số chữ cái của văn bản
+// (lang rỗng nếu quá ít chữ cái)
+func detectLanguageScore(text string) (string, float64, int) {
+	languageModelsOnce.Do(func() {
+		languageModels = make(map[string]ngramModel, len(languageSamples))
+		vocabulary := map[string]bool{}
+		for lang, sample := range languageSamples {
+			m := ngramModel{counts: ngramCounts(sample)}
+			for gram, n := range m.counts {
+				m.total += n
+				vocabulary[gram] = true
+			}
+			languageModels[lang] = m
+		}
+		languageVocabulary = len(vocabulary)
+	})
+
+	if utf8.RuneCountInString(text) > maxDetectRunes {
+		text = string([]rune(text)[:maxDetectRunes])
+	}
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < minDetectLetters {
+		return "", 0, letters
+	}
+
+	counts := ngramCounts(text)
+	grams := 0
+	for _, n := range counts {
+		grams += n
+	}
+	best, bestScore, secondScore := "", math.Inf(-1), math.Inf(-1)
+	// Duyệt theo thứ tự cố định để kết quả không phụ thuộc thứ tự map
+	langs := make([]string, 0, len(languageModels))
+	for lang := range languageModels {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		m := languageModels[lang]
+		denom := math.Log(float64(m.total + languageVocabulary))
+		var score float64
+		for gram, n := range counts {
+			score += float64(n) * (math.Log(float64(m.counts[gram]+1)) - denom)
+		}
+		score /= float64(grams)
+		switch {
+		case score > bestScore:
+			secondScore, bestScore, best = bestScore, score, lang
+		case score > secondScore:
+			secondScore = score
+		}
+	}
+	return best, bestScore - secondScore, letters
+}
+
+// ngramCounts đếm các n-gram 1-3 ký tự của từng từ (viết thường, ký tự "_" đánh dấu đầu/cuối từ)
+func ngramCounts(text string) map[string]int {
+	counts := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		runes := []rune("_" + word + "_")
+		for n := 1; n <= 3; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				if gram := string(runes[i : i+n]); gram != "_" {
+					counts[gram]++
+				}
+			}
+		}
+	}
+	return counts
+}
+
+// splitLanguageRuns nhóm các đoạn văn liên tiếp cùng ngôn ngữ. fallback là ngôn ngữ của tài liệu: đoạn chỉ chuyển sang
+// ngôn ngữ khác khi đủ dài, chênh lệch điểm lớn và không có chữ tiếng Việt có dấu (câu tiếng Việt nhiều tên riêng).
+// Đoạn không đủ chắc chắn (tiêu đề, câu ngắn) theo ngôn ngữ của đoạn trước, đoạn đầu tiên mặc định là fallback
+func splitLanguageRuns(text, fallback string) []languageRun {
+	var runs []languageRun
+	for _, para := range plainParagraphSplitRe.Split(text, -1) {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		lang, margin, letters := detectLanguageScore(para)
+		vietnamese := hasVietnameseLetters(para)
+		switch {
+		case lang == fallback && margin >= minDetectMargin:
+		case fallback == "vi" && vietnamese:
+			lang = fallback
+		case lang != "" && letters >= minSwitchLetters && margin >= minSwitchMargin && !(vietnamese && lang != "vi"):
+		default:
+			lang = fallback
+			if len(runs) > 0 {
+				lang = runs[len(runs)-1].lang
+			}
+		}
+		if len(runs) > 0 && runs[len(runs)-1].lang == lang {
+			runs[len(runs)-1].text += "\n\n" + para
+			continue
+		}
+		runs = append(runs, languageRun{lang: lang, text: para})
+	}
+	return runs
+}
+
+// hasVietnameseLetters cho biết văn bản có chữ cái chỉ tiếng Việt dùng (đ, ă, ơ, ư, nguyên âm mang dấu thanh như ạ, ế, ữ)
+func hasVietnameseLetters(text string) bool {
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == 'đ', r == 'ă', r == 'ơ', r == 'ư', r == 'ĩ', r == 'ũ':
+			return true
+		case r >= 0x1EA0 && r <= 0x1EF9: // Latin Extended Additional: ạ ả ấ ... ỹ
+			return true
+		}
+	}
+	return false
+}
+
+// LanguageName trả về tên ngôn ngữ để ghi vào prompt (mã lạ thì giữ nguyên mã)
+func LanguageName(lang string) string {
+	if name, ok := languageNames[lang]; ok {
+		return name
+	}
+	return lang
+}
+
+// VoiceLanguage lấy mã ngôn ngữ 2 chữ từ tên giọng ("en-US-Chirp3-HD-Puck" -> "en")
+func VoiceLanguage(voice string) string {
+	return baseLanguage(voiceLanguageCode(voice))
+}
+
+// baseLanguage bỏ mã vùng: "en-US" -> "en"
+func baseLanguage(code string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(code), "-")
+	return lang
+}
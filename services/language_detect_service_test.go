@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Hôm nay chúng ta sẽ cùng tìm hiểu về lịch sử và văn hoá của Hà Nội qua nhiều thế kỷ.", "vi"},
+		{"Machine learning is a field of study in artificial intelligence concerned with statistical algorithms.", "en"},
+		{"Bonjour à tous, aujourd'hui nous parlons de la cuisine française et de ses traditions.", "fr"},
+		{"Heute sprechen wir über die Geschichte der Stadt und die Menschen, die dort leben.", "de"},
+		{"Hoy vamos a hablar de la historia de la ciudad y de la gente que vive allí.", "es"},
+		{"Xin chào", ""}, // Quá ngắn
+		{"12345 67890 !!!", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, muốn %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitLanguageRuns(t *testing.T) {
+	const english = "Machine learning is a field of study in artificial intelligence concerned with the development of statistical algorithms that can learn from data."
+	tests := []struct {
+		name     string
+		text     string
+		fallback string
+		want     []string // Ngôn ngữ của từng run
+	}{
+		{
+			name:     "tên riêng tiếng Anh trong câu tiếng Việt",
+			text:     "Apple vừa ra mắt iPhone 16 Pro Max với camera mới và pin lớn hơn.\n\nManchester United đã thắng Liverpool với tỉ số 2-1 ở Old Trafford.",
+			fallback: "vi",
+			want:     []string{"vi"},
+		},
+		{
+			name:     "danh sách tên riêng không dấu",
+			text:     "Các mẫu điện thoại được nhắc tới trong bài:\n\nApple iPhone 16 Pro Max, Samsung Galaxy S24 Ultra, Google Pixel 9 Pro",
+			fallback: "vi",
+			want:     []string{"vi"},
+		},
+		{
+			name:     "câu trích dẫn tiếng Anh ngắn",
+			text:     "Ông nói một câu nổi tiếng:\n\nStay hungry, stay foolish.\n\nCâu nói này được nhiều người nhắc lại.",
+			fallback: "vi",
+			want:     []string{"vi"},
+		},
+		{
+			name:     "đoạn tiếng Anh dài rồi quay lại tiếng Việt",
+			text:     "Sau đây là định nghĩa gốc bằng tiếng Anh.\n\n" + english + "\n\nNói cách khác, máy học từ dữ liệu.",
+			fallback: "vi",
+			want:     []string{"vi", "en", "vi"},
+		},
+		{
+			name:     "tiêu đề ngắn theo đoạn trước",
+			text:     english + "\n\nSummary\n\n" + english,
+			fallback: "vi",
+			want:     []string{"en"},
+		},
+		{
+			name:     "tài liệu tiếng Anh có tên tiếng Việt",
+			text:     english + "\n\nThe study was led by Nguyễn Văn An at a university in Hà Nội.",
+			fallback: "en",
+			want:     []string{"en"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := splitLanguageRuns(tt.text, tt.fallback)
+			got := make([]string, len(runs))
+			for i, r := range runs {
+				got[i] = r.lang
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("runs = %v, muốn %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("runs = %v, muốn %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// localVoiceEngine giả lập engine có tên giọng không theo dạng của Google
+type localVoiceEngine struct{ FakeTTSEngine }
+
+func (*localVoiceEngine) Name() string { return "local-test" }
+
+func (*localVoiceEngine) Voices(ctx context.Context) ([]TTSVoice, error) {
+	return []TTSVoice{{Name: "en", LanguageCode: "en-GB"}, {Name: "vi", LanguageCode: "vi-VN"}}, nil
+}
+
+func TestVoiceLanguageCodeFromEngine(t *testing.T) {
+	useTestTTSEngine(t, &localVoiceEngine{})
+
+	tests := []struct {
+		voice, want string
+	}{
+		{"en", "en-GB"},
+		{"vi", "vi-VN"},
+		{"fr-FR-Neural2-A", "fr-FR"}, // Không có trong danh sách: tách từ tên
+	}
+	for _, tt := range tests {
+		if got := voiceLanguageCode(tt.voice); got != tt.want {
+			t.Errorf("voiceLanguageCode(%q) = %q, muốn %q", tt.voice, got, tt.want)
+		}
+	}
+	if got := VoiceLanguage("en"); got != "en" {
+		t.Errorf(`VoiceLanguage("en") = %q`, got)
+	}
+}
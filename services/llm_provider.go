@@ -0,0 +1,456 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Yêu cầu gửi tới LLM: chỉ dẫn (prompt) và văn bản cần xử lý tách riêng để provider giả lập dùng lại được
+type LLMRequest struct {
+	Prompt string
+	Input  string
+}
+
+// Số token đã dùng cho 1 lần gọi
+type LLMUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type LLMResponse struct {
+	Text      string
+	Model     string
+	Usage     LLMUsage
+	Truncated bool // Câu trả lời bị cắt vì hết giới hạn token đầu ra
+}
+
+// LLMProvider là 1 nhà cung cấp mô hình ngôn ngữ (Gemini, API tương thích OpenAI, giả lập)
+type LLMProvider interface {
+	Name() string
+	Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error)
+}
+
+// Lỗi HTTP từ provider, dùng để quyết định có thử lại hay không
+type LLMStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *LLMStatusError) Error() string {
+	return fmt.Sprintf("LLM trả về status %d: %s", e.StatusCode, e.Body)
+}
+
+var (
+	defaultLLM   LLMProvider
+	defaultLLMMu sync.Mutex
+)
+
+// DefaultLLM trả về provider theo cấu hình LLM_*, dùng chung cho cả tiến trình.
+// Chỉ lưu provider tạo thành công: lỗi cấu hình được thử lại ở lần gọi sau thay vì lỗi mãi đến khi khởi động lại
+func DefaultLLM() (LLMProvider, error) {
+	defaultLLMMu.Lock()
+	defer defaultLLMMu.Unlock()
+	if defaultLLM != nil {
+		return defaultLLM, nil
+	}
+	llm, err := NewLLMProvider(config.GetLLMConfig())
+	if err != nil {
+		return nil, err
+	}
+	defaultLLM = llm
+	return llm, nil
+}
+
+// NewLLMProvider tạo provider kèm timeout, thử lại và ghi nhận token theo cấu hình
+func NewLLMProvider(cfg config.LLMConfig) (LLMProvider, error) {
+	var base LLMProvider
+	model := cfg.Model
+	switch cfg.Provider {
+	case "gemini", "":
+		if cfg.GeminiAPIKey == "" {
+			return nil, errors.New("GEMINI_API_KEY chưa được cấu hình")
+		}
+		if model == "" {
+			model = "gemini-2.0-flash"
+		}
+		base = &GeminiProvider{APIKey: cfg.GeminiAPIKey, Model: model, Temperature: cfg.Temperature, MaxOutputTokens: cfg.MaxOutputTokens}
+	case "openai":
+		if cfg.OpenAIAPIKey == "" {
+			return nil, errors.New("OPENAI_API_KEY chưa được cấu hình")
+		}
+		if model == "" {
+			model = "gpt-4o-mini"
+		}
+		base = &OpenAIProvider{
+			BaseURL:         strings.TrimRight(cfg.OpenAIBaseURL, "/"),
+			APIKey:          cfg.OpenAIAPIKey,
+			Model:           model,
+			Temperature:     cfg.Temperature,
+			MaxOutputTokens: cfg.MaxOutputTokens,
+			Client:          &http.Client{},
+		}
+	case "fake":
+		model = "fake"
+		base = &FakeLLMProvider{}
+	default:
+		return nil, fmt.Errorf("LLM_PROVIDER không hợp lệ: %s", cfg.Provider)
+	}
+
+	return &retryingLLM{
+		inner:       base,
+		model:       model,
+		timeout:     cfg.Timeout,
+		maxRetries:  cfg.MaxRetries,
+		baseBackoff: cfg.BaseBackoff,
+		trackUsage:  cfg.TrackUsage,
+	}, nil
+}
+
+// LLMGenerateText gọi provider mặc định và trả về văn bản kết quả
+func LLMGenerateText(ctx context.Context, prompt, input string) (string, error) {
+	llm, err := DefaultLLM()
+	if err != nil {
+		return "", err
+	}
+	resp, err := llm.Generate(ctx, LLMRequest{Prompt: prompt, Input: input})
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+func fullPrompt(req LLMRequest) string {
+	if req.Input == "" {
+		return req.Prompt
+	}
+	return req.Prompt + "\n\n" + req.Input
+}
+
+// ---------------- Gemini ----------------
+
+// GeminiProvider dùng chung 1 client cho mọi lần gọi thay vì tạo client mới mỗi lần
+type GeminiProvider struct {
+	APIKey          string
+	Model           string
+	Temperature     float32
+	MaxOutputTokens int
+
+	mu     sync.Mutex
+	client *genai.Client
+}
+
+func (g *GeminiProvider) Name() string { return "gemini" }
+
+// Client tạo 1 lần và dùng chung (tạo lại ở lần gọi sau nếu lần trước lỗi)
+func (g *GeminiProvider) getClient() (*genai.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.client != nil {
+		return g.client, nil
+	}
+	// Client sống cùng tiến trình nên không gắn với ctx của từng request
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey(g.APIKey))
+	if err != nil {
+		return nil, err
+	}
+	g.client = client
+	return client, nil
+}
+
+func (g *GeminiProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	client, err := g.getClient()
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo Gemini client: %w", err)
+	}
+
+	model := client.GenerativeModel(g.Model)
+	model.SetTemperature(g.Temperature)
+	if g.MaxOutputTokens > 0 {
+		model.SetMaxOutputTokens(int32(g.MaxOutputTokens))
+	}
+
+	resp, err := model.GenerateContent(ctx, genai.Text(fullPrompt(req)))
+	if err != nil {
+		return nil, fmt.Errorf("lỗi Gemini xử lý: %w", err)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return nil, errors.New("gemini không trả kết quả hợp lệ")
+	}
+
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if t, ok := part.(genai.Text); ok {
+			text.WriteString(string(t))
+		}
+	}
+
+	out := &LLMResponse{Text: text.String(), Model: g.Model, Truncated: resp.Candidates[0].FinishReason == genai.FinishReasonMaxTokens}
+	if u := resp.UsageMetadata; u != nil {
+		out.Usage = LLMUsage{
+			PromptTokens:     int(u.PromptTokenCount),
+			CompletionTokens: int(u.CandidatesTokenCount),
+			TotalTokens:      int(u.TotalTokenCount),
+		}
+	}
+	return out, nil
+}
+
+// ---------------- OpenAI-compatible ----------------
+
+// OpenAIProvider gọi endpoint /chat/completions (OpenAI, Azure proxy, vLLM, Ollama...)
+type OpenAIProvider struct {
+	BaseURL         string
+	APIKey          string
+	Model           string
+	Temperature     float32
+	MaxOutputTokens int
+	Client          *http.Client
+}
+
+type openAIChatRequest struct {
+	Model       string              `json:"model"`
+	Messages    []openAIChatMessage `json:"messages"`
+	Temperature float32             `json:"temperature"`
+	MaxTokens   int                 `json:"max_tokens,omitempty"`
+}
+
+type openAIChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openAIChatMessage `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func (o *OpenAIProvider) Name() string { return "openai" }
+
+func (o *OpenAIProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	payload, err := json.Marshal(openAIChatRequest{
+		Model:       o.Model,
+		Messages:    []openAIChatMessage{{Role: "user", Content: fullPrompt(req)}},
+		Temperature: o.Temperature,
+		MaxTokens:   o.MaxOutputTokens,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.BaseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+o.APIKey)
+
+	resp, err := o.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi gọi LLM: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &LLMStatusError{StatusCode: resp.StatusCode, Body: truncateRunes(string(body), 500)}
+	}
+
+	var out openAIChatResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("không đọc được phản hồi LLM: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return nil, errors.New("LLM không trả kết quả hợp lệ")
+	}
+
+	model := out.Model
+	if model == "" {
+		model = o.Model
+	}
+	return &LLMResponse{
+		Text:      out.Choices[0].Message.Content,
+		Model:     model,
+		Truncated: out.Choices[0].FinishReason == "length",
+		Usage: LLMUsage{
+			PromptTokens:     out.Usage.PromptTokens,
+			CompletionTokens: out.Usage.CompletionTokens,
+			TotalTokens:      out.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+// ---------------- Fake ----------------
+
+// FakeLLMProvider trả kết quả cố định, không gọi mạng: văn bản đầu vào được trả lại nguyên vẹn
+type FakeLLMProvider struct{}
+
+func (f *FakeLLMProvider) Name() string { return "fake" }
+
+func (f *FakeLLMProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	text := strings.TrimSpace(req.Input)
+	if text == "" {
+		text = "[fake] " + strings.TrimSpace(req.Prompt)
+	}
+	// Ước lượng token ~ 4 ký tự/token để số liệu thống kê vẫn có ý nghĩa khi chạy local
+	prompt := len([]rune(fullPrompt(req))) / 4
+	completion := len([]rune(text)) / 4
+	return &LLMResponse{
+		Text:  text,
+		Model: "fake",
+		Usage: LLMUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+	}, nil
+}
+
+// ---------------- Timeout, thử lại và thống kê token ----------------
+
+type retryingLLM struct {
+	inner       LLMProvider
+	model       string
+	timeout     time.Duration
+	maxRetries  int
+	baseBackoff time.Duration
+	trackUsage  bool
+}
+
+func (r *retryingLLM) Name() string { return r.inner.Name() }
+
+func (r *retryingLLM) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	var lastErr error
+	for attempt := 0; attempt <= r.maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := r.baseBackoff << (attempt - 1)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+
+		callCtx, cancel := ctx, context.CancelFunc(func() {})
+		if r.timeout > 0 {
+			callCtx, cancel = context.WithTimeout(ctx, r.timeout)
+		}
+		resp, err := r.inner.Generate(callCtx, req)
+		cancel()
+
+		if err == nil {
+			if r.trackUsage {
+				recordLLMUsage(r.inner.Name(), resp.Model, resp.Usage, false)
+			}
+			return resp, nil
+		}
+
+		lastErr = err
+		if r.trackUsage {
+			recordLLMUsage(r.inner.Name(), r.model, LLMUsage{}, true)
+		}
+		if ctx.Err() != nil || !isTransientError(err) {
+			break
+		}
+		log.Printf("LLM %s lỗi (lần %d/%d), thử lại: %v\n", r.inner.Name(), attempt+1, r.maxRetries+1, err)
+	}
+	return nil, lastErr
+}
+
+// isTransientError: lỗi tạm thời (quá tải, 5xx, timeout, mất kết nối) thì thử lại (dùng cho cả LLM và TTS)
+func isTransientError(err error) bool {
+	var statusErr *LLMStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Internal, codes.Aborted:
+			return true
+		}
+	}
+	return false
+}
+
+// Thống kê token theo provider/model kể từ khi server khởi động
+type LLMUsageStat struct {
+	Provider         string `json:"provider"`
+	Model            string `json:"model"`
+	Calls            int    `json:"calls"`
+	Errors           int    `json:"errors"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+}
+
+var (
+	llmUsageMu    sync.Mutex
+	llmUsageStats = map[string]*LLMUsageStat{}
+)
+
+func recordLLMUsage(provider, model string, usage LLMUsage, failed bool) {
+	llmUsageMu.Lock()
+	defer llmUsageMu.Unlock()
+
+	key := provider + "/" + model
+	stat, ok := llmUsageStats[key]
+	if !ok {
+		stat = &LLMUsageStat{Provider: provider, Model: model}
+		llmUsageStats[key] = stat
+	}
+	if failed {
+		stat.Errors++
+		return
+	}
+	stat.Calls++
+	stat.PromptTokens += usage.PromptTokens
+	stat.CompletionTokens += usage.CompletionTokens
+	stat.TotalTokens += usage.TotalTokens
+}
+
+// GetLLMUsageStats trả về bản sao thống kê token hiện tại
+func GetLLMUsageStats() []LLMUsageStat {
+	llmUsageMu.Lock()
+	defer llmUsageMu.Unlock()
+
+	stats := make([]LLMUsageStat, 0, len(llmUsageStats))
+	for _, s := range llmUsageStats {
+		stats = append(stats, *s)
+	}
+	return stats
+}
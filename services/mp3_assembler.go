@@ -0,0 +1,281 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/Huong3203/APIPodcast/models"
+	tcmp3 "github.com/tcolgate/mp3"
+)
+
+// Ghép MP3 theo frame: bỏ ID3/Xing của từng chunk, ghi 1 header Xing/Info đúng cho cả file, gắn thẻ ID3v2.
+
+var ErrNoMP3Frames = errors.New("không tìm thấy frame MP3 hợp lệ")
+
+// Thông tin gắn vào thẻ ID3v2 của file podcast
+type MP3Tags struct {
+	Title     string
+	Artist    string
+	Album     string
+	Genre     string // Tên danh mục
+	Cover     []byte // Ảnh bìa (JPEG/PNG)
+	CoverMIME string
+	Chapters  []models.ChuongAudio // Ghi thành frame CTOC + CHAP
+}
+
+// JoinMP3 nối các file MP3 ở mức frame và thêm header Xing (VBR) hoặc Info (CBR) để player tính đúng thời lượng
+func JoinMP3(parts [][]byte) ([]byte, error) {
+	var frames [][]byte
+	for _, part := range parts {
+		dec := tcmp3.NewDecoder(bytes.NewReader(stripID3(part)))
+		var frame tcmp3.Frame
+		var skipped int
+		first := true
+		for {
+			if err := dec.Decode(&frame, &skipped); err != nil {
+				// EOF hoặc frame cuối bị cắt cụt: bỏ phần thừa
+				break
+			}
+			buf, _ := io.ReadAll(frame.Reader())
+			if first && isXingFrame(buf) {
+				first = false
+				continue
+			}
+			first = false
+			frames = append(frames, buf)
+		}
+	}
+	if len(frames) == 0 {
+		return nil, ErrNoMP3Frames
+	}
+
+	xing := buildXingFrame(frames)
+	size := len(xing)
+	for _, f := range frames {
+		size += len(f)
+	}
+	out := make([]byte, 0, size)
+	out = append(out, xing...)
+	for _, f := range frames {
+		out = append(out, f...)
+	}
+	return out, nil
+}
+
+// TagMP3 thay thẻ ID3 cũ (nếu có) bằng thẻ ID3v2.3 mới, kèm độ dài (TLEN) tính từ các frame
+func TagMP3(audio []byte, tags MP3Tags) []byte {
+	body := stripID3(audio)
+	var frames []byte
+	frames = append(frames, id3TextFrame("TIT2", tags.Title)...)
+	frames = append(frames, id3TextFrame("TPE1", tags.Artist)...)
+	frames = append(frames, id3TextFrame("TALB", tags.Album)...)
+	frames = append(frames, id3TextFrame("TCON", tags.Genre)...)
+	if d, err := GetMP3DurationFromBytes(body); err == nil && d > 0 {
+		frames = append(frames, id3TextFrame("TLEN", strconv.Itoa(int(d*1000)))...)
+	}
+	if len(tags.Cover) > 0 {
+		mime := tags.CoverMIME
+		if mime == "" {
+			mime = "image/jpeg"
+		}
+		apic := []byte{0} // ISO-8859-1
+		apic = append(apic, mime...)
+		apic = append(apic, 0, 3, 0) // Kết thúc MIME, loại ảnh 3 = bìa trước, mô tả rỗng
+		apic = append(apic, tags.Cover...)
+		frames = append(frames, id3Frame("APIC", apic)...)
+	}
+	frames = append(frames, id3ChapterFrames(tags.Chapters)...)
+
+	out := make([]byte, 0, 10+len(frames)+len(body))
+	out = append(out, 'I', 'D', '3', 3, 0, 0)
+	out = append(out, id3SyncSafe(len(frames))...)
+	out = append(out, frames...)
+	return append(out, body...)
+}
+
+// stripID3 bỏ thẻ ID3v2 ở đầu và ID3v1 ở cuối file
+func stripID3(data []byte) []byte {
+	for len(data) >= 10 && string(data[:3]) == "ID3" {
+		size := id3TagSize(data[:10])
+		if size > len(data) {
+			return nil
+		}
+		data = data[size:]
+	}
+	if len(data) >= 128 && string(data[len(data)-128:len(data)-125]) == "TAG" {
+		data = data[:len(data)-128]
+	}
+	return data
+}
+
+// id3TagSize trả về tổng kích thước thẻ ID3v2 (kể cả header 10 bytes và footer nếu có)
+func id3TagSize(header []byte) int {
+	size := int(header[6]&0x7F)<<21 | int(header[7]&0x7F)<<14 | int(header[8]&0x7F)<<7 | int(header[9]&0x7F)
+	size += 10
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+	return size
+}
+
+func id3SyncSafe(n int) []byte {
+	return []byte{byte(n>>21) & 0x7F, byte(n>>14) & 0x7F, byte(n>>7) & 0x7F, byte(n) & 0x7F}
+}
+
+// id3Frame tạo 1 frame ID3v2.3 (kích thước không syncsafe)
+func id3Frame(id string, body []byte) []byte {
+	out := make([]byte, 0, 10+len(body))
+	out = append(out, id...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(body)))
+	out = append(out, 0, 0)
+	return append(out, body...)
+}
+
+// id3TextFrame mã hoá UTF-16 có BOM (ID3v2.3 không hỗ trợ UTF-8), bỏ qua nếu text rỗng
+func id3TextFrame(id, text string) []byte {
+	if text == "" {
+		return nil
+	}
+	body := []byte{1, 0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(text)) {
+		body = binary.LittleEndian.AppendUint16(body, u)
+	}
+	return id3Frame(id, body)
+}
+
+// id3ChapterFrames tạo 1 frame CTOC (mục lục) và 1 frame CHAP cho mỗi chương theo chuẩn ID3v2 Chapter Frame Addendum
+func id3ChapterFrames(chapters []models.ChuongAudio) []byte {
+	if len(chapters) > 255 {
+		chapters = chapters[:255] // CTOC chỉ chứa tối đa 255 mục
+	}
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	toc := []byte("toc\x00")
+	toc = append(toc, 0x03, byte(len(chapters))) // Mục lục gốc, có thứ tự
+	var chaps []byte
+	for i, ch := range chapters {
+		id := "chp" + strconv.Itoa(i+1)
+		toc = append(toc, id...)
+		toc = append(toc, 0)
+
+		body := append([]byte(id), 0)
+		body = binary.BigEndian.AppendUint32(body, uint32(ch.BatDauMs))
+		body = binary.BigEndian.AppendUint32(body, uint32(ch.KetThucMs))
+		body = binary.BigEndian.AppendUint32(body, 0xFFFFFFFF) // Không dùng offset byte
+		body = binary.BigEndian.AppendUint32(body, 0xFFFFFFFF)
+		body = append(body, id3TextFrame("TIT2", ch.TieuDe)...)
+		chaps = append(chaps, id3Frame("CHAP", body)...)
+	}
+	return append(id3Frame("CTOC", toc), chaps...)
+}
+
+// mp3SideInfoLength: độ dài side info theo phiên bản MPEG và số kênh
+func mp3SideInfoLength(h tcmp3.FrameHeader) int {
+	mono := h.ChannelMode() == tcmp3.SingleChannel
+	if h.Version() == tcmp3.MPEG1 {
+		if mono {
+			return 17
+		}
+		return 32
+	}
+	if mono {
+		return 9
+	}
+	return 17
+}
+
+// isXingFrame nhận ra frame chứa header Xing/Info (LAME) hoặc VBRI (Fraunhofer), không phải audio
+func isXingFrame(frame []byte) bool {
+	if len(frame) < 4 {
+		return false
+	}
+	h := tcmp3.FrameHeader(frame[:4])
+	offset := 4 + mp3SideInfoLength(h)
+	if h.Protection() {
+		offset += 2
+	}
+	if len(frame) >= offset+4 {
+		if tag := string(frame[offset : offset+4]); tag == "Xing" || tag == "Info" {
+			return true
+		}
+	}
+	return len(frame) >= 40 && string(frame[36:40]) == "VBRI"
+}
+
+// buildXingFrame tạo frame im lặng chứa số frame, số byte và bảng TOC để tua chính xác.
+// Dùng cùng phiên bản/tần số lấy mẫu/kênh với frame đầu, chọn bitrate nhỏ nhất đủ chứa dữ liệu.
+func buildXingFrame(frames [][]byte) []byte {
+	first := tcmp3.FrameHeader(frames[0][:4])
+	if first.Layer() != tcmp3.Layer3 {
+		return nil
+	}
+	samples := 576
+	if first.Version() == tcmp3.MPEG1 {
+		samples = 1152
+	}
+	side := mp3SideInfoLength(first)
+	need := 4 + side + 4 + 4 + 4 + 4 + 100
+
+	h := []byte{frames[0][0], frames[0][1] | 0x01, frames[0][2] &^ 0x02, frames[0][3]} // Không CRC, không padding
+	size := 0
+	for idx := byte(1); idx < 15; idx++ {
+		h[2] = h[2]&0x0F | idx<<4
+		br := tcmp3.FrameHeader(h).BitRate()
+		sr := tcmp3.FrameHeader(h).SampleRate()
+		if br <= 0 || sr <= 0 {
+			continue
+		}
+		if size = samples / 8 * int(br) / int(sr); size >= need {
+			break
+		}
+	}
+	if size < need {
+		return nil
+	}
+
+	// CBR nếu mọi frame cùng bitrate
+	tag := "Info"
+	total := size
+	offsets := make([]int, len(frames))
+	for i, f := range frames {
+		offsets[i] = total
+		total += len(f)
+		if f[2]>>4 != frames[0][2]>>4 {
+			tag = "Xing"
+		}
+	}
+
+	frame := make([]byte, size)
+	copy(frame, h)
+	pos := 4 + side
+	copy(frame[pos:], tag)
+	binary.BigEndian.PutUint32(frame[pos+4:], 0x07) // Có số frame, số byte, TOC
+	binary.BigEndian.PutUint32(frame[pos+8:], uint32(len(frames)))
+	binary.BigEndian.PutUint32(frame[pos+12:], uint32(total))
+	for i := 0; i < 100; i++ {
+		off := offsets[i*len(frames)/100]
+		frame[pos+16+i] = byte(min(255, off*256/total))
+	}
+	return frame
+}
+
+// skipID3Reader bỏ qua thẻ ID3v2 ở đầu luồng (ảnh bìa trong thẻ có thể bị nhận nhầm là frame MP3)
+func skipID3Reader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	for {
+		header, err := br.Peek(10)
+		if err != nil || string(header[:3]) != "ID3" {
+			return br
+		}
+		if _, err := br.Discard(id3TagSize(header)); err != nil {
+			return br
+		}
+	}
+}
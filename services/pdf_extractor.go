@@ -4,35 +4,302 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"mime/multipart"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/ledongthuc/pdf"
 )
 
+// Văn bản của 1 trang PDF sau khi đã bỏ header/footer và số trang
+type PDFPage struct {
+	Number int    `json:"number"` // Số trang (bắt đầu từ 1)
+	Text   string `json:"text"`
+}
+
+// Một dòng chữ trên trang PDF
+type pdfLine struct {
+	Y        float64
+	FontSize float64
+	Text     string
+}
+
+const (
+	pdfEdgeLines       = 3   // Số dòng đầu/cuối trang được xét là vùng header/footer
+	pdfRepeatThreshold = 0.6 // Dòng lặp lại trên >= 60% số trang thì coi là header/footer
+)
+
+var (
+	pdfDigitsRe     = regexp.MustCompile(`\d+`)
+	pdfPageNumberRe = regexp.MustCompile(`(?i)^[-–—\s]*((trang|page|tr\.|p\.)\s*)?(\d+|(?-i:[ivx]{1,6}))(\s*(/|of|trên)\s*\d+)?[-–—\s]*$`)
+)
+
 func ExtractTextFromPDF(file multipart.File) (string, error) {
 	var buf bytes.Buffer
 	if _, err := io.Copy(&buf, file); err != nil {
 		return "", fmt.Errorf("lỗi đọc file PDF: %w", err)
 	}
+	return ExtractTextFromPDFBytes(buf.Bytes())
+}
 
-	reader, err := pdf.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+// ExtractTextFromPDFBytes trích xuất văn bản từ nội dung PDF đã đọc sẵn
+func ExtractTextFromPDFBytes(data []byte) (string, error) {
+	pages, err := ExtractPDFPages(data)
 	if err != nil {
-		return "", fmt.Errorf("không thể tạo reader PDF: %w", err)
+		return "", err
 	}
+	return JoinPDFPages(pages), nil
+}
 
-	var textBuilder bytes.Buffer
-	pages := reader.NumPage()
-	for i := 1; i <= pages; i++ {
+// ExtractPDFPages trích xuất văn bản từng trang, bỏ dòng lặp lại ở đầu/cuối trang và nối từ bị ngắt bằng gạch nối
+func ExtractPDFPages(data []byte) ([]PDFPage, error) {
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo reader PDF: %w", err)
+	}
+
+	numPages := reader.NumPage()
+	pageLines := make([][]pdfLine, numPages)
+	for i := 1; i <= numPages; i++ {
 		page := reader.Page(i)
 		if page.V.IsNull() {
 			continue
 		}
-		content, err := page.GetPlainText(nil)
-		if err != nil {
+		pageLines[i-1] = readPDFPageLines(page)
+	}
+
+	repeated := findRepeatedPDFLines(pageLines)
+
+	var pages []PDFPage
+	for i, lines := range pageLines {
+		lines = stripPDFPageEdges(lines, repeated)
+		text := buildPDFParagraphs(lines)
+		if text == "" {
 			continue
 		}
-		textBuilder.WriteString(content)
+		pages = append(pages, PDFPage{Number: i + 1, Text: text})
+	}
+	return pages, nil
+}
+
+// JoinPDFPages ghép các trang thành 1 văn bản, nối đoạn bị cắt ngang giữa 2 trang
+func JoinPDFPages(pages []PDFPage) string {
+	var b strings.Builder
+	for _, p := range pages {
+		if b.Len() > 0 {
+			prev := b.String()
+			switch {
+			case endsWithHyphenatedWord(prev) && startsWithLower(p.Text):
+				// Từ bị ngắt bằng gạch nối ở cuối trang
+				b.Reset()
+				b.WriteString(strings.TrimRight(prev, "-‐"))
+			case !endsSentence(prev) && startsWithLower(p.Text):
+				b.WriteString(" ")
+			default:
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(p.Text)
+	}
+	return b.String()
+}
+
+// readPDFPageLines gom các ký tự của trang thành dòng theo toạ độ Y, từ trên xuống dưới
+func readPDFPageLines(page pdf.Page) (lines []pdfLine) {
+	defer func() {
+		// Thư viện pdf panic với một số content stream lỗi, coi như trang trống
+		if r := recover(); r != nil {
+			lines = nil
+		}
+	}()
+
+	texts := page.Content().Text
+	if len(texts) == 0 {
+		return nil
+	}
+
+	type group struct {
+		y, size float64
+		items   []pdf.Text
 	}
+	var groups []*group
+	for _, t := range texts {
+		tol := math.Max(t.FontSize*0.5, 1)
+		var g *group
+		for _, cand := range groups {
+			if math.Abs(cand.y-t.Y) <= tol {
+				g = cand
+				break
+			}
+		}
+		if g == nil {
+			g = &group{y: t.Y, size: t.FontSize}
+			groups = append(groups, g)
+		}
+		g.items = append(g.items, t)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].y > groups[j].y })
+
+	for _, g := range groups {
+		sort.SliceStable(g.items, func(i, j int) bool { return g.items[i].X < g.items[j].X })
+
+		var b strings.Builder
+		var prevEnd float64
+		for i, t := range g.items {
+			if i > 0 {
+				gap := t.X - prevEnd
+				if gap > math.Max(t.FontSize, 1)*0.2 && !strings.HasSuffix(b.String(), " ") && t.S != " " {
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(t.S)
+			w := t.W
+			if w <= 0 {
+				w = t.FontSize * 0.5
+			}
+			prevEnd = t.X + w
+		}
+
+		text := normalizeSpaces(b.String())
+		if text != "" {
+			lines = append(lines, pdfLine{Y: g.y, FontSize: g.size, Text: text})
+		}
+	}
+	return lines
+}
+
+// normalizePDFEdgeLine chuẩn hoá dòng để so sánh giữa các trang (số trang khác nhau vẫn khớp)
+func normalizePDFEdgeLine(s string) string {
+	return pdfDigitsRe.ReplaceAllString(strings.ToLower(normalizeSpaces(s)), "#")
+}
+
+// findRepeatedPDFLines tìm các dòng ở vùng đầu/cuối trang lặp lại trên phần lớn số trang
+func findRepeatedPDFLines(pageLines [][]pdfLine) map[string]bool {
+	repeated := map[string]bool{}
+	nonEmpty := 0
+	counts := map[string]int{}
+	for _, lines := range pageLines {
+		if len(lines) == 0 {
+			continue
+		}
+		nonEmpty++
+		seen := map[string]bool{}
+		for i, l := range lines {
+			if i >= pdfEdgeLines && i < len(lines)-pdfEdgeLines {
+				continue
+			}
+			key := normalizePDFEdgeLine(l.Text)
+			if !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+	if nonEmpty < 2 {
+		return repeated
+	}
+
+	minPages := int(math.Ceil(float64(nonEmpty) * pdfRepeatThreshold))
+	if minPages < 2 {
+		minPages = 2
+	}
+	for key, n := range counts {
+		if n >= minPages {
+			repeated[key] = true
+		}
+	}
+	return repeated
+}
+
+// stripPDFPageEdges bỏ header/footer lặp lại và dòng chỉ chứa số trang ở đầu/cuối trang
+func stripPDFPageEdges(lines []pdfLine, repeated map[string]bool) []pdfLine {
+	isNoise := func(l pdfLine) bool {
+		return repeated[normalizePDFEdgeLine(l.Text)] || pdfPageNumberRe.MatchString(l.Text)
+	}
+
+	start, end := 0, len(lines)
+	for start < end && start < pdfEdgeLines && isNoise(lines[start]) {
+		start++
+	}
+	for end > start && len(lines)-end < pdfEdgeLines && isNoise(lines[end-1]) {
+		end--
+	}
+	return lines[start:end]
+}
+
+// buildPDFParagraphs nối các dòng thành đoạn, ngắt đoạn khi khoảng cách dòng lớn hơn bình thường
+func buildPDFParagraphs(lines []pdfLine) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	var gaps []float64
+	for i := 1; i < len(lines); i++ {
+		if g := lines[i-1].Y - lines[i].Y; g > 0 {
+			gaps = append(gaps, g)
+		}
+	}
+	normalGap := 0.0
+	if len(gaps) > 0 {
+		sorted := append([]float64(nil), gaps...)
+		sort.Float64s(sorted)
+		normalGap = sorted[len(sorted)/2]
+	}
+
+	var paragraphs []string
+	var cur strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			gap := lines[i-1].Y - l.Y
+			prev := cur.String()
+			switch {
+			case normalGap > 0 && gap > normalGap*1.3:
+				paragraphs = append(paragraphs, prev)
+				cur.Reset()
+			case endsWithHyphenatedWord(prev) && startsWithLower(l.Text):
+				// Nối từ bị ngắt bằng gạch nối ở cuối dòng
+				cur.Reset()
+				cur.WriteString(strings.TrimRight(prev, "-‐"))
+			default:
+				cur.WriteString(" ")
+			}
+		}
+		cur.WriteString(l.Text)
+	}
+	paragraphs = append(paragraphs, cur.String())
+
+	var out []string
+	for _, p := range paragraphs {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// endsWithHyphenatedWord: chuỗi kết thúc bằng chữ cái + gạch nối (vd "chuyển-")
+func endsWithHyphenatedWord(s string) bool {
+	s = strings.TrimRight(s, " ")
+	if !strings.HasSuffix(s, "-") && !strings.HasSuffix(s, "‐") {
+		return false
+	}
+	s = strings.TrimRight(s, "-‐")
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return unicode.IsLetter(r)
+}
+
+func startsWithLower(s string) bool {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(s))
+	return unicode.IsLower(r)
+}
 
-	return textBuilder.String(), nil
+func endsSentence(s string) bool {
+	s = strings.TrimSpace(s)
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return strings.ContainsRune(".!?:;…\"”)", r)
 }
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Loại mẫu prompt, tương ứng với từng lần gọi LLM trong pipeline
+const (
+	PromptClean     = "clean"     // Làm sạch văn bản (CleanWithGemini)
+	PromptScript    = "script"    // Viết lại thành nội dung podcast (SummarizeText)
+	PromptSummary   = "summary"   // Tóm tắt TomTat khi SUMMARY_MODE=llm
+	PromptDialogue  = "dialogue"  // Viết lại thành kịch bản 2 người dẫn (định dạng hội thoại)
+	PromptTranslate = "translate" // Dịch nội dung đã làm sạch sang ngôn ngữ khác (bản dịch podcast)
+)
+
+// Giá trị ghi vào TaiLieu.MauTomTat / MauKichBan khi không dùng mẫu trong DB
+const (
+	PromptSourceBuiltin    = "builtin"
+	PromptSourceExtractive = "extractive"
+)
+
+var (
+	ErrInvalidPromptType     = errors.New("loại mẫu prompt không hợp lệ (clean, script, summary, dialogue, translate)")
+	ErrPromptTemplateMissing = errors.New("không tìm thấy mẫu prompt")
+)
+
+// Biến dùng trong mẫu prompt
+type PromptVars struct {
+	TieuDe   string
+	DanhMuc  string
+	DoDai    int // Độ dài mục tiêu (ký tự)
+	GiongVan string
+	NgonNgu  string // Ngôn ngữ của tài liệu ("vi", "en"...)
+	// Tên ngôn ngữ để ghi vào prompt, vd "tiếng Anh" (điền tự động từ NgonNgu)
+	TenNgonNgu string
+}
+
+// Ngữ cảnh chọn mẫu prompt cho 1 tài liệu
+type PromptContext struct {
+	DB        *gorm.DB
+	DanhMucID string
+	Chon      map[string]string // Mẫu chọn khi upload theo loại (mã mẫu hoặc ID phiên bản)
+	Vars      PromptVars
+}
+
+// Prompt đã điền biến và nguồn của nó (ID phiên bản mẫu hoặc "builtin")
+type RenderedPrompt struct {
+	Text   string
+	Source string
+}
+
+// Mẫu mặc định khi DB chưa có mẫu phù hợp (giữ nguyên prompt cũ, tài liệu không phải tiếng Việt có thêm yêu cầu giữ ngôn ngữ)
+var builtinPromptTemplates = map[string]string{
+	PromptClean: `Bạn là công cụ xử lý văn bản trích xuất từ tài liệu.
+	Hãy xử lý văn bản sau với yêu cầu:
+	- Xoá phần mục lục, các dòng chứa số trang, tiêu đề lặp lại
+	- Xoá code, ví dụ mã lệnh, hoặc các ký hiệu kỹ thuật
+	- Làm gọn văn bản: không có dòng trống thừa, không có ký tự lạ
+	- Ngắt đoạn hợp lý, dễ đọc, phù hợp để chuyển thành nội dung podcast
+	- Giữ nguyên nội dung, không thêm bớt, không giải thích
+	- Không in đậm, in nghiêng, không sử dụng markdown, chỉ trả về văn bản thuần tuý{{if ne .NgonNgu "vi"}}
+	- Giữ nguyên {{.TenNgonNgu}} như văn bản gốc, không dịch{{end}}
+	Văn bản cần làm sạch:`,
+
+	PromptScript: `Tôi có một đoạn văn bản{{if .TieuDe}} với tiêu đề "{{.TieuDe}}"{{end}}, bạn hãy giúp tôi tóm tắt lại nội dung một cách ngắn gọn, rõ ràng, dễ nghe khi được chuyển thành giọng nói (audio).
+	Yêu cầu:
+	1. Không lược bỏ nội dung chính, không tự ý thêm thông tin không có trong văn bản, đảm bảo đủ nội dung quan trọng
+	2. Ngôn ngữ tự nhiên, gần gũi, không quá khô khan
+	3. Có thể thêm câu chuyển đoạn ngắn để mạch lạc hơn
+	4. Không sử dụng từ ngữ chuyên môn quá khó hiểu
+	5. Giọng văn {{.GiongVan}}, phù hợp để đọc lên
+	6. Không sử dụng markdown, không in đậm, không in nghiêng, chỉ trả về văn bản thuần tuý
+	7. Không bình luận, không giải thích, chỉ trả về nội dung tóm tắt phù hợp để chuyển thành audio podcast
+	8. Có thể bắt đầu bằng câu "Chào mừng bạn đến sonify, trong tập này..." để rõ ràng hơn{{if ne .NgonNgu "vi"}}
+	9. Viết toàn bộ bằng {{.TenNgonNgu}} như văn bản gốc, kể cả câu mở đầu{{end}}
+	Đoạn văn bản cần tóm tắt:`,
+
+	PromptSummary: `Tóm tắt văn bản sau bằng {{.TenNgonNgu}} trong tối đa {{.DoDai}} ký tự.
+	Chỉ giữ ý chính, không thêm thông tin ngoài văn bản, không dùng markdown, chỉ trả về đoạn tóm tắt.
+	Văn bản:`,
+
+	PromptDialogue: `Hãy chuyển văn bản sau{{if .TieuDe}} (chủ đề "{{.TieuDe}}"){{end}} thành kịch bản podcast trò chuyện giữa 2 người dẫn.
+	Yêu cầu:
+	1. Mỗi lượt nói nằm trên 1 dòng riêng, bắt đầu bằng "A:" (người dẫn chính) hoặc "B:" (người dẫn phụ), hai người nói xen kẽ
+	2. Giữ đủ nội dung chính, không tự ý thêm thông tin không có trong văn bản
+	3. A mở đầu bằng câu "Chào mừng bạn đến sonify..." và giới thiệu chủ đề
+	4. B đặt câu hỏi, nhận xét ngắn để dẫn dắt, A giải thích; giọng văn {{.GiongVan}}
+	5. Mỗi lượt nói không quá 4 câu, không dùng markdown, không ghi chú hành động hay âm thanh{{if ne .NgonNgu "vi"}}
+	6. Viết toàn bộ lời thoại bằng {{.TenNgonNgu}} như văn bản gốc, nhãn "A:" và "B:" giữ nguyên{{end}}
+	Văn bản:`,
+
+	PromptTranslate: `Hãy dịch văn bản sau{{if .TieuDe}} (chủ đề "{{.TieuDe}}"){{end}} sang {{.TenNgonNgu}} để làm podcast.
+	Yêu cầu:
+	1. Dịch đầy đủ, đúng nghĩa, không tóm tắt, không tự ý thêm thông tin, không giải thích
+	2. Văn phong tự nhiên, dễ nghe khi đọc thành audio; giọng văn {{.GiongVan}}
+	3. Giữ nguyên cách ngắt đoạn, các dòng tiêu đề phần/chương vẫn là 1 dòng riêng
+	4. Dòng bắt đầu bằng "A:" hoặc "B:" là lượt nói của người dẫn: giữ nguyên nhãn, chỉ dịch lời nói
+	5. Không dùng markdown, chỉ trả về văn bản đã dịch
+	Văn bản cần dịch:`,
+}
+
+const defaultPromptTone = "trung tính, nhẹ nhàng"
+
+func IsValidPromptType(loai string) bool {
+	_, ok := builtinPromptTemplates[loai]
+	return ok
+}
+
+// Render chọn mẫu theo thứ tự: mẫu chọn khi upload -> mẫu của danh mục -> mẫu mặc định trong DB -> mẫu trong code
+func (pc PromptContext) Render(loai string) (RenderedPrompt, error) {
+	if !IsValidPromptType(loai) {
+		return RenderedPrompt{}, ErrInvalidPromptType
+	}
+
+	tmpl, err := pc.resolve(loai)
+	if err != nil {
+		return RenderedPrompt{}, err
+	}
+
+	vars := pc.Vars
+	if vars.NgonNgu == "" {
+		vars.NgonNgu = "vi"
+	}
+	vars.TenNgonNgu = LanguageName(vars.NgonNgu)
+	if vars.DoDai <= 0 {
+		vars.DoDai = config.GetSummaryConfig().MaxChars
+	}
+
+	if tmpl == nil {
+		if vars.GiongVan == "" {
+			vars.GiongVan = defaultPromptTone
+		}
+		text, err := RenderPromptTemplate(builtinPromptTemplates[loai], vars)
+		return RenderedPrompt{Text: text, Source: PromptSourceBuiltin}, err
+	}
+
+	if vars.GiongVan == "" {
+		vars.GiongVan = tmpl.GiongVan
+	}
+	if vars.GiongVan == "" {
+		vars.GiongVan = defaultPromptTone
+	}
+	text, err := RenderPromptTemplate(tmpl.NoiDung, vars)
+	if err != nil {
+		return RenderedPrompt{}, fmt.Errorf("mẫu %s v%d: %w", tmpl.MaMau, tmpl.PhienBan, err)
+	}
+	return RenderedPrompt{Text: text, Source: tmpl.ID}, nil
+}
+
+func (pc PromptContext) resolve(loai string) (*models.MauPrompt, error) {
+	if pc.DB == nil {
+		return nil, nil
+	}
+
+	if chosen := pc.Chon[loai]; chosen != "" {
+		tmpl, err := FindPromptTemplate(pc.DB, chosen)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl.Loai != loai {
+			return nil, fmt.Errorf("mẫu %s không phải loại %s", chosen, loai)
+		}
+		return tmpl, nil
+	}
+
+	lang := pc.Vars.NgonNgu
+	if lang == "" {
+		lang = "vi"
+	}
+	base := func() *gorm.DB {
+		return pc.DB.Where("loai = ? AND ngon_ngu = ? AND hien_hanh = ? AND kich_hoat = ?", loai, lang, true, true).
+			Order("ngay_tao DESC")
+	}
+
+	var tmpl models.MauPrompt
+	if pc.DanhMucID != "" {
+		if err := base().Where("danh_muc_id = ?", pc.DanhMucID).First(&tmpl).Error; err == nil {
+			return &tmpl, nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if err := base().Where("danh_muc_id IS NULL").First(&tmpl).Error; err == nil {
+		return &tmpl, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// FindPromptTemplate tìm theo ID phiên bản, hoặc theo mã mẫu (lấy phiên bản hiện hành)
+func FindPromptTemplate(db *gorm.DB, ref string) (*models.MauPrompt, error) {
+	var tmpl models.MauPrompt
+	err := db.Where("id = ?", ref).First(&tmpl).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = db.Where("ma_mau = ? AND hien_hanh = ?", ref, true).First(&tmpl).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrPromptTemplateMissing, ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// CheckPromptChoice kiểm tra mẫu chọn khi upload tồn tại và đúng loại
+func CheckPromptChoice(db *gorm.DB, loai, ref string) error {
+	if ref == "" {
+		return nil
+	}
+	tmpl, err := FindPromptTemplate(db, ref)
+	if err != nil {
+		return err
+	}
+	if tmpl.Loai != loai {
+		return fmt.Errorf("mẫu %s không phải loại %s", ref, loai)
+	}
+	return nil
+}
+
+// RenderPromptTemplate điền biến vào mẫu (cú pháp text/template)
+func RenderPromptTemplate(content string, vars PromptVars) (string, error) {
+	t, err := template.New("prompt").Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("mẫu prompt sai cú pháp: %w", err)
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, vars); err != nil {
+		return "", fmt.Errorf("không điền được biến vào mẫu prompt: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// ValidatePromptTemplate kiểm tra mẫu điền được với dữ liệu thử trước khi lưu
+func ValidatePromptTemplate(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return errors.New("nội dung mẫu prompt không được để trống")
+	}
+	_, err := RenderPromptTemplate(content, PromptVars{TieuDe: "Tiêu đề", DanhMuc: "Danh mục", DoDai: 800, GiongVan: defaultPromptTone, NgonNgu: "vi", TenNgonNgu: "tiếng Việt"})
+	return err
+}
+
+// CreatePromptTemplate tạo mẫu mới (phiên bản 1)
+func CreatePromptTemplate(db *gorm.DB, tmpl *models.MauPrompt) error {
+	if !IsValidPromptType(tmpl.Loai) {
+		return ErrInvalidPromptType
+	}
+	if err := ValidatePromptTemplate(tmpl.NoiDung); err != nil {
+		return err
+	}
+	if tmpl.NgonNgu == "" {
+		tmpl.NgonNgu = "vi"
+	}
+	tmpl.ID = uuid.New().String()
+	tmpl.PhienBan = 1
+	tmpl.HienHanh = true
+	tmpl.KichHoat = true
+	return db.Create(tmpl).Error
+}
+
+// NewPromptTemplateVersion lưu nội dung sửa thành phiên bản mới, phiên bản cũ được giữ lại
+func NewPromptTemplateVersion(db *gorm.DB, maMau string, update func(next *models.MauPrompt)) (*models.MauPrompt, error) {
+	var next models.MauPrompt
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.MauPrompt
+		if err := tx.Where("ma_mau = ? AND hien_hanh = ?", maMau, true).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", ErrPromptTemplateMissing, maMau)
+			}
+			return err
+		}
+
+		next = current
+		update(&next)
+		if !IsValidPromptType(next.Loai) {
+			return ErrInvalidPromptType
+		}
+		if err := ValidatePromptTemplate(next.NoiDung); err != nil {
+			return err
+		}
+		next.ID = uuid.New().String()
+		next.MaMau = current.MaMau
+		next.PhienBan = current.PhienBan + 1
+		next.HienHanh = true
+
+		if err := tx.Model(&models.MauPrompt{}).Where("ma_mau = ?", maMau).Update("hien_hanh", false).Error; err != nil {
+			return err
+		}
+		return tx.Create(&next).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// buildPromptContext lấy biến prompt từ job và tài liệu (tiêu đề, danh mục, mẫu chọn khi upload)
+func buildPromptContext(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu) PromptContext {
+	pc := PromptContext{
+		DB:        db,
+		DanhMucID: job.DanhMucID,
+		Chon: map[string]string{
+			PromptClean:    job.MauLamSach,
+			PromptScript:   job.MauKichBan,
+			PromptDialogue: job.MauKichBan, // Mẫu viết nội dung chỉ dùng 1 trong 2 loại, tuỳ định dạng
+			PromptSummary:  job.MauTomTat,
+		},
+		Vars: PromptVars{
+			TieuDe:   job.TieuDe,
+			GiongVan: job.GiongVan,
+			NgonNgu:  doc.NgonNgu, // Chọn mẫu trong DB đúng ngôn ngữ của tài liệu
+		},
+	}
+	if pc.Vars.TieuDe == "" {
+		pc.Vars.TieuDe = strings.TrimSuffix(doc.TenFileGoc, filepath.Ext(doc.TenFileGoc))
+	}
+	if job.DanhMucID != "" {
+		var dm models.DanhMuc
+		if err := db.Select("ten_danh_muc").First(&dm, "id = ?", job.DanhMucID).Error; err == nil {
+			pc.Vars.DanhMuc = dm.TenDanhMuc
+		}
+	}
+	return pc
+}
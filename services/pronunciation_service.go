@@ -0,0 +1,199 @@
+package services
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+)
+
+// Lexicon là từ điển phát âm đã biên dịch cho 1 danh mục (mục riêng của danh mục ghi đè mục chung)
+type Lexicon struct {
+	re       *regexp.Regexp
+	category lexiconTerms // Mục riêng của danh mục, tra trước
+	global   lexiconTerms // Mục chung
+}
+
+type lexiconTerms struct {
+	exact  map[string]models.TuDienPhatAm // Phân biệt hoa thường
+	folded map[string]models.TuDienPhatAm // Không phân biệt, khoá viết thường
+}
+
+func newLexiconTerms() lexiconTerms {
+	return lexiconTerms{exact: map[string]models.TuDienPhatAm{}, folded: map[string]models.TuDienPhatAm{}}
+}
+
+func (t lexiconTerms) add(e models.TuDienPhatAm) {
+	if e.PhanBietHoaThuong {
+		t.exact[e.TuKhoa] = e
+	} else {
+		t.folded[strings.ToLower(e.TuKhoa)] = e
+	}
+}
+
+func (t lexiconTerms) lookup(word string) (models.TuDienPhatAm, bool) {
+	if e, ok := t.exact[word]; ok {
+		return e, true
+	}
+	e, ok := t.folded[strings.ToLower(word)]
+	return e, ok
+}
+
+type lexiconMatch struct {
+	start, end int
+	entry      models.TuDienPhatAm
+}
+
+// NewLexicon biên dịch các mục đang bật áp dụng cho danh mục (danhMucID rỗng = chỉ mục chung)
+func NewLexicon(entries []models.TuDienPhatAm, danhMucID string) *Lexicon {
+	lex := &Lexicon{category: newLexiconTerms(), global: newLexiconTerms()}
+
+	terms := map[string]bool{}
+	for _, e := range entries {
+		e.TuKhoa = strings.TrimSpace(e.TuKhoa)
+		if !e.KichHoat || e.TuKhoa == "" {
+			continue
+		}
+		switch {
+		case e.DanhMucID == nil:
+			lex.global.add(e)
+		case danhMucID != "" && *e.DanhMucID == danhMucID:
+			lex.category.add(e)
+		default:
+			continue
+		}
+		terms[e.TuKhoa] = true
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// Từ dài khớp trước ("iPhone 15" trước "iPhone")
+	list := make([]string, 0, len(terms))
+	for t := range terms {
+		list = append(list, t)
+	}
+	sortByLengthDesc(list)
+	quoted := make([]string, len(list))
+	for i, t := range list {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	lex.re = regexp.MustCompile(`(?i)(?:` + strings.Join(quoted, "|") + `)`)
+	return lex
+}
+
+// find trả về các vị trí khớp trọn từ (không nằm giữa 1 từ khác)
+func (l *Lexicon) find(text string) []lexiconMatch {
+	if l == nil || l.re == nil {
+		return nil
+	}
+	var matches []lexiconMatch
+	for _, m := range l.re.FindAllStringIndex(text, -1) {
+		if !isTermBoundary(text, m[0], m[1]) {
+			continue
+		}
+		// Mục của danh mục (phân biệt hay không phân biệt hoa thường) luôn thắng mục chung
+		word := text[m[0]:m[1]]
+		entry, ok := l.category.lookup(word)
+		if !ok {
+			entry, ok = l.global.lookup(word)
+		}
+		if ok {
+			matches = append(matches, lexiconMatch{start: m[0], end: m[1], entry: entry})
+		}
+	}
+	return matches
+}
+
+func isTermBoundary(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyWith thay từ bằng cách đọc trong văn bản thuần (mục chỉ có phiên âm được giữ nguyên),
+// các đoạn còn lại đi qua rest
+func (l *Lexicon) applyWith(text string, rest func(string) string) string {
+	matches := l.find(text)
+	if len(matches) == 0 {
+		return rest(text)
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(rest(text[last:m.start]))
+		if m.entry.CachDoc != "" {
+			sb.WriteString(m.entry.CachDoc)
+		} else {
+			sb.WriteString(text[m.start:m.end])
+		}
+		last = m.end
+	}
+	sb.WriteString(rest(text[last:]))
+	return sb.String()
+}
+
+// ssml tạo thẻ SSML cho từ: <phoneme> nếu có phiên âm, ngược lại <sub alias>
+func (m lexiconMatch) ssml(word string) string {
+	switch {
+	case m.entry.PhienAm != "":
+		return `<phoneme alphabet="ipa" ph="` + ssmlEscaper.Replace(m.entry.PhienAm) + `">` + ssmlEscaper.Replace(word) + `</phoneme>`
+	case m.entry.CachDoc != "":
+		return `<sub alias="` + ssmlEscaper.Replace(m.entry.CachDoc) + `">` + ssmlEscaper.Replace(word) + `</sub>`
+	}
+	return ssmlEscaper.Replace(word)
+}
+
+// Bộ nhớ đệm từ điển: nạp lại sau khi admin sửa hoặc hết hạn (nhiều instance dùng chung DB)
+const lexiconCacheTTL = 5 * time.Minute
+
+var (
+	lexiconMu       sync.Mutex
+	lexiconEntries  []models.TuDienPhatAm
+	lexiconLoadedAt time.Time
+)
+
+// LoadLexicon lấy từ điển phát âm cho danh mục, trả về nil nếu từ điển trống hoặc chưa có DB
+func LoadLexicon(danhMucID string) *Lexicon {
+	if config.DB == nil {
+		return nil
+	}
+
+	lexiconMu.Lock()
+	if lexiconLoadedAt.IsZero() || time.Since(lexiconLoadedAt) > lexiconCacheTTL {
+		var entries []models.TuDienPhatAm
+		if err := config.DB.Where("kich_hoat = ?", true).Find(&entries).Error; err != nil {
+			log.Println("Không thể tải từ điển phát âm:", err)
+		} else {
+			lexiconEntries = entries
+			lexiconLoadedAt = time.Now()
+		}
+	}
+	entries := lexiconEntries
+	lexiconMu.Unlock()
+
+	return NewLexicon(entries, danhMucID)
+}
+
+// InvalidateLexiconCache buộc nạp lại từ điển ở lần tổng hợp tiếp theo
+func InvalidateLexiconCache() {
+	lexiconMu.Lock()
+	lexiconLoadedAt = time.Time{}
+	lexiconMu.Unlock()
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/Huong3203/APIPodcast/models"
+)
+
+func TestLexiconCategoryOverridesGlobal(t *testing.T) {
+	cat := "cong-nghe"
+	other := "the-thao"
+	entries := []models.TuDienPhatAm{
+		{TuKhoa: "AI", CachDoc: "ây ai", PhanBietHoaThuong: true, KichHoat: true},
+		{TuKhoa: "ai", CachDoc: "trí tuệ nhân tạo", DanhMucID: &cat, KichHoat: true},
+		{TuKhoa: "iOS", CachDoc: "ai ô ét", KichHoat: true},
+		{TuKhoa: "iOS", CachDoc: "hệ điều hành ai ô ét", PhanBietHoaThuong: true, DanhMucID: &other, KichHoat: true},
+		{TuKhoa: "VAR", CachDoc: "va", PhanBietHoaThuong: true, DanhMucID: &other, KichHoat: false},
+	}
+
+	tests := []struct {
+		danhMuc, text, want string
+	}{
+		{"", "AI và iOS", "ây ai và ai ô ét"},
+		{cat, "AI và iOS", "trí tuệ nhân tạo và ai ô ét"},
+		{other, "AI và iOS", "ây ai và hệ điều hành ai ô ét"},
+		{other, "VAR", "VAR"},
+	}
+	for _, tt := range tests {
+		lex := NewLexicon(entries, tt.danhMuc)
+		got := lex.applyWith(tt.text, func(s string) string { return s })
+		if got != tt.want {
+			t.Errorf("danh mục %q: %q -> %q, muốn %q", tt.danhMuc, tt.text, got, tt.want)
+		}
+	}
+}
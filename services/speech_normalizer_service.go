@@ -0,0 +1,426 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Huong3203/APIPodcast/config"
+)
+
+// Chuẩn hoá văn bản tiếng Việt trước khi đọc: số, ngày, giờ, tiền tệ, phần trăm, đơn vị, số La Mã -> chữ.
+// Chạy lúc tổng hợp giọng nói (nội dung lưu trong DB vẫn giữ nguyên chữ số).
+
+var viDigitWords = [...]string{"không", "một", "hai", "ba", "bốn", "năm", "sáu", "bảy", "tám", "chín"}
+
+// Số: "50.000", "1.234,5", "3,5", "12.5", "2024"
+const viNumPat = `\d{1,3}(?:\.\d{3})+(?:,\d+)?|\d+(?:[.,]\d+)?`
+
+var viThousandsRe = regexp.MustCompile(`^\d{1,3}(?:\.\d{3})+$`)
+
+var viRomanRe = regexp.MustCompile(`^M{0,3}(?:CM|CD|D?C{0,3})(?:XC|XL|L?X{0,3})(?:IX|IV|V?I{0,3})$`)
+
+// Tiền tệ đứng trước số
+var viCurrencyPrefix = map[string]string{"$": "đô la", "€": "ơ rô", "£": "bảng Anh", "¥": "yên"}
+
+// Tiền tệ / hệ số đứng sau số (thứ tự trong regex: dài trước ngắn)
+var viCurrencySuffix = map[string]string{
+	"VNĐ": "đồng", "VND": "đồng", "vnđ": "đồng", "vnd": "đồng", "đ": "đồng",
+	"USD": "đô la", "usd": "đô la", "EUR": "ơ rô", "€": "ơ rô", "£": "bảng Anh", "¥": "yên",
+	"k": "nghìn", "K": "nghìn", "tr": "triệu",
+}
+
+// Đơn vị đo đứng sau số (phân biệt hoa thường: "m" là mét, "M" không phải)
+var viUnits = map[string]string{
+	"km/h": "ki lô mét trên giờ", "m/s": "mét trên giây",
+	"km²": "ki lô mét vuông", "km2": "ki lô mét vuông", "m²": "mét vuông", "m2": "mét vuông", "m³": "mét khối", "m3": "mét khối",
+	"km": "ki lô mét", "cm": "xen ti mét", "mm": "mi li mét", "m": "mét",
+	"kg": "ki lô gam", "mg": "mi li gam", "g": "gam",
+	"ml": "mi li lít", "l": "lít", "L": "lít", "ha": "héc ta",
+	"°C": "độ C", "°F": "độ F", "°": "độ",
+	"TB": "tê ra bai", "GB": "gi ga bai", "MB": "mê ga bai", "KB": "ki lô bai",
+	"GHz": "gi ga héc", "MHz": "mê ga héc", "Hz": "héc",
+	"kWh": "ki lô oát giờ", "MW": "mê ga oát", "kW": "ki lô oát", "W": "oát", "mAh": "mi li am pe giờ", "V": "vôn",
+}
+
+type viRule struct {
+	re *regexp.Regexp
+	// fn trả về chuỗi thay thế cho match m (chỉ số theo FindAllStringSubmatchIndex), false = giữ nguyên
+	fn func(text string, m []int) (string, bool)
+	// free: fn tự kiểm tra ranh giới từ, không kiểm tra chung
+	free bool
+}
+
+var viRules = []viRule{
+	// Ngày đầy đủ: 25/12/2024, 25-12-2024, 25.12.2024
+	{re: regexp.MustCompile(`(\d{1,2})([/.\-])(\d{1,2})([/.\-])(\d{4})`), fn: func(text string, m []int) (string, bool) {
+		if viGroup(text, m, 2) != viGroup(text, m, 4) || viSlashContinues(text, m[1]) {
+			return "", false
+		}
+		day, month := viAtoi(viGroup(text, m, 1)), viAtoi(viGroup(text, m, 3))
+		if day < 1 || day > 31 || month < 1 || month > 12 {
+			return "", false
+		}
+		s := viNumberWords(uint64(day)) + " tháng " + viMonthWords(month) + " năm " + viReadNumber(viGroup(text, m, 5))
+		if !viPrecededBy(text, m[0], "ngày") {
+			s = "ngày " + s
+		}
+		return s, true
+	}},
+	// Tháng/năm: 12/2024 (không phải số hiệu văn bản: Nghị định 08/2022/NĐ-CP)
+	{re: regexp.MustCompile(`(\d{1,2})/(\d{4})`), fn: func(text string, m []int) (string, bool) {
+		month := viAtoi(viGroup(text, m, 1))
+		if month < 1 || month > 12 || viSlashContinues(text, m[1]) {
+			return "", false
+		}
+		s := viMonthWords(month) + " năm " + viReadNumber(viGroup(text, m, 2))
+		if !viPrecededBy(text, m[0], "tháng") {
+			s = "tháng " + s
+		}
+		return s, true
+	}},
+	// Ngày/tháng chỉ khi đứng sau "ngày" (tránh nhầm phân số): ngày 25/12
+	{re: regexp.MustCompile(`(\d{1,2})/(\d{1,2})`), fn: func(text string, m []int) (string, bool) {
+		day, month := viAtoi(viGroup(text, m, 1)), viAtoi(viGroup(text, m, 2))
+		if !viPrecededBy(text, m[0], "ngày") || day < 1 || day > 31 || month < 1 || month > 12 {
+			return "", false
+		}
+		return viNumberWords(uint64(day)) + " tháng " + viMonthWords(month), true
+	}},
+	// Giờ: 14:30, 14:30:15, 14h30, 8h
+	{re: regexp.MustCompile(`(\d{1,2}):(\d{2})(?::(\d{2}))?|(\d{1,2})h(\d{2})?`), fn: func(text string, m []int) (string, bool) {
+		h, min, sec := viGroup(text, m, 1), viGroup(text, m, 2), viGroup(text, m, 3)
+		if h == "" {
+			h, min = viGroup(text, m, 4), viGroup(text, m, 5)
+		}
+		return viClockWords(h, min, sec)
+	}},
+	// Số điện thoại nối bằng gạch/chấm: 0912-345-678, 028.3822.1234 (đọc từng chữ số theo nhóm)
+	{re: regexp.MustCompile(`0\d{1,4}(?:[-.]\d{2,4}){1,3}`), fn: func(text string, m []int) (string, bool) {
+		groups := strings.FieldsFunc(text[m[0]:m[1]], func(r rune) bool { return r == '-' || r == '.' })
+		if len(strings.Join(groups, "")) < 8 {
+			return "", false
+		}
+		for i, g := range groups {
+			groups[i] = viDigitsWords(g)
+		}
+		return strings.Join(groups, ", "), true
+	}},
+	// Khoảng số: 5-10, 2020–2024 (chỉ đổi dấu gạch, số được đọc ở các luật sau cùng đơn vị)
+	{re: regexp.MustCompile(`(` + viNumPat + `)(?:-|–|\s+–\s+)(` + viNumPat + `)`), free: true, fn: func(text string, m []int) (string, bool) {
+		a, b := viGroup(text, m, 1), viGroup(text, m, 2)
+		if !viBoundaryBefore(text, m[0]) || viLeadingZero(a) || viLeadingZero(b) {
+			return "", false
+		}
+		// Không phải chuỗi số nối bằng gạch (số điện thoại, mã)
+		if r, _ := utf8.DecodeRuneInString(text[m[1]:]); unicode.IsDigit(r) || r == '-' || r == '–' {
+			return "", false
+		}
+		return a + " đến " + b, true
+	}},
+	// Số âm: -5°C (dấu gạch liền sau chữ/số là gạch nối, không phải dấu âm)
+	{re: regexp.MustCompile(`[-−](` + viNumPat + `)`), free: true, fn: func(text string, m []int) (string, bool) {
+		if !viBoundaryBefore(text, m[0]) {
+			return "", false
+		}
+		return "âm " + viGroup(text, m, 1), true
+	}},
+	// Tiền tệ đứng trước: $100, €5
+	{re: regexp.MustCompile(`([$€£¥])\s?(` + viNumPat + `)`), fn: func(text string, m []int) (string, bool) {
+		return viReadNumber(viGroup(text, m, 2)) + " " + viCurrencyPrefix[viGroup(text, m, 1)], true
+	}},
+	// Tiền tệ / hệ số đứng sau: 50.000đ, 100 USD, 50k, 2tr
+	{re: regexp.MustCompile(`(` + viNumPat + `)\s?(` + viAlternation(viCurrencySuffix) + `)`), fn: func(text string, m []int) (string, bool) {
+		return viReadNumber(viGroup(text, m, 1)) + " " + viCurrencySuffix[viGroup(text, m, 2)], true
+	}},
+	// Phần trăm: 3,5%
+	{re: regexp.MustCompile(`(` + viNumPat + `)\s?%`), fn: func(text string, m []int) (string, bool) {
+		return viReadNumber(viGroup(text, m, 1)) + " phần trăm", true
+	}},
+	// Đơn vị đo: 5km, 30 °C, 100 m²
+	{re: regexp.MustCompile(`(` + viNumPat + `)\s?(` + viAlternation(viUnits) + `)`), fn: func(text string, m []int) (string, bool) {
+		return viReadNumber(viGroup(text, m, 1)) + " " + viUnits[viGroup(text, m, 2)], true
+	}},
+	// Số thứ tự: thứ 2, hạng 1
+	{re: regexp.MustCompile(`(?i:(thứ|hạng))\s+(\d+)`), fn: func(text string, m []int) (string, bool) {
+		return viGroup(text, m, 1) + " " + viOrdinalWords(viAtoi(viGroup(text, m, 2))), true
+	}},
+	// Số La Mã sau từ chỉ thứ tự: Chương IV, thế kỷ XX, Thế chiến thứ II
+	{re: regexp.MustCompile(`(?i:(chương|phần|thế kỷ|thế kỉ|quyển|tập|khoá|khóa|đại hội|hồi|bài|mục|phụ lục|giai đoạn|thứ|lần))\s+([IVXLCDM]+)`), fn: func(text string, m []int) (string, bool) {
+		n, ok := viRomanValue(viGroup(text, m, 2))
+		if !ok {
+			return "", false
+		}
+		word := viGroup(text, m, 1)
+		if strings.EqualFold(word, "thứ") {
+			return word + " " + viOrdinalWords(n), true
+		}
+		return word + " " + viNumberWords(uint64(n)), true
+	}},
+	// Số còn lại: 1.234,5, 0912345678 ("COVID-19" -> "COVID-mười chín")
+	{re: regexp.MustCompile(viNumPat), fn: func(text string, m []int) (string, bool) {
+		return viReadNumber(text[m[0]:m[1]]), true
+	}},
+}
+
+// NormalizeVietnamese đọc số, ngày, giờ, tiền tệ, phần trăm, đơn vị và số La Mã thành chữ tiếng Việt
+func NormalizeVietnamese(text string) string {
+	for _, rule := range viRules {
+		matches := rule.re.FindAllStringSubmatchIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		var sb strings.Builder
+		last := 0
+		for _, m := range matches {
+			if !rule.free && !isTermBoundary(text, m[0], m[1]) {
+				continue
+			}
+			repl, ok := rule.fn(text, m)
+			if !ok {
+				continue
+			}
+			sb.WriteString(text[last:m[0]])
+			sb.WriteString(repl)
+			last = m[1]
+		}
+		sb.WriteString(text[last:])
+		text = sb.String()
+	}
+	return text
+}
+
+// normalizeForSpeech chỉ chuẩn hoá cho giọng tiếng Việt (ngôn ngữ khác để máy TTS tự đọc)
+func normalizeForSpeech(text, lang string) string {
+	if !strings.HasPrefix(lang, "vi") || !config.GetTTSConfig().Normalize {
+		return text
+	}
+	return NormalizeVietnamese(text)
+}
+
+// PrepareSpeechText tạo văn bản thuần gửi TTS: từ trong từ điển phát âm được thay cách đọc,
+// phần còn lại được chuẩn hoá theo ngôn ngữ của giọng đọc
+func PrepareSpeechText(text string, lex *Lexicon, lang string) string {
+	return lex.applyWith(text, func(s string) string { return normalizeForSpeech(s, lang) })
+}
+
+// viReadNumber đọc 1 số đã khớp viNumPat
+func viReadNumber(tok string) string {
+	intPart, frac, sep := tok, "", ""
+	if i := strings.LastIndexByte(tok, ','); i >= 0 {
+		intPart, frac, sep = tok[:i], tok[i+1:], "phẩy"
+	} else if strings.Count(tok, ".") == 1 && !viThousandsRe.MatchString(tok) {
+		i := strings.IndexByte(tok, '.')
+		intPart, frac, sep = tok[:i], tok[i+1:], "chấm"
+	}
+	intPart = strings.ReplaceAll(intPart, ".", "")
+
+	var s string
+	if viLeadingZero(intPart) || len(intPart) > 18 {
+		// Mã, số điện thoại: đọc từng chữ số
+		s = viDigitsWords(intPart)
+	} else {
+		n, _ := strconv.ParseUint(intPart, 10, 64)
+		s = viNumberWords(n)
+	}
+	if frac == "" {
+		return s
+	}
+	if len(frac) <= 2 && frac[0] != '0' {
+		n, _ := strconv.ParseUint(frac, 10, 64)
+		return s + " " + sep + " " + viNumberWords(n)
+	}
+	return s + " " + sep + " " + viDigitsWords(frac)
+}
+
+// viNumberWords đọc số nguyên: 2024 -> "hai nghìn không trăm hai mươi tư"
+func viNumberWords(n uint64) string {
+	if n == 0 {
+		return "không"
+	}
+	return strings.Join(viNumberParts(n, false), " ")
+}
+
+// full: đã có hàng cao hơn nên phải đọc đủ "không trăm", "linh"
+func viNumberParts(n uint64, full bool) []string {
+	const billion = 1_000_000_000
+	if n >= billion {
+		parts := append(viNumberParts(n/billion, full), "tỷ")
+		if rest := n % billion; rest > 0 {
+			parts = append(parts, viNumberParts(rest, true)...)
+		}
+		return parts
+	}
+
+	var parts []string
+	for _, g := range []struct {
+		div  uint64
+		name string
+	}{{1_000_000, "triệu"}, {1000, "nghìn"}, {1, ""}} {
+		v := n / g.div % 1000
+		if v == 0 {
+			continue
+		}
+		parts = append(parts, viTripleWords(int(v), full)...)
+		if g.name != "" {
+			parts = append(parts, g.name)
+		}
+		full = true
+	}
+	return parts
+}
+
+func viTripleWords(n int, full bool) []string {
+	h, t, u := n/100, n/10%10, n%10
+	var w []string
+	if h > 0 || full {
+		w = append(w, viDigitWords[h], "trăm")
+	}
+	switch {
+	case t == 0:
+		if u > 0 {
+			if h > 0 || full {
+				w = append(w, "linh")
+			}
+			w = append(w, viDigitWords[u])
+		}
+	case t == 1:
+		w = append(w, "mười")
+		if u == 5 {
+			w = append(w, "lăm")
+		} else if u > 0 {
+			w = append(w, viDigitWords[u])
+		}
+	default:
+		w = append(w, viDigitWords[t], "mươi")
+		switch u {
+		case 0:
+		case 1:
+			w = append(w, "mốt")
+		case 4:
+			w = append(w, "tư")
+		case 5:
+			w = append(w, "lăm")
+		default:
+			w = append(w, viDigitWords[u])
+		}
+	}
+	return w
+}
+
+func viDigitsWords(digits string) string {
+	words := make([]string, 0, len(digits))
+	for _, d := range digits {
+		if d >= '0' && d <= '9' {
+			words = append(words, viDigitWords[d-'0'])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func viMonthWords(month int) string {
+	if month == 4 {
+		return "tư"
+	}
+	return viNumberWords(uint64(month))
+}
+
+func viOrdinalWords(n int) string {
+	switch n {
+	case 1:
+		return "nhất"
+	case 4:
+		return "tư"
+	}
+	return viNumberWords(uint64(n))
+}
+
+func viClockWords(h, min, sec string) (string, bool) {
+	hour, minute, second := viAtoi(h), viAtoi(min), viAtoi(sec)
+	if hour > 24 || minute > 59 || second > 59 {
+		return "", false
+	}
+	s := viNumberWords(uint64(hour)) + " giờ"
+	if minute > 0 || sec != "" {
+		s += " " + viNumberWords(uint64(minute)) + " phút"
+	}
+	if second > 0 {
+		s += " " + viNumberWords(uint64(second)) + " giây"
+	}
+	return s, true
+}
+
+func viRomanValue(s string) (int, bool) {
+	if s == "" || !viRomanRe.MatchString(s) {
+		return 0, false
+	}
+	values := map[byte]int{'I': 1, 'V': 5, 'X': 10, 'L': 50, 'C': 100, 'D': 500, 'M': 1000}
+	total := 0
+	for i := 0; i < len(s); i++ {
+		v := values[s[i]]
+		if i+1 < len(s) && values[s[i+1]] > v {
+			total -= v
+		} else {
+			total += v
+		}
+	}
+	return total, true
+}
+
+func viGroup(text string, m []int, i int) string {
+	if 2*i+1 >= len(m) || m[2*i] < 0 {
+		return ""
+	}
+	return text[m[2*i]:m[2*i+1]]
+}
+
+func viAtoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func viLeadingZero(num string) bool {
+	return len(num) > 1 && num[0] == '0' && num[1] >= '0' && num[1] <= '9'
+}
+
+// viSlashContinues cho biết sau vị trí end là "/" hoặc chữ (số hiệu văn bản "08/2022/NĐ-CP", không phải ngày tháng)
+func viSlashContinues(text string, end int) bool {
+	r, _ := utf8.DecodeRuneInString(text[end:])
+	return r == '/' || unicode.IsLetter(r)
+}
+
+// viPrecededBy cho biết từ ngay trước vị trí start có phải word không (không phân biệt hoa thường)
+func viPrecededBy(text string, start int, word string) bool {
+	fields := strings.Fields(text[:start])
+	return len(fields) > 0 && strings.EqualFold(fields[len(fields)-1], word)
+}
+
+func viBoundaryBefore(text string, start int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:start])
+	return start == 0 || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// viAlternation ghép các khoá thành nhánh regex, khoá dài đứng trước
+func viAlternation(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sortByLengthDesc(keys)
+	for i, k := range keys {
+		keys[i] = regexp.QuoteMeta(k)
+	}
+	return strings.Join(keys, "|")
+}
+
+func sortByLengthDesc(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+}
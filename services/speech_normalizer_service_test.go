@@ -0,0 +1,63 @@
+package services
+
+import "testing"
+
+func TestNormalizeVietnamese(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		// Ngày tháng
+		{"ngày đầy đủ", "Ngày 25/12/2024 là Giáng sinh.", "Ngày hai mươi lăm tháng mười hai năm hai nghìn không trăm hai mươi tư là Giáng sinh."},
+		{"ngày không có chữ ngày", "sinh 2/9/1945", "sinh ngày hai tháng chín năm một nghìn chín trăm bốn mươi lăm"},
+		{"tháng/năm", "Hạn chót 12/2024.", "Hạn chót tháng mười hai năm hai nghìn không trăm hai mươi tư."},
+		{"tháng/năm sau chữ tháng", "tháng 3/2023", "tháng ba năm hai nghìn không trăm hai mươi ba"},
+		{"ngày/tháng sau chữ ngày", "ngày 25/12", "ngày hai mươi lăm tháng mười hai"},
+		{"phân số không phải ngày", "tỉ lệ 1/2", "tỉ lệ một/hai"},
+
+		// Số hiệu văn bản pháp luật
+		{"nghị định", "Nghị định 08/2022/NĐ-CP", "Nghị định không tám/hai nghìn không trăm hai mươi hai/NĐ-CP"},
+		{"thông tư", "Thông tư 01/2023/TT-BTC", "Thông tư không một/hai nghìn không trăm hai mươi ba/TT-BTC"},
+		{"quyết định có ngày", "Quyết định 15/12/2023/QĐ-UBND", "Quyết định mười lăm/mười hai/hai nghìn không trăm hai mươi ba/QĐ-UBND"},
+
+		// Tiền tệ
+		{"đồng", "Giá 50.000đ", "Giá năm mươi nghìn đồng"},
+		{"USD sau số", "100 USD", "một trăm đô la"},
+		{"$ trước số", "$100", "một trăm đô la"},
+		{"nghìn viết tắt", "50k", "năm mươi nghìn"},
+		{"triệu viết tắt", "2tr", "hai triệu"},
+		{"số thập phân", "1.234,5 VNĐ", "một nghìn hai trăm ba mươi tư phẩy năm đồng"},
+
+		// Phần trăm
+		{"phần trăm thập phân", "tăng 3,5%", "tăng ba phẩy năm phần trăm"},
+		{"phần trăm có cách", "giảm 10 %", "giảm mười phần trăm"},
+
+		// Đơn vị
+		{"ki lô mét", "5km", "năm ki lô mét"},
+		{"độ C", "30°C", "ba mươi độ C"},
+		{"mét vuông", "100 m²", "một trăm mét vuông"},
+		{"ki lô gam", "2 kg", "hai ki lô gam"},
+		{"số âm", "-5°C", "âm năm độ C"},
+
+		// Khoảng số
+		{"khoảng", "từ 5-10 người", "từ năm đến mười người"},
+		{"khoảng năm", "giai đoạn 2020–2024", "giai đoạn hai nghìn không trăm hai mươi đến hai nghìn không trăm hai mươi tư"},
+
+		// Số điện thoại
+		{"di động có gạch", "Gọi 0912-345-678", "Gọi không chín một hai, ba bốn năm, sáu bảy tám"},
+		{"cố định có chấm", "tổng đài 028.3822.1234", "tổng đài không hai tám, ba tám hai hai, một hai ba bốn"},
+		{"liền", "số 0912345678", "số không chín một hai ba bốn năm sáu bảy tám"},
+
+		// Khác
+		{"giờ", "lúc 14:30", "lúc mười bốn giờ ba mươi phút"},
+		{"giờ viết tắt", "8h", "tám giờ"},
+		{"số La Mã", "thế kỷ XX", "thế kỷ hai mươi"},
+		{"hàng triệu", "1.000.000", "một triệu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeVietnamese(tt.in); got != tt.want {
+				t.Errorf("NormalizeVietnamese(%q) =\n%q\nmuốn\n%q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
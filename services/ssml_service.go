@@ -0,0 +1,401 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Huong3203/APIPodcast/config"
+)
+
+// Khoảng nghỉ chèn vào SSML
+const (
+	ssmlSectionBreak   = `<break time="900ms"/>`
+	ssmlHeadingBreak   = `<break time="500ms"/>`
+	ssmlParagraphBreak = `<break time="400ms"/>`
+	ssmlListItemBreak  = `<break time="250ms"/>`
+	ssmlQuoteBreak     = `<break time="150ms"/>`
+)
+
+// Dòng liệt kê: "- ...", "• ...", "1. ...", "a) ..."
+var ssmlListMarkerRe = regexp.MustCompile(`^(?:[-•*+–]|\d{1,2}[.)]|[a-zđ]\))\s+(.+)$`)
+
+// Ngày (dd/mm/yyyy), giờ (hh:mm), số thập phân dấu phẩy (giữ nguyên), số có dấu chấm
+// (tiếng Việt: phân cách hàng nghìn, ngôn ngữ khác: số thập phân), số thập phân dấu chấm, số nguyên
+var ssmlSayAsRe = regexp.MustCompile(`(?P<date>\b\d{1,2}/\d{1,2}/\d{4}\b)|(?P<time>\b\d{1,2}:\d{2}\b)|(?P<dec>\b\d{1,3}(?:\.\d{3})*,\d+\b)|(?P<group>\b\d{1,3}(?:\.\d{3})+\b)|(?P<point>\b\d+\.\d+\b)|(?P<num>\b\d+\b)`)
+
+// Trích dẫn trong ngoặc kép
+var ssmlQuoteRe = regexp.MustCompile(`[“"][^”"\n]{1,300}[”"]`)
+
+var ssmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+
+// UseSSMLForVoice cho biết có gửi SSML cho giọng này không (giọng Chirp HD không hỗ trợ SSML)
+func UseSSMLForVoice(voice string) bool {
+	switch config.GetTTSConfig().SSMLMode {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	return !strings.Contains(voice, "Chirp")
+}
+
+// BuildSSML chuyển văn bản đã làm sạch thành SSML: nghỉ giữa các phần/đoạn/mục liệt kê,
+// nhấn mạnh tiêu đề, từ điển phát âm (lex có thể nil), số/ngày đọc thành chữ với giọng tiếng Việt
+// hoặc say-as với ngôn ngữ khác (lang là mã ngôn ngữ của giọng, vd "vi-VN")
+func BuildSSML(text string, lex *Lexicon, lang string) string {
+	var sb strings.Builder
+	sb.WriteString("<speak>")
+
+	first, afterHeading := true, false
+	for _, para := range plainParagraphSplitRe.Split(text, -1) {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+
+		if _, ok := plainHeadingLevel(para); ok {
+			if !first {
+				sb.WriteString(ssmlSectionBreak)
+			}
+			sb.WriteString(`<emphasis level="strong">` + ssmlInline(normalizeSpaces(para), lex, lang) + `</emphasis>` + ssmlHeadingBreak)
+			first, afterHeading = false, true
+			continue
+		}
+
+		if !first && !afterHeading {
+			sb.WriteString(ssmlParagraphBreak)
+		}
+		first, afterHeading = false, false
+
+		sb.WriteString("<p>")
+		var prose []string
+		flushProse := func() {
+			for _, s := range SplitSentences(strings.Join(prose, " ")) {
+				sb.WriteString("<s>" + ssmlInline(s, lex, lang) + "</s>")
+			}
+			prose = nil
+		}
+		for _, line := range strings.Split(para, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			if m := ssmlListMarkerRe.FindStringSubmatch(line); m != nil {
+				flushProse()
+				sb.WriteString("<s>" + ssmlInline(ensureSentenceEnd(m[1]), lex, lang) + "</s>" + ssmlListItemBreak)
+				continue
+			}
+			prose = append(prose, line)
+		}
+		flushProse()
+		sb.WriteString("</p>")
+	}
+
+	sb.WriteString("</speak>")
+	return sb.String()
+}
+
+// ssmlInline escape văn bản: nghỉ ngắn quanh trích dẫn, áp dụng từ điển phát âm, chuẩn hoá số/ngày/giờ
+func ssmlInline(text string, lex *Lexicon, lang string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range ssmlQuoteRe.FindAllStringIndex(text, -1) {
+		sb.WriteString(ssmlTerms(text[last:m[0]], lex, lang))
+		quote := text[m[0]:m[1]]
+		_, size := utf8.DecodeRuneInString(quote)
+		_, lastSize := utf8.DecodeLastRuneInString(quote)
+		sb.WriteString(ssmlQuoteBreak + ssmlTerms(quote[size:len(quote)-lastSize], lex, lang) + ssmlQuoteBreak)
+		last = m[1]
+	}
+	sb.WriteString(ssmlTerms(text[last:], lex, lang))
+	return sb.String()
+}
+
+// ssmlTerms thay từ có trong từ điển phát âm bằng <sub>/<phoneme>, phần còn lại được chuẩn hoá
+// rồi qua say-as (số chưa được đọc thành chữ)
+func ssmlTerms(text string, lex *Lexicon, lang string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range lex.find(text) {
+		sb.WriteString(ssmlSayAs(normalizeForSpeech(text[last:m.start], lang), lang))
+		sb.WriteString(m.ssml(text[m.start:m.end]))
+		last = m.end
+	}
+	sb.WriteString(ssmlSayAs(normalizeForSpeech(text[last:], lang), lang))
+	return sb.String()
+}
+
+func ssmlSayAs(text, lang string) string {
+	var sb strings.Builder
+	last := 0
+	names := ssmlSayAsRe.SubexpNames()
+	for _, m := range ssmlSayAsRe.FindAllStringSubmatchIndex(text, -1) {
+		sb.WriteString(ssmlEscaper.Replace(text[last:m[0]]))
+		match := text[m[0]:m[1]]
+		last = m[1]
+
+		kind := ""
+		for i := 1; i < len(names); i++ {
+			if m[2*i] >= 0 {
+				kind = names[i]
+				break
+			}
+		}
+
+		switch kind {
+		case "date":
+			sb.WriteString(`<say-as interpret-as="date" format="dmy">` + match + `</say-as>`)
+		case "time":
+			sb.WriteString(`<say-as interpret-as="time" format="hms24">` + match + `</say-as>`)
+		case "group":
+			if strings.HasPrefix(lang, "vi") {
+				sb.WriteString(`<say-as interpret-as="cardinal">` + strings.ReplaceAll(match, ".", "") + `</say-as>`)
+			} else {
+				// "3.141" với giọng tiếng Anh là số thập phân: giữ nguyên dấu chấm
+				sb.WriteString(match)
+			}
+		case "point":
+			sb.WriteString(match)
+		case "num":
+			if len(match) > 1 && match[0] == '0' {
+				// Số bắt đầu bằng 0 (mã, số điện thoại): đọc từng chữ số
+				sb.WriteString(`<say-as interpret-as="characters">` + match + `</say-as>`)
+			} else {
+				sb.WriteString(`<say-as interpret-as="cardinal">` + match + `</say-as>`)
+			}
+		default:
+			sb.WriteString(ssmlEscaper.Replace(match))
+		}
+	}
+	sb.WriteString(ssmlEscaper.Replace(text[last:]))
+	return sb.String()
+}
+
+// Phần tử SSML luôn được giữ nguyên trong 1 chunk (nội dung ngắn, không được tách)
+var ssmlAtomicTags = map[string]bool{
+	"say-as": true, "sub": true, "phoneme": true, "emphasis": true, "prosody": true, "lang": true, "voice": true, "audio": true,
+}
+
+type ssmlToken struct {
+	raw     string
+	open    string // Tên thẻ mở (chưa đóng trong token này)
+	close   string // Tên thẻ đóng
+	content bool   // Token có nội dung đọc được
+}
+
+type ssmlOpenTag struct {
+	name string
+	raw  string
+}
+
+// splitSSMLToChunksByByte chia SSML thành nhiều tài liệu <speak> hợp lệ, mỗi tài liệu không quá maxBytes.
+// Chỉ cắt giữa các câu/từ, không bao giờ cắt trong thẻ; thẻ đang mở được đóng lại ở cuối chunk và mở lại ở chunk sau
+func splitSSMLToChunksByByte(ssml string, maxBytes int) []string {
+	body := strings.TrimSpace(ssml)
+	body = strings.TrimPrefix(body, "<speak>")
+	body = strings.TrimSuffix(body, "</speak>")
+
+	const wrapper = len("<speak></speak>")
+	tokens := tokenizeSSML(body, maxBytes/2)
+
+	var (
+		chunks []string
+		cur    strings.Builder
+		stack  []ssmlOpenTag
+		dirty  bool
+	)
+	closingLen := func() int {
+		n := 0
+		for _, t := range stack {
+			n += len(t.name) + 3
+		}
+		return n
+	}
+	flush := func() {
+		var sb strings.Builder
+		sb.WriteString("<speak>")
+		sb.WriteString(cur.String())
+		for i := len(stack) - 1; i >= 0; i-- {
+			sb.WriteString("</" + stack[i].name + ">")
+		}
+		sb.WriteString("</speak>")
+		chunks = append(chunks, sb.String())
+
+		cur.Reset()
+		for _, t := range stack {
+			cur.WriteString(t.raw)
+		}
+		dirty = false
+	}
+
+	write := func(tok ssmlToken) {
+		cur.WriteString(tok.raw)
+		switch {
+		case tok.open != "":
+			stack = append(stack, ssmlOpenTag{name: tok.open, raw: tok.raw})
+		case tok.close != "":
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == tok.close {
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+		if tok.content {
+			dirty = true
+		}
+	}
+
+	// Ưu tiên cắt giữa các câu: chỉ tách bên trong 1 câu khi riêng câu đó đã vượt giới hạn
+	for _, unit := range groupSSMLSentences(tokens) {
+		size, opened := 0, 0
+		for _, tok := range unit {
+			size += len(tok.raw)
+			if tok.open != "" {
+				opened += len(tok.open) + 3
+			}
+		}
+		if dirty && cur.Len()+size+closingLen()+opened+wrapper > maxBytes {
+			flush()
+		}
+		if cur.Len()+size+closingLen()+opened+wrapper <= maxBytes {
+			for _, tok := range unit {
+				write(tok)
+			}
+			continue
+		}
+		for _, tok := range unit {
+			extra := 0
+			if tok.open != "" {
+				extra = len(tok.open) + 3
+			}
+			if dirty && cur.Len()+len(tok.raw)+closingLen()+extra+wrapper > maxBytes {
+				flush()
+			}
+			write(tok)
+		}
+	}
+	if dirty {
+		flush()
+	}
+	return chunks
+}
+
+// groupSSMLSentences gom token thành từng câu: kết thúc ở thẻ đóng, thẻ tự đóng (break) hoặc văn bản có dấu kết câu
+func groupSSMLSentences(tokens []ssmlToken) [][]ssmlToken {
+	var (
+		units [][]ssmlToken
+		cur   []ssmlToken
+	)
+	for _, tok := range tokens {
+		cur = append(cur, tok)
+		isBoundary := tok.close != "" ||
+			(tok.open == "" && !tok.content && strings.HasPrefix(tok.raw, "<")) ||
+			(tok.open == "" && !strings.HasPrefix(tok.raw, "<") && endsSentence(strings.TrimSpace(tok.raw)))
+		if isBoundary {
+			units = append(units, cur)
+			cur = nil
+		}
+	}
+	if len(cur) > 0 {
+		units = append(units, cur)
+	}
+	return units
+}
+
+// tokenizeSSML tách SSML thành thẻ, phần tử nguyên khối và các đoạn văn bản theo câu (mỗi đoạn tối đa maxPiece byte)
+func tokenizeSSML(body string, maxPiece int) []ssmlToken {
+	var tokens []ssmlToken
+	for len(body) > 0 {
+		if body[0] != '<' {
+			end := strings.IndexByte(body, '<')
+			if end < 0 {
+				end = len(body)
+			}
+			for _, piece := range splitSSMLText(body[:end], maxPiece) {
+				tokens = append(tokens, ssmlToken{raw: piece, content: strings.TrimSpace(piece) != ""})
+			}
+			body = body[end:]
+			continue
+		}
+
+		end := strings.IndexByte(body, '>')
+		if end < 0 {
+			// Thẻ hỏng: coi phần còn lại là văn bản
+			tokens = append(tokens, ssmlToken{raw: ssmlEscaper.Replace(body), content: true})
+			break
+		}
+		tag := body[:end+1]
+		body = body[end+1:]
+		name := ssmlTagName(tag)
+
+		switch {
+		case strings.HasPrefix(tag, "</"):
+			tokens = append(tokens, ssmlToken{raw: tag, close: name})
+		case strings.HasSuffix(tag, "/>"):
+			tokens = append(tokens, ssmlToken{raw: tag})
+		case ssmlAtomicTags[name]:
+			closeTag := "</" + name + ">"
+			idx := strings.Index(body, closeTag)
+			if idx < 0 {
+				tokens = append(tokens, ssmlToken{raw: tag, open: name})
+				continue
+			}
+			tokens = append(tokens, ssmlToken{raw: tag + body[:idx+len(closeTag)], content: true})
+			body = body[idx+len(closeTag):]
+		default:
+			tokens = append(tokens, ssmlToken{raw: tag, open: name})
+		}
+	}
+	return tokens
+}
+
+// splitSSMLText chia văn bản (đã escape) sau dấu kết câu; đoạn quá dài được chia tiếp tại khoảng trắng
+func splitSSMLText(text string, maxPiece int) []string {
+	var pieces []string
+	start := 0
+	for _, m := range sentenceBoundaryRe.FindAllStringIndex(text, -1) {
+		pieces = append(pieces, text[start:m[1]])
+		start = m[1]
+	}
+	if start < len(text) {
+		pieces = append(pieces, text[start:])
+	}
+
+	var out []string
+	for _, p := range pieces {
+		for len(p) > maxPiece {
+			cut := strings.LastIndexByte(p[:maxPiece], ' ')
+			if cut <= 0 {
+				// Không có khoảng trắng: cắt ở ranh giới ký tự UTF-8, tránh giữa thực thể &...;
+				cut = maxPiece
+				for cut > 0 && (p[cut]&0xC0) == 0x80 {
+					cut--
+				}
+				if amp := strings.LastIndexByte(p[:cut], '&'); amp >= 0 && !strings.Contains(p[amp:cut], ";") {
+					cut = amp
+				}
+				if cut <= 0 {
+					cut = maxPiece
+				}
+			} else {
+				cut++
+			}
+			out = append(out, p[:cut])
+			p = p[cut:]
+		}
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func ssmlTagName(tag string) string {
+	name := strings.TrimLeft(tag, "</")
+	if i := strings.IndexAny(name, " \t\n/>"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
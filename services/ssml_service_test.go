@@ -0,0 +1,21 @@
+package services
+
+import "testing"
+
+func TestSSMLSayAsNumbers(t *testing.T) {
+	tests := []struct {
+		text, lang, want string
+	}{
+		{"pi = 3.141", "en-US", "pi = 3.141"},
+		{"up 12.5 points", "en-US", "up 12.5 points"},
+		{"dân số 3.141 người", "vi-VN", `dân số <say-as interpret-as="cardinal">3141</say-as> người`},
+		{"tăng 2,5 lần", "vi-VN", "tăng 2,5 lần"},
+		{"42 items", "en-US", `<say-as interpret-as="cardinal">42</say-as> items`},
+		{"mã 0912", "vi-VN", `mã <say-as interpret-as="characters">0912</say-as>`},
+	}
+	for _, tt := range tests {
+		if got := ssmlSayAs(tt.text, tt.lang); got != tt.want {
+			t.Errorf("ssmlSayAs(%q, %q) = %q, muốn %q", tt.text, tt.lang, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Huong3203/APIPodcast/config"
+)
+
+// Kết quả tóm tắt trích xuất
+type SummaryResult struct {
+	Summary      string   `json:"summary"`       // Các câu được chọn, theo thứ tự xuất hiện
+	KeySentences []string `json:"key_sentences"` // Các câu quan trọng nhất, theo điểm giảm dần
+}
+
+type SummarizeOptions struct {
+	MaxSentences int
+	MaxChars     int
+}
+
+const (
+	textRankDamping      = 0.85
+	textRankIterations   = 50
+	textRankTolerance    = 1e-4
+	minSentenceTokens    = 4   // Câu quá ngắn (tiêu đề, mảnh vụn) không được chọn
+	textRankMaxSentences = 800 // Số câu tối đa trong 1 đồ thị TextRank
+)
+
+// Từ dừng tiếng Việt (theo âm tiết) và tiếng Anh thông dụng, không mang nội dung
+var vietnameseStopwords = toSet(strings.Fields(`
+	và của là có cho các những một được trong với này đã đang sẽ thì mà ở tại từ đến khi như cũng
+	không để ra vào lên nên nhưng hoặc hay vì do bởi theo về trên dưới rất lại còn chỉ đó đây kia ấy
+	nào gì ai sau trước nhiều ít mỗi cả việc điều thế vậy rằng bị vẫn đều cùng nếu thì là lúc nữa
+	hơn nhất rồi thôi chứ à ạ nhé nhỉ vâng ừ the a an of to in and or is are was were be been for on
+	with as by at from that this it its not but
+`))
+
+// Dấu kết câu theo sau bởi khoảng trắng (xuống dòng được tách riêng)
+var sentenceBoundaryRe = regexp.MustCompile(`([.!?…]+["”’)]?)\s+`)
+
+// GenerateSummary nhận nội dung text và trả về tóm tắt + câu chính cùng nguồn tạo ra tóm tắt
+// (ID phiên bản mẫu prompt, "builtin" hoặc "extractive"). Câu chính luôn chọn offline bằng TextRank
+func GenerateSummary(content string, pc PromptContext) (SummaryResult, string, error) {
+	if strings.TrimSpace(content) == "" {
+		return SummaryResult{}, "", fmt.Errorf("nội dung rỗng")
+	}
+
+	cfg := config.GetSummaryConfig()
+	result := ExtractiveSummarize(content, SummarizeOptions{MaxSentences: cfg.MaxSentences, MaxChars: cfg.MaxChars})
+	if cfg.Mode == "gemini" || cfg.Mode == "llm" {
+		if pc.Vars.DoDai <= 0 {
+			pc.Vars.DoDai = cfg.MaxChars
+		}
+		summary, source, err := summarizeWithGemini(content, pc)
+		if err == nil && summary != "" {
+			result.Summary = summary
+			return result, source, nil
+		}
+		log.Println("Tóm tắt bằng LLM lỗi, dùng tóm tắt offline:", err)
+	}
+
+	if result.Summary == "" {
+		return SummaryResult{}, "", errors.New("không tạo được tóm tắt")
+	}
+	return result, PromptSourceExtractive, nil
+}
+
+// KeySentencesJSON mã hoá câu chính để lưu vào cột CauChinh
+func KeySentencesJSON(key []string) string {
+	if len(key) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(key)
+	return string(data)
+}
+
+// DecodeKeySentences đọc cột CauChinh (rỗng hoặc lỗi = không có câu chính)
+func DecodeKeySentences(raw string) []string {
+	key := []string{}
+	if raw != "" {
+		json.Unmarshal([]byte(raw), &key)
+	}
+	return key
+}
+
+// summarizeWithGemini là tầng tóm tắt chất lượng cao qua LLM đã cấu hình (LLM_PROVIDER), prompt lấy từ mẫu loại summary
+func summarizeWithGemini(content string, pc PromptContext) (string, string, error) {
+	prompt, err := pc.Render(PromptSummary)
+	if err != nil {
+		return "", "", err
+	}
+	summary, err := LLMGenerateText(context.Background(), prompt.Text, content)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(summary), prompt.Source, nil
+}
+
+// ExtractiveSummarize chọn các câu quan trọng bằng TextRank, không cần mạng
+func ExtractiveSummarize(text string, opts SummarizeOptions) SummaryResult {
+	if opts.MaxSentences <= 0 {
+		opts.MaxSentences = 5
+	}
+
+	sentences := SplitSentences(text)
+	if len(sentences) == 0 {
+		return SummaryResult{}
+	}
+
+	tokens := make([]map[string]float64, len(sentences))
+	for i, s := range sentences {
+		tokens[i] = sentenceTerms(s)
+	}
+
+	// Văn bản dài: chọn ứng viên theo từng khối trước để tránh đồ thị n² quá lớn
+	candidates := make([]int, len(sentences))
+	for i := range candidates {
+		candidates[i] = i
+	}
+	if len(sentences) > textRankMaxSentences {
+		candidates = nil
+		perBlock := opts.MaxSentences * 3
+		for start := 0; start < len(sentences); start += textRankMaxSentences {
+			end := start + textRankMaxSentences
+			if end > len(sentences) {
+				end = len(sentences)
+			}
+			block := make([]int, 0, end-start)
+			for i := start; i < end; i++ {
+				block = append(block, i)
+			}
+			candidates = append(candidates, rankSentences(block, tokens)[:min(perBlock, len(block))]...)
+		}
+		sort.Ints(candidates)
+	}
+	order := rankSentences(candidates, tokens)
+
+	var chosen []int
+	total := 0
+	for _, idx := range order {
+		if len(chosen) >= opts.MaxSentences {
+			break
+		}
+		if countTerms(tokens[idx]) < minSentenceTokens && len(sentences) > opts.MaxSentences {
+			continue
+		}
+		n := utf8.RuneCountInString(sentences[idx])
+		if opts.MaxChars > 0 && total > 0 && total+1+n > opts.MaxChars {
+			continue
+		}
+		chosen = append(chosen, idx)
+		total += n + 1
+	}
+
+	// Chưa chọn được câu nào (câu đầu dài hơn giới hạn): cắt câu tốt nhất
+	if len(chosen) == 0 {
+		best := sentences[order[0]]
+		if opts.MaxChars > 0 {
+			best = truncateAtWord(best, opts.MaxChars)
+		}
+		return SummaryResult{Summary: best, KeySentences: []string{best}}
+	}
+
+	key := make([]string, len(chosen))
+	for i, idx := range chosen {
+		key[i] = sentences[idx]
+	}
+
+	sort.Ints(chosen)
+	parts := make([]string, len(chosen))
+	for i, idx := range chosen {
+		parts[i] = sentences[idx]
+	}
+	return SummaryResult{Summary: strings.Join(parts, " "), KeySentences: key}
+}
+
+// rankSentences chạy TextRank trên tập câu idx, trả về chỉ số câu theo điểm giảm dần
+func rankSentences(idx []int, tokens []map[string]float64) []int {
+	terms := make([]map[string]float64, len(idx))
+	for i, id := range idx {
+		terms[i] = tokens[id]
+	}
+	scores := textRankScores(terms)
+
+	// Ưu tiên nhẹ các câu mở đầu (thường nêu chủ đề)
+	for i, id := range idx {
+		scores[i] *= 1 + 0.2/float64(id+1)
+	}
+
+	order := make([]int, len(idx))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	for i, o := range order {
+		order[i] = idx[o]
+	}
+	return order
+}
+
+// SplitSentences tách văn bản thành câu theo dấu kết câu và xuống dòng
+func SplitSentences(text string) []string {
+	var sentences []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		start := 0
+		for _, m := range sentenceBoundaryRe.FindAllStringSubmatchIndex(line, -1) {
+			end := m[3] // Hết dấu câu
+			next, _ := utf8.DecodeRuneInString(line[m[1]:])
+			// Chỉ ngắt khi câu sau bắt đầu bằng chữ hoa, số hoặc ngoặc (tránh ngắt sau "v.v." giữa câu)
+			if !(unicode.IsUpper(next) || unicode.IsDigit(next) || strings.ContainsRune("\"“‘(", next)) {
+				continue
+			}
+			if s := strings.TrimSpace(line[start:end]); s != "" {
+				sentences = append(sentences, s)
+			}
+			start = m[1]
+		}
+		if s := strings.TrimSpace(line[start:]); s != "" {
+			sentences = append(sentences, s)
+		}
+	}
+	return sentences
+}
+
+// TokenizeVietnamese tách âm tiết (chữ thường, bỏ dấu câu) và bỏ từ dừng
+func TokenizeVietnamese(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		if !vietnameseStopwords[w] {
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+// sentenceTerms: âm tiết + cặp âm tiết liền nhau (bắt được từ ghép như "kinh tế", "giáo dục")
+func sentenceTerms(sentence string) map[string]float64 {
+	terms := map[string]float64{}
+	tokens := TokenizeVietnamese(sentence)
+	for i, t := range tokens {
+		terms[t]++
+		if i+1 < len(tokens) {
+			terms[t+" "+tokens[i+1]] += 1.5 // Cụm 2 âm tiết mang nghĩa rõ hơn âm tiết đơn
+		}
+	}
+	return terms
+}
+
+func countTerms(terms map[string]float64) int {
+	n := 0
+	for t := range terms {
+		if !strings.Contains(t, " ") {
+			n++
+		}
+	}
+	return n
+}
+
+// textRankScores chạy PageRank trên đồ thị câu, trọng số cạnh là độ trùng từ chuẩn hoá theo độ dài câu
+func textRankScores(terms []map[string]float64) []float64 {
+	n := len(terms)
+	weights := make([][]float64, n)
+	for i := range weights {
+		weights[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			w := sentenceSimilarity(terms[i], terms[j])
+			weights[i][j], weights[j][i] = w, w
+		}
+	}
+
+	outSum := make([]float64, n)
+	for i := range weights {
+		for _, w := range weights[i] {
+			outSum[i] += w
+		}
+	}
+
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1
+	}
+	for iter := 0; iter < textRankIterations; iter++ {
+		next := make([]float64, n)
+		delta := 0.0
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				if weights[j][i] > 0 && outSum[j] > 0 {
+					sum += weights[j][i] / outSum[j] * scores[j]
+				}
+			}
+			next[i] = (1 - textRankDamping) + textRankDamping*sum
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores = next
+		if delta < textRankTolerance {
+			break
+		}
+	}
+	return scores
+}
+
+// sentenceSimilarity theo công thức TextRank: tổng trọng số từ chung / (log|Si| + log|Sj|)
+func sentenceSimilarity(a, b map[string]float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+	common := 0.0
+	for t, wa := range a {
+		if wb, ok := b[t]; ok {
+			common += math.Min(wa, wb)
+		}
+	}
+	if common == 0 {
+		return 0
+	}
+	return common / (math.Log(float64(len(a))) + math.Log(float64(len(b))))
+}
+
+func truncateAtWord(s string, maxChars int) string {
+	if utf8.RuneCountInString(s) <= maxChars {
+		return s
+	}
+	cut := string([]rune(s)[:maxChars])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "..."
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, it := range items {
+		set[it] = true
+	}
+	return set
+}
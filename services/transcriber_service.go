@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Huong3203/APIPodcast/config"
+	tcmp3 "github.com/tcolgate/mp3"
+	"google.golang.org/api/option"
+	speech "google.golang.org/api/speech/v1p1beta1"
+)
+
+// Định dạng file ghi âm được hỗ trợ
+type AudioFormat string
+
+const (
+	AudioMP3 AudioFormat = "mp3"
+	AudioWAV AudioFormat = "wav"
+)
+
+// Giới hạn nội dung audio gửi trực tiếp (inline) cho Google Speech-to-Text
+const maxInlineAudioBytes = 10 * 1024 * 1024
+
+var (
+	ErrUnsupportedAudio = errors.New("định dạng audio không hỗ trợ (chỉ nhận mp3, wav)")
+	ErrAudioTooLarge    = errors.New("file audio quá lớn để nhận dạng (tối đa 10MB)")
+	ErrEmptyTranscript  = errors.New("không nhận dạng được lời nói trong file audio")
+)
+
+// Transcriber chuyển file ghi âm thành văn bản
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, format AudioFormat) (string, error)
+}
+
+// NewTranscriber chọn transcriber theo cấu hình STT_PROVIDER
+func NewTranscriber(cfg config.STTConfig) (Transcriber, error) {
+	switch cfg.Provider {
+	case "google", "":
+		return &GoogleTranscriber{LanguageCode: cfg.LanguageCode, PollInterval: cfg.PollInterval}, nil
+	case "fake":
+		return &FakeTranscriber{Text: cfg.FakeText}, nil
+	default:
+		return nil, fmt.Errorf("STT_PROVIDER không hợp lệ: %s", cfg.Provider)
+	}
+}
+
+// TranscribeAudio nhận dạng định dạng file rồi gọi transcriber theo cấu hình
+func TranscribeAudio(data []byte) (string, error) {
+	format, err := DetectAudioFormat(data)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := config.GetSTTConfig()
+	t, err := NewTranscriber(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	text, err := t.Transcribe(ctx, data, format)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", ErrEmptyTranscript
+	}
+	return text, nil
+}
+
+// DetectAudioFormat xác định mp3/wav theo header của file (không tin vào phần mở rộng)
+func DetectAudioFormat(data []byte) (AudioFormat, error) {
+	if len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE" {
+		return AudioWAV, nil
+	}
+	if len(data) >= 3 && string(data[0:3]) == "ID3" {
+		return AudioMP3, nil
+	}
+	if len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 {
+		return AudioMP3, nil
+	}
+	return "", ErrUnsupportedAudio
+}
+
+// GoogleTranscriber dùng Google Cloud Speech-to-Text (v1p1beta1 để hỗ trợ MP3)
+type GoogleTranscriber struct {
+	LanguageCode string
+	PollInterval time.Duration
+}
+
+func (g *GoogleTranscriber) Transcribe(ctx context.Context, audio []byte, format AudioFormat) (string, error) {
+	if len(audio) > maxInlineAudioBytes {
+		return "", ErrAudioTooLarge
+	}
+
+	jsonCreds := os.Getenv("GOOGLE_CREDENTIALS_JSON")
+	if jsonCreds == "" {
+		return "", errors.New("GOOGLE_CREDENTIALS_JSON environment variable is not set")
+	}
+
+	svc, err := speech.NewService(ctx, option.WithCredentialsJSON([]byte(jsonCreds)))
+	if err != nil {
+		return "", err
+	}
+
+	recCfg, err := speechRecognitionConfig(audio, format, g.LanguageCode)
+	if err != nil {
+		return "", err
+	}
+
+	// Dùng nhận dạng dài (long running) vì Recognize chỉ nhận audio dưới 1 phút
+	op, err := svc.Speech.Longrunningrecognize(&speech.LongRunningRecognizeRequest{
+		Config: recCfg,
+		Audio:  &speech.RecognitionAudio{Content: base64.StdEncoding.EncodeToString(audio)},
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("lỗi gửi yêu cầu nhận dạng giọng nói: %w", err)
+	}
+
+	interval := g.PollInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("quá thời gian chờ nhận dạng giọng nói: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+		op, err = svc.Operations.Get(op.Name).Context(ctx).Do()
+		if err != nil {
+			return "", fmt.Errorf("lỗi kiểm tra kết quả nhận dạng: %w", err)
+		}
+	}
+	if op.Error != nil {
+		return "", fmt.Errorf("nhận dạng giọng nói thất bại: %s", op.Error.Message)
+	}
+
+	var resp speech.LongRunningRecognizeResponse
+	if err := json.Unmarshal(op.Response, &resp); err != nil {
+		return "", fmt.Errorf("không đọc được kết quả nhận dạng: %w", err)
+	}
+
+	// Mỗi result là 1 đoạn audio liên tiếp, lấy phương án tốt nhất và giữ thành đoạn văn riêng
+	var paragraphs []string
+	for _, r := range resp.Results {
+		if len(r.Alternatives) == 0 {
+			continue
+		}
+		if text := strings.TrimSpace(r.Alternatives[0].Transcript); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n"), nil
+}
+
+// speechRecognitionConfig đọc sample rate/số kênh từ header để Google giải mã đúng
+func speechRecognitionConfig(audio []byte, format AudioFormat, languageCode string) (*speech.RecognitionConfig, error) {
+	if languageCode == "" {
+		languageCode = "vi-VN"
+	}
+	cfg := &speech.RecognitionConfig{
+		LanguageCode:               languageCode,
+		EnableAutomaticPunctuation: true,
+	}
+
+	switch format {
+	case AudioWAV:
+		rate, channels, err := readWAVFormat(audio)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Encoding = "LINEAR16"
+		cfg.SampleRateHertz = rate
+		if channels > 1 {
+			cfg.AudioChannelCount = channels
+		}
+	case AudioMP3:
+		rate, err := readMP3SampleRate(audio)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Encoding = "MP3"
+		cfg.SampleRateHertz = rate
+	default:
+		return nil, ErrUnsupportedAudio
+	}
+	return cfg, nil
+}
+
+// readWAVFormat đọc chunk "fmt " của file WAV PCM
+func readWAVFormat(data []byte) (int64, int64, error) {
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := pos + 8
+		if id == "fmt " {
+			if body+16 > len(data) {
+				break
+			}
+			audioFormat := binary.LittleEndian.Uint16(data[body : body+2])
+			if audioFormat != 1 {
+				return 0, 0, errors.New("file wav phải ở dạng PCM 16-bit")
+			}
+			// LINEAR16 chỉ nhận mẫu 16-bit, gửi 8/24/32-bit sẽ bị đọc sai thành tiếng rè
+			if bits := binary.LittleEndian.Uint16(data[body+14 : body+16]); bits != 16 {
+				return 0, 0, fmt.Errorf("file wav phải ở dạng PCM 16-bit (file có %d-bit)", bits)
+			}
+			channels := int64(binary.LittleEndian.Uint16(data[body+2 : body+4]))
+			rate := int64(binary.LittleEndian.Uint32(data[body+4 : body+8]))
+			return rate, channels, nil
+		}
+		// Chunk có kích thước lẻ được đệm thêm 1 byte
+		pos = body + size + size%2
+	}
+	return 0, 0, errors.New("file wav không hợp lệ: thiếu chunk fmt")
+}
+
+// readMP3SampleRate đọc sample rate từ frame MP3 đầu tiên
+func readMP3SampleRate(data []byte) (int64, error) {
+	dec := tcmp3.NewDecoder(bytes.NewReader(data))
+	var f tcmp3.Frame
+	skipped := 0
+	if err := dec.Decode(&f, &skipped); err != nil {
+		if err == io.EOF {
+			return 0, errors.New("file mp3 không có frame audio")
+		}
+		return 0, fmt.Errorf("file mp3 không hợp lệ: %w", err)
+	}
+	return int64(f.Header().SampleRate()), nil
+}
+
+// FakeTranscriber trả về nội dung cố định, dùng khi chạy local hoặc kiểm thử
+type FakeTranscriber struct {
+	Text string
+}
+
+func (f *FakeTranscriber) Transcribe(ctx context.Context, audio []byte, format AudioFormat) (string, error) {
+	if f.Text != "" {
+		return f.Text, nil
+	}
+	return fmt.Sprintf("Đây là bản ghi giả lập cho file %s dung lượng %d byte.", format, len(audio)), nil
+}
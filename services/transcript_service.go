@@ -0,0 +1,177 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Huong3203/APIPodcast/models"
+)
+
+// Phụ đề: mỗi câu của văn bản gốc được căn theo thời lượng thực của các chunk audio trong cùng phần,
+// vị trí trong phần tính theo độ dài lời đọc (sau khi chuẩn hoá số, từ điển phát âm)
+
+// Câu dài hơn giới hạn này được tách thành nhiều dòng phụ đề
+const maxCueRunes = 120
+
+// 1 dòng phụ đề chưa có thời gian: text hiển thị và độ dài lời đọc tương ứng
+type transcriptCue struct {
+	text   string
+	weight int
+}
+
+var ssmlTagStripRe = regexp.MustCompile(`<[^>]*>`)
+
+var ssmlUnescaper = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'")
+
+// transcriptCues tách 1 phần văn bản thành các câu (tiêu đề là 1 dòng riêng) kèm độ dài lời đọc
+func transcriptCues(text string, lex *Lexicon, lang string) []transcriptCue {
+	var cues []transcriptCue
+	for _, para := range plainParagraphSplitRe.Split(text, -1) {
+		for _, sentence := range SplitSentences(para) {
+			for _, piece := range splitCueText(normalizeSpaces(sentence), maxCueRunes) {
+				weight := utf8.RuneCountInString(PrepareSpeechText(piece, lex, lang))
+				cues = append(cues, transcriptCue{text: piece, weight: max(weight, 1)})
+			}
+		}
+	}
+	return cues
+}
+
+// splitCueText tách câu dài ở dấu phẩy/chấm phẩy/hai chấm gần giới hạn nhất, không có thì ở khoảng trắng
+func splitCueText(s string, maxRunes int) []string {
+	var pieces []string
+	for utf8.RuneCountInString(s) > maxRunes {
+		limit := len(string([]rune(s)[:maxRunes]))
+		cut := strings.LastIndexAny(s[:limit], ",;:")
+		if cut < limit/2 {
+			cut = strings.LastIndexByte(s[:limit], ' ')
+		} else {
+			cut++ // Giữ dấu câu ở dòng trước
+		}
+		if cut <= 0 {
+			cut = limit
+		}
+		pieces = append(pieces, strings.TrimSpace(s[:cut]))
+		s = strings.TrimSpace(s[cut:])
+	}
+	if s != "" {
+		pieces = append(pieces, s)
+	}
+	return pieces
+}
+
+// buildTranscript gán thời gian cho các câu: mỗi phần nội suy tuyến tính trên các chunk của phần đó
+func buildTranscript(cues [][]transcriptCue, chunks []synthesisChunk, durations []float64) []models.DongPhuDe {
+	starts := make([]float64, len(durations))
+	lengths := make([]float64, len(durations))
+	var elapsed float64
+	for i, d := range durations {
+		starts[i] = elapsed
+		elapsed += d
+		spoken := chunks[i].req.Text
+		if chunks[i].req.SSML {
+			spoken = ssmlUnescaper.Replace(ssmlTagStripRe.ReplaceAllString(spoken, " "))
+		}
+		lengths[i] = float64(max(utf8.RuneCountInString(normalizeSpaces(spoken)), 1))
+	}
+
+	var lines []models.DongPhuDe
+	first := 0
+	for sec, secCues := range cues {
+		last := first
+		for last < len(chunks) && chunks[last].section == sec {
+			last++
+		}
+		if last == first || len(secCues) == 0 {
+			first = last
+			continue
+		}
+
+		var total, weights float64
+		for i := first; i < last; i++ {
+			total += lengths[i]
+		}
+		for _, cue := range secCues {
+			weights += float64(cue.weight)
+		}
+
+		// Thời điểm (giây) ứng với vị trí pos trong lời đọc của phần
+		timeAt := func(pos float64) float64 {
+			for i := first; i < last; i++ {
+				if pos <= lengths[i] || i == last-1 {
+					return starts[i] + min(pos/lengths[i], 1)*durations[i]
+				}
+				pos -= lengths[i]
+			}
+			return starts[last-1] + durations[last-1]
+		}
+
+		var done float64
+		for _, cue := range secCues {
+			from := int(timeAt(done/weights*total) * 1000)
+			done += float64(cue.weight)
+			to := int(timeAt(done/weights*total) * 1000)
+			if to <= from {
+				continue
+			}
+			lines = append(lines, models.DongPhuDe{
+				ThuTu:     len(lines) + 1,
+				BatDauMs:  from,
+				KetThucMs: to,
+				NoiDung:   cue.text,
+			})
+		}
+		first = last
+	}
+	return lines
+}
+
+// EncodeTranscript chuyển phụ đề thành JSON để lưu vào cột PhuDe ("" nếu không có)
+func EncodeTranscript(lines []models.DongPhuDe) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(lines)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// DecodeTranscript đọc cột PhuDe, luôn trả về slice (không nil)
+func DecodeTranscript(raw string) []models.DongPhuDe {
+	lines := []models.DongPhuDe{}
+	if raw != "" {
+		_ = json.Unmarshal([]byte(raw), &lines)
+	}
+	return lines
+}
+
+// FormatWebVTT xuất phụ đề theo chuẩn WebVTT (text/vtt)
+func FormatWebVTT(lines []models.DongPhuDe) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n\n")
+	for _, l := range lines {
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", l.ThuTu, formatCueTime(l.BatDauMs, '.'), formatCueTime(l.KetThucMs, '.'), vttEscaper.Replace(l.NoiDung))
+	}
+	return sb.String()
+}
+
+// FormatSRT xuất phụ đề theo chuẩn SubRip (.srt)
+func FormatSRT(lines []models.DongPhuDe) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", l.ThuTu, formatCueTime(l.BatDauMs, ','), formatCueTime(l.KetThucMs, ','), l.NoiDung)
+	}
+	return sb.String()
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// formatCueTime: hh:mm:ss.mmm (WebVTT) hoặc hh:mm:ss,mmm (SRT)
+func formatCueTime(ms int, sep byte) string {
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/Huong3203/APIPodcast/ws"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bản dịch podcast: nội dung đã làm sạch của podcast gốc được LLM dịch sang ngôn ngữ khác và đọc bằng giọng của ngôn ngữ đó.
+// Mỗi bản dịch có tài liệu riêng (DichTuID = tài liệu gốc) và chạy qua hàng đợi job: dịch -> tóm tắt -> audio -> tạo podcast,
+// podcast bản dịch trỏ về podcast gốc qua BanGocID
+
+var (
+	ErrUnsupportedLanguage = errors.New("ngôn ngữ không được hỗ trợ (vi, en, fr, de, es)")
+	ErrSameLanguage        = errors.New("podcast gốc đã ở ngôn ngữ này")
+	ErrTranslationExists   = errors.New("podcast đã có hoặc đang tạo bản dịch ở ngôn ngữ này")
+	ErrVoiceLanguage       = errors.New("giọng đọc không thuộc ngôn ngữ của bản dịch")
+	ErrTranslationFormat   = errors.New("bản dịch giữ định dạng của podcast gốc, không đổi được định dạng")
+	ErrTranslationCut      = errors.New("bản dịch bị cắt cụt")
+)
+
+// Số ký tự tối đa gửi LLM dịch trong 1 lần: văn bản dài được dịch theo từng nhóm đoạn văn
+// để bản dịch không vượt giới hạn token đầu ra của model
+const translateBatchRunes = 6000
+
+// Tuỳ chọn tạo bản dịch
+type TranslateOptions struct {
+	NgonNgu      string  // Ngôn ngữ đích ("en", "vi"...)
+	Voice        string  // Rỗng = giọng mặc định của ngôn ngữ đích
+	VoiceB       string  // Giọng người dẫn thứ 2 khi podcast gốc là hội thoại
+	SpeakingRate float64 // 0 = giữ tốc độ của podcast gốc
+}
+
+// 1 phiên bản ngôn ngữ của podcast (bản gốc hoặc bản dịch)
+type PodcastLanguage struct {
+	NgonNgu   string `json:"ngon_ngu"`
+	PodcastID string `json:"podcast_id"`
+	TieuDe    string `json:"tieu_de"`
+	BanGoc    bool   `json:"ban_goc"`
+}
+
+func IsSupportedLanguage(lang string) bool {
+	_, ok := languageNames[lang]
+	return ok
+}
+
+// TranslatePodcast tạo tài liệu + job dịch cho podcast; podcast bản dịch được tạo (trạng thái Tắt) khi job hoàn tất.
+// Dịch bản dịch thì lấy nội dung từ podcast gốc để không dịch chồng
+func TranslatePodcast(db *gorm.DB, podcastID, userID string, opts TranslateOptions) (*models.TaiLieu, *models.ProcessingJob, error) {
+	opts.NgonNgu = strings.ToLower(strings.TrimSpace(opts.NgonNgu))
+	if !IsSupportedLanguage(opts.NgonNgu) {
+		return nil, nil, ErrUnsupportedLanguage
+	}
+
+	var podcast models.Podcast
+	if err := db.Select("id, ban_goc_id").First(&podcast, "id = ?", podcastID).Error; err != nil {
+		return nil, nil, err
+	}
+	if podcast.BanGocID != "" {
+		podcastID = podcast.BanGocID
+	}
+	src, err := loadRevoiceSource(db, podcastID)
+	if err != nil {
+		return nil, nil, err
+	}
+	goc := &src.podcast
+
+	// Podcast tạo trước khi có nhận diện ngôn ngữ: xác định và lưu lại để lọc theo ngôn ngữ
+	if goc.NgonNgu == "" {
+		goc.NgonNgu = src.doc.NgonNgu
+		if goc.NgonNgu == "" {
+			goc.NgonNgu = DetectLanguage(src.text)
+		}
+		if goc.NgonNgu == "" {
+			goc.NgonNgu = "vi"
+		}
+		db.Model(goc).Update("ngon_ngu", goc.NgonNgu)
+	}
+	if opts.NgonNgu == goc.NgonNgu {
+		return nil, nil, ErrSameLanguage
+	}
+
+	if opts.Voice == "" {
+		opts.Voice = DefaultVoiceFor(goc.DanhMucID, opts.NgonNgu)
+	}
+	for _, voice := range []string{opts.Voice, opts.VoiceB} {
+		if voice != "" && VoiceLanguage(voice) != opts.NgonNgu {
+			return nil, nil, fmt.Errorf("%w: %s", ErrVoiceLanguage, voice)
+		}
+	}
+	if opts.SpeakingRate <= 0 {
+		opts.SpeakingRate = src.lastJob.SpeakingRate
+	}
+	if opts.SpeakingRate <= 0 {
+		opts.SpeakingRate = 1.0
+	}
+	format := src.lastJob.DinhDang
+	if format == "" {
+		format = FormatNarration
+	}
+
+	doc := models.TaiLieu{
+		ID:            uuid.New().String(),
+		TenFileGoc:    src.doc.TenFileGoc,
+		DuongDanFile:  src.doc.DuongDanFile,
+		LoaiFile:      src.doc.LoaiFile,
+		KichThuocFile: src.doc.KichThuocFile,
+		NgonNgu:       opts.NgonNgu,
+		DichTuID:      src.doc.ID,
+		TrangThai:     "Đã tải lên",
+		NguoiTaiLen:   userID,
+	}
+	job := models.ProcessingJob{
+		ID:             uuid.New().String(),
+		TaiLieuID:      doc.ID,
+		Status:         JobPending,
+		Stage:          StageTranslate,
+		MaxAttempts:    config.GetJobQueueConfig().MaxAttempts,
+		NextRunAt:      time.Now(),
+		NoiDungTho:     src.text,
+		Voice:          opts.Voice,
+		VoiceB:         opts.VoiceB,
+		DinhDang:       format,
+		SpeakingRate:   opts.SpeakingRate,
+		NguoiTao:       userID,
+		GiongVan:       src.lastJob.GiongVan,
+		TieuDe:         goc.TieuDe,
+		MoTa:           goc.MoTa,
+		DanhMucID:      goc.DanhMucID,
+		HinhAnhDaiDien: goc.HinhAnhDaiDien,
+		TheTag:         goc.TheTag,
+		PodcastID:      uuid.New().String(),
+		BanGocID:       goc.ID,
+	}
+
+	// Khoá dòng podcast gốc để kiểm tra bản dịch đã có và tạo bản dịch mới trong cùng 1 giao dịch
+	// (2 yêu cầu đồng thời không tạo 2 bản dịch cùng ngôn ngữ)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var locked models.Podcast
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, "id = ?", goc.ID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.Podcast{}).
+			Where("ban_goc_id = ? AND ngon_ngu = ?", goc.ID, opts.NgonNgu).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			if err := tx.Model(&models.ProcessingJob{}).
+				Joins("JOIN tai_lieus ON tai_lieus.id = processing_jobs.tai_lieu_id").
+				Where("processing_jobs.ban_goc_id = ? AND processing_jobs.status IN ? AND tai_lieus.ngon_ngu = ?",
+					goc.ID, []string{JobPending, JobRunning}, opts.NgonNgu).
+				Count(&count).Error; err != nil {
+				return err
+			}
+		}
+		if count > 0 {
+			return ErrTranslationExists
+		}
+		if err := tx.Create(&doc).Error; err != nil {
+			return fmt.Errorf("không thể tạo bản dịch: %w", err)
+		}
+		if err := tx.Create(&job).Error; err != nil {
+			return fmt.Errorf("không thể tạo bản dịch: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ws.SendStatusUpdate(doc.ID, "Đã xếp hàng dịch podcast", 10, "")
+	ws.BroadcastDocumentListChanged()
+	wakeDocumentWorkers()
+	return &doc, &job, nil
+}
+
+// runTranslateStage dịch nội dung gốc (job.NoiDungTho), tiêu đề và mô tả sang ngôn ngữ của tài liệu bản dịch
+func runTranslateStage(db *gorm.DB, job *models.ProcessingJob, doc *models.TaiLieu) error {
+	ws.SendStatusUpdate(doc.ID, fmt.Sprintf("Đang dịch nội dung sang %s...", LanguageName(doc.NgonNgu)), 30, "")
+	pc := buildPromptContext(db, job, doc)
+
+	text, source, err := TranslateText(job.NoiDungTho, pc)
+	if err != nil {
+		return err
+	}
+	if job.DinhDang == FormatDialogue {
+		// Kịch bản dịch phải còn đủ lượt nói của 2 người dẫn
+		turns := ParseDialogueScript(text)
+		speakers := map[string]bool{}
+		for _, t := range turns {
+			speakers[t.Speaker] = true
+		}
+		if !speakers[SpeakerA] || !speakers[SpeakerB] {
+			return ErrInvalidDialogueScript
+		}
+		text = FormatDialogueScript(turns)
+	}
+
+	tieuDe, moTa := job.TieuDe, job.MoTa
+	if tieuDe != "" {
+		if tieuDe, _, err = TranslateText(tieuDe, pc); err != nil {
+			return err
+		}
+		tieuDe = firstLine(tieuDe, 255)
+	}
+	if moTa != "" {
+		if moTa, _, err = TranslateText(moTa, pc); err != nil {
+			return err
+		}
+	}
+
+	doc.NoiDungTrichXuat = text
+	doc.MauKichBan = source
+	doc.TrangThai = "Đã trích xuất"
+	if err := db.Model(doc).Updates(map[string]interface{}{
+		"TrangThai":        doc.TrangThai,
+		"NoiDungTrichXuat": text,
+		"MauKichBan":       source,
+	}).Error; err != nil {
+		return err
+	}
+	job.TieuDe, job.MoTa = tieuDe, moTa
+	if err := db.Model(job).Updates(map[string]interface{}{"tieu_de": tieuDe, "mo_ta": moTa}).Error; err != nil {
+		return err
+	}
+
+	ws.SendStatusUpdate(doc.ID, "Đã dịch nội dung", 40, "")
+	ws.BroadcastDocumentListChanged()
+	return nil
+}
+
+// TranslateText nhờ LLM dịch văn bản sang pc.Vars.NgonNgu theo từng nhóm đoạn văn, trả kèm phiên bản mẫu prompt đã dùng.
+// Nhóm nào bị cắt (hết token hoặc thiếu dòng so với bản gốc) thì trả ErrTranslationCut để bước dịch chạy lại
+func TranslateText(text string, pc PromptContext) (string, string, error) {
+	prompt, err := pc.Render(PromptTranslate)
+	if err != nil {
+		return "", "", err
+	}
+	llm, err := DefaultLLM()
+	if err != nil {
+		return "", "", err
+	}
+
+	limit := translateBatchRunes
+	if maxTokens := config.GetLLMConfig().MaxOutputTokens; maxTokens > 0 && maxTokens*2 < limit {
+		// Ước lượng ~2 ký tự/token (tiếng Việt tốn token hơn tiếng Anh)
+		limit = maxTokens * 2
+	}
+	batches := translationBatches(text, limit)
+
+	var sb strings.Builder
+	for i, batch := range batches {
+		resp, err := llm.Generate(context.Background(), LLMRequest{Prompt: prompt.Text, Input: batch.text})
+		if err != nil {
+			return "", "", err
+		}
+		translated := strings.TrimSpace(resp.Text)
+		if translated == "" {
+			return "", "", errors.New("LLM trả về bản dịch rỗng")
+		}
+		if resp.Truncated {
+			return "", "", fmt.Errorf("%w: phần %d/%d vượt giới hạn token của LLM", ErrTranslationCut, i+1, len(batches))
+		}
+		// LLM có thể tách 1 đoạn thành 2 nhưng không được bỏ đoạn
+		if got, want := countTextLines(translated), countTextLines(batch.text); got < want {
+			return "", "", fmt.Errorf("%w: phần %d/%d còn %d/%d dòng", ErrTranslationCut, i+1, len(batches), got, want)
+		}
+		if i > 0 {
+			sb.WriteString(batch.sep)
+		}
+		sb.WriteString(translated)
+	}
+	if sb.Len() == 0 {
+		return "", "", errors.New("LLM trả về bản dịch rỗng")
+	}
+	return sb.String(), prompt.Source, nil
+}
+
+// 1 lần gửi LLM dịch; sep là phần ngăn cách với nhóm trước khi ghép lại ("\n\n" giữa 2 đoạn, "\n" giữa 2 dòng, " " giữa 2 câu)
+type translationBatch struct {
+	text string
+	sep  string
+}
+
+// translationBatches gom các đoạn văn liên tiếp thành nhóm không quá limit ký tự. Đoạn dài hơn limit được tách theo dòng
+// (lượt nói của kịch bản hội thoại), dòng dài hơn limit được tách theo câu
+func translationBatches(text string, limit int) []translationBatch {
+	type piece struct{ text, sep string }
+	var pieces []piece
+	for _, para := range plainParagraphSplitRe.Split(strings.TrimSpace(text), -1) {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		sep := "\n\n"
+		if utf8.RuneCountInString(para) <= limit {
+			pieces = append(pieces, piece{para, sep})
+			continue
+		}
+		for _, line := range strings.Split(para, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			if utf8.RuneCountInString(line) <= limit {
+				pieces = append(pieces, piece{line, sep})
+				sep = "\n"
+				continue
+			}
+			for _, sentence := range SplitSentences(line) {
+				pieces = append(pieces, piece{sentence, sep})
+				sep = " "
+			}
+			sep = "\n"
+		}
+	}
+
+	var batches []translationBatch
+	size := 0
+	for _, p := range pieces {
+		n := utf8.RuneCountInString(p.text)
+		if len(batches) == 0 || size+n > limit {
+			batches = append(batches, translationBatch{text: p.text, sep: p.sep})
+			size = n
+			continue
+		}
+		last := &batches[len(batches)-1]
+		last.text += p.sep + p.text
+		size += n
+	}
+	return batches
+}
+
+// countTextLines đếm số dòng có chữ
+func countTextLines(text string) int {
+	n := 0
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// firstLine lấy dòng đầu (LLM đôi khi trả thêm ghi chú) và cắt theo độ dài cột
+func firstLine(s string, maxRunes int) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	s = strings.Trim(strings.TrimSpace(s), `"*`)
+	if r := []rune(s); len(r) > maxRunes {
+		s = string(r[:maxRunes])
+	}
+	return s
+}
+
+// PodcastLanguages trả về các phiên bản ngôn ngữ của podcast: bản gốc trước, rồi các bản dịch.
+// publishedOnly: chỉ lấy podcast đang bật (người dùng thường)
+func PodcastLanguages(db *gorm.DB, podcast *models.Podcast, publishedOnly bool) []PodcastLanguage {
+	gocID := podcast.ID
+	if podcast.BanGocID != "" {
+		gocID = podcast.BanGocID
+	}
+
+	var podcasts []models.Podcast
+	query := db.Select("id, tieu_de, ngon_ngu, ban_goc_id, trang_thai").
+		Where("id = ? OR ban_goc_id = ?", gocID, gocID)
+	if publishedOnly {
+		query = query.Where("trang_thai = ? OR id = ?", "Bật", podcast.ID)
+	}
+	query.Order("ngay_tao_ra").Find(&podcasts)
+
+	languages := []PodcastLanguage{}
+	for _, p := range podcasts {
+		lang := p.NgonNgu
+		if lang == "" {
+			lang = "vi"
+		}
+		item := PodcastLanguage{NgonNgu: lang, PodcastID: p.ID, TieuDe: p.TieuDe, BanGoc: p.BanGocID == ""}
+		if item.BanGoc {
+			languages = append([]PodcastLanguage{item}, languages...)
+		} else {
+			languages = append(languages, item)
+		}
+	}
+	return languages
+}
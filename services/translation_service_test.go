@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// stubTranslator giả lập LLM dịch: thêm "[en] " trước mỗi dòng, cut != nil thì cắt bản dịch của lần gọi đó
+type stubTranslator struct {
+	mu     sync.Mutex
+	inputs []string
+	cut    func(call int, out string) (string, bool)
+}
+
+func (s *stubTranslator) Name() string { return "stub" }
+
+func (s *stubTranslator) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	s.mu.Lock()
+	s.inputs = append(s.inputs, req.Input)
+	call := len(s.inputs)
+	s.mu.Unlock()
+
+	lines := strings.Split(req.Input, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = "[en] " + line
+		}
+	}
+	resp := &LLMResponse{Text: strings.Join(lines, "\n"), Model: "stub"}
+	if s.cut != nil {
+		resp.Text, resp.Truncated = s.cut(call, resp.Text)
+	}
+	return resp, nil
+}
+
+func useTestLLM(t *testing.T, llm LLMProvider) {
+	t.Helper()
+	defaultLLMMu.Lock()
+	old := defaultLLM
+	defaultLLM = llm
+	defaultLLMMu.Unlock()
+	t.Cleanup(func() {
+		defaultLLMMu.Lock()
+		defaultLLM = old
+		defaultLLMMu.Unlock()
+	})
+}
+
+func testTranslationText(paragraphs int) string {
+	paras := make([]string, paragraphs)
+	for i := range paras {
+		paras[i] = fmt.Sprintf("Đoạn %d. ", i+1) + strings.Repeat("Nội dung đoạn văn cần dịch sang tiếng Anh. ", 20)
+	}
+	return strings.Join(paras, "\n\n")
+}
+
+func TestTranslateTextBatches(t *testing.T) {
+	stub := &stubTranslator{}
+	useTestLLM(t, stub)
+	t.Setenv("LLM_MAX_OUTPUT_TOKENS", "")
+
+	text := testTranslationText(30)
+	got, source, err := TranslateText(text, PromptContext{Vars: PromptVars{NgonNgu: "en"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != "builtin" {
+		t.Errorf("source = %q", source)
+	}
+	if len(stub.inputs) < 2 {
+		t.Fatalf("văn bản dài phải được dịch nhiều lần, có %d", len(stub.inputs))
+	}
+	for i, in := range stub.inputs {
+		if n := utf8.RuneCountInString(in); n > translateBatchRunes {
+			t.Errorf("lần %d gửi %d ký tự, vượt %d", i+1, n, translateBatchRunes)
+		}
+	}
+
+	// Đủ đoạn, đúng thứ tự, giữ ngắt đoạn
+	paras := plainParagraphSplitRe.Split(got, -1)
+	if len(paras) != 30 {
+		t.Fatalf("bản dịch có %d đoạn, muốn 30", len(paras))
+	}
+	for i, p := range paras {
+		if !strings.HasPrefix(p, fmt.Sprintf("[en] Đoạn %d. ", i+1)) {
+			t.Errorf("đoạn %d = %.40q", i+1, p)
+		}
+	}
+}
+
+func TestTranslateTextBatchLimitFromMaxTokens(t *testing.T) {
+	stub := &stubTranslator{}
+	useTestLLM(t, stub)
+	t.Setenv("LLM_MAX_OUTPUT_TOKENS", "1000")
+
+	if _, _, err := TranslateText(testTranslationText(10), PromptContext{Vars: PromptVars{NgonNgu: "en"}}); err != nil {
+		t.Fatal(err)
+	}
+	for i, in := range stub.inputs {
+		if n := utf8.RuneCountInString(in); n > 2000 {
+			t.Errorf("lần %d gửi %d ký tự, vượt giới hạn theo LLM_MAX_OUTPUT_TOKENS", i+1, n)
+		}
+	}
+}
+
+func TestTranslateTextCut(t *testing.T) {
+	tests := []struct {
+		name string
+		cut  func(call int, out string) (string, bool)
+	}{
+		{"hết token", func(call int, out string) (string, bool) {
+			return out, call == 2
+		}},
+		{"thiếu đoạn cuối", func(call int, out string) (string, bool) {
+			if call == 2 {
+				paras := plainParagraphSplitRe.Split(out, -1)
+				return strings.Join(paras[:len(paras)-1], "\n\n"), false
+			}
+			return out, false
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestLLM(t, &stubTranslator{cut: tt.cut})
+			t.Setenv("LLM_MAX_OUTPUT_TOKENS", "")
+			_, _, err := TranslateText(testTranslationText(30), PromptContext{Vars: PromptVars{NgonNgu: "en"}})
+			if !errors.Is(err, ErrTranslationCut) {
+				t.Fatalf("err = %v, muốn %v", err, ErrTranslationCut)
+			}
+		})
+	}
+}
+
+func TestTranslationBatchesSplitsLongDialogue(t *testing.T) {
+	var turns []string
+	for i := 0; i < 40; i++ {
+		speaker := SpeakerA
+		if i%2 == 1 {
+			speaker = SpeakerB
+		}
+		turns = append(turns, speaker+": "+strings.TrimSpace(strings.Repeat("Lời thoại của người dẫn. ", 8)))
+	}
+	text := strings.Join(turns, "\n")
+
+	batches := translationBatches(text, 1000)
+	if len(batches) < 2 {
+		t.Fatalf("kịch bản dài phải tách nhiều nhóm, có %d", len(batches))
+	}
+	var joined strings.Builder
+	for i, b := range batches {
+		if utf8.RuneCountInString(b.text) > 1000 {
+			t.Errorf("nhóm %d dài %d ký tự", i+1, utf8.RuneCountInString(b.text))
+		}
+		if i > 0 {
+			if b.sep != "\n" {
+				t.Errorf("nhóm %d ngăn cách bằng %q, muốn xuống dòng", i+1, b.sep)
+			}
+			joined.WriteString(b.sep)
+		}
+		joined.WriteString(b.text)
+	}
+	if joined.String() != text {
+		t.Error("ghép các nhóm không ra văn bản gốc")
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+	"github.com/Huong3203/APIPodcast/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bộ nhớ đệm TTS: audio của mỗi chunk lưu trên storage (uploads/tts-cache/<mã băm>), chỉ mục trong bảng BoNhoDemTTS.
+// Lỗi đọc/ghi bộ nhớ đệm không làm hỏng việc tổng hợp, chỉ tính là miss.
+
+// Thống kê bộ nhớ đệm kể từ khi server khởi động
+type TTSCacheStats struct {
+	Hits        int   `json:"hits"`
+	Misses      int   `json:"misses"`
+	Errors      int   `json:"errors"`       // Lỗi đọc/ghi storage hoặc DB
+	BytesServed int64 `json:"bytes_served"` // Tổng số byte audio lấy từ bộ nhớ đệm thay vì gọi TTS
+}
+
+var (
+	ttsCacheMu    sync.Mutex
+	ttsCacheStats TTSCacheStats
+)
+
+// synthesisCacheEnabled: cần bật TTS_CACHE, có DB và cấu hình Supabase
+func synthesisCacheEnabled() bool {
+	return config.GetTTSConfig().Cache && config.DB != nil && os.Getenv("SUPABASE_URL") != ""
+}
+
+// synthesisCacheKey băm mọi tham số ảnh hưởng tới audio đầu ra
+func synthesisCacheKey(engine TTSEngine, req SynthesisRequest) string {
+	ssml := "0"
+	if req.SSML {
+		ssml = "1"
+	}
+	h := sha256.New()
+	for _, part := range []string{
+		engine.Name(),
+		string(engine.OutputFormat()),
+		req.Voice,
+		req.LanguageCode,
+		strconv.FormatFloat(req.SpeakingRate, 'f', 3, 64),
+		ssml,
+		req.Text,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadCachedSynthesis lấy audio đã tổng hợp trước đó (false nếu chưa có hoặc lỗi)
+func loadCachedSynthesis(key string) ([]byte, bool) {
+	var entry models.BoNhoDemTTS
+	if err := config.DB.First(&entry, "ma_bam = ?", key).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			recordTTSCache(false, 0, true)
+		}
+		recordTTSCache(false, 0, false)
+		return nil, false
+	}
+
+	audio, err := utils.DownloadFromSupabase(entry.DuongDan)
+	if err != nil || len(audio) == 0 {
+		log.Printf("Không đọc được bộ nhớ đệm TTS %s: %v\n", entry.DuongDan, err)
+		recordTTSCache(false, 0, true)
+		recordTTSCache(false, 0, false)
+		return nil, false
+	}
+
+	config.DB.Model(&entry).Updates(map[string]interface{}{
+		"so_lan_dung":   gorm.Expr("so_lan_dung + ?", 1),
+		"lan_dung_cuoi": time.Now(),
+	})
+	recordTTSCache(true, len(audio), false)
+	return audio, true
+}
+
+// storeCachedSynthesis lưu audio vừa tổng hợp lên storage và ghi chỉ mục
+func storeCachedSynthesis(key string, engine TTSEngine, req SynthesisRequest, audio []byte) {
+	contentType := "audio/mpeg"
+	if engine.OutputFormat() == AudioWAV {
+		contentType = "audio/wav"
+	}
+	path, err := utils.UploadCacheToSupabase(audio, key+"."+string(engine.OutputFormat()), contentType)
+	if err != nil {
+		log.Printf("Không lưu được bộ nhớ đệm TTS: %v\n", err)
+		recordTTSCache(false, 0, true)
+		return
+	}
+
+	entry := models.BoNhoDemTTS{
+		MaBam:       key,
+		Engine:      engine.Name(),
+		Giong:       req.Voice,
+		TocDo:       req.SpeakingRate,
+		DuongDan:    path,
+		KichThuoc:   len(audio),
+		LanDungCuoi: time.Now(),
+	}
+	// 2 chunk giống nhau có thể được tổng hợp song song: bản ghi sau bỏ qua
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+		log.Printf("Không ghi được chỉ mục bộ nhớ đệm TTS: %v\n", err)
+		recordTTSCache(false, 0, true)
+	}
+}
+
+func recordTTSCache(hit bool, size int, failed bool) {
+	ttsCacheMu.Lock()
+	defer ttsCacheMu.Unlock()
+
+	switch {
+	case failed:
+		ttsCacheStats.Errors++
+	case hit:
+		ttsCacheStats.Hits++
+		ttsCacheStats.BytesServed += int64(size)
+	default:
+		ttsCacheStats.Misses++
+	}
+}
+
+// GetTTSCacheStats trả về bản sao thống kê bộ nhớ đệm hiện tại
+func GetTTSCacheStats() TTSCacheStats {
+	ttsCacheMu.Lock()
+	defer ttsCacheMu.Unlock()
+	return ttsCacheStats
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	texttospeech "cloud.google.com/go/texttospeech/apiv1"
+	texttospeechpb "cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"github.com/Huong3203/APIPodcast/config"
+	"google.golang.org/api/option"
+)
+
+// Giọng mặc định khi job/yêu cầu không chọn giọng.
+// Giọng Chirp HD không nhận SSML: với TTS_SSML=auto (mặc định) giọng này được gửi văn bản thuần
+// (đã chuẩn hoá + từ điển phát âm), nghỉ/nhấn mạnh/say-as của BuildSSML chỉ dùng khi chọn giọng
+// không phải Chirp (Wavenet, Neural2, Standard)
+const defaultVoice = "vi-VN-Chirp3-HD-Puck"
+
+// Giọng đọc engine cung cấp
+type TTSVoice struct {
+	Name         string `json:"name"`
+	LanguageCode string `json:"language_code"`
+	Gender       string `json:"gender,omitempty"`
+}
+
+// 1 lần tổng hợp: Text là văn bản thuần hoặc tài liệu SSML (đã chia vừa MaxChunkBytes)
+type SynthesisRequest struct {
+	Text         string
+	SSML         bool
+	Voice        string
+	LanguageCode string
+	SpeakingRate float64
+}
+
+// TTSEngine là 1 bộ máy đọc văn bản (Google Cloud TTS, lệnh cục bộ, giả lập)
+type TTSEngine interface {
+	Name() string
+	Voices(ctx context.Context) ([]TTSVoice, error)
+	Synthesize(ctx context.Context, req SynthesisRequest) ([]byte, error)
+	SupportsSSML(voice string) bool
+	MaxChunkBytes() int
+	OutputFormat() AudioFormat
+}
+
+var (
+	defaultTTSEngine   TTSEngine
+	defaultTTSEngineMu sync.Mutex
+)
+
+// DefaultTTSEngine trả về engine theo cấu hình TTS_*, dùng chung cho cả tiến trình.
+// Chỉ lưu engine tạo thành công: lỗi cấu hình được thử lại ở lần gọi sau
+func DefaultTTSEngine() (TTSEngine, error) {
+	defaultTTSEngineMu.Lock()
+	defer defaultTTSEngineMu.Unlock()
+	if defaultTTSEngine != nil {
+		return defaultTTSEngine, nil
+	}
+	engine, err := NewTTSEngine(config.GetTTSConfig())
+	if err != nil {
+		return nil, err
+	}
+	defaultTTSEngine = engine
+	return engine, nil
+}
+
+// NewTTSEngine tạo engine theo cấu hình
+func NewTTSEngine(cfg config.TTSConfig) (TTSEngine, error) {
+	switch cfg.Engine {
+	case "google", "":
+		if cfg.GoogleCredentialsJSON == "" {
+			return nil, errors.New("GOOGLE_CREDENTIALS_JSON environment variable is not set")
+		}
+		return &GoogleTTSEngine{CredentialsJSON: cfg.GoogleCredentialsJSON}, nil
+	case "local":
+		if len(cfg.LocalVoices) == 0 {
+			return nil, errors.New("TTS_LOCAL_VOICES chưa được cấu hình")
+		}
+		// Không tìm thấy lệnh thì báo sớm thay vì đợi job lỗi
+		if fields := strings.Fields(cfg.LocalCommand); len(fields) > 0 {
+			if _, err := exec.LookPath(fields[0]); err != nil {
+				log.Printf("⚠️ TTS_ENGINE=local nhưng không tìm thấy lệnh %s: %v", fields[0], err)
+			}
+		}
+		format := AudioFormat(cfg.LocalFormat)
+		if format != AudioMP3 && format != AudioWAV {
+			return nil, fmt.Errorf("TTS_LOCAL_FORMAT không hợp lệ: %s", cfg.LocalFormat)
+		}
+		return &LocalTTSEngine{
+			Command:      cfg.LocalCommand,
+			VoiceNames:   cfg.LocalVoices,
+			LanguageCode: cfg.LocalLanguage,
+			Format:       format,
+			MaxBytes:     cfg.LocalMaxChunkBytes,
+		}, nil
+	case "fake":
+		return &FakeTTSEngine{}, nil
+	default:
+		return nil, fmt.Errorf("TTS_ENGINE không hợp lệ: %s", cfg.Engine)
+	}
+}
+
+// ---------------- Google Cloud TTS ----------------
+
+type GoogleTTSEngine struct {
+	CredentialsJSON string
+
+	mu     sync.Mutex
+	client *texttospeech.Client
+}
+
+func (g *GoogleTTSEngine) Name() string { return "google" }
+
+// Client tạo 1 lần và dùng chung (tạo lại ở lần gọi sau nếu lần trước lỗi)
+func (g *GoogleTTSEngine) getClient() (*texttospeech.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.client != nil {
+		return g.client, nil
+	}
+	client, err := texttospeech.NewClient(context.Background(), option.WithCredentialsJSON([]byte(g.CredentialsJSON)))
+	if err != nil {
+		return nil, err
+	}
+	g.client = client
+	return client, nil
+}
+
+func (g *GoogleTTSEngine) Voices(ctx context.Context) ([]TTSVoice, error) {
+	client, err := g.getClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.ListVoices(ctx, &texttospeechpb.ListVoicesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	voices := make([]TTSVoice, 0, len(resp.Voices))
+	for _, v := range resp.Voices {
+		lang := ""
+		if len(v.LanguageCodes) > 0 {
+			lang = v.LanguageCodes[0]
+		}
+		voices = append(voices, TTSVoice{Name: v.Name, LanguageCode: lang, Gender: strings.ToLower(v.SsmlGender.String())})
+	}
+	return voices, nil
+}
+
+func (g *GoogleTTSEngine) Synthesize(ctx context.Context, req SynthesisRequest) ([]byte, error) {
+	client, err := g.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	input := &texttospeechpb.SynthesisInput{
+		InputSource: &texttospeechpb.SynthesisInput_Text{Text: req.Text},
+	}
+	if req.SSML {
+		input.InputSource = &texttospeechpb.SynthesisInput_Ssml{Ssml: req.Text}
+	}
+
+	resp, err := client.SynthesizeSpeech(ctx, &texttospeechpb.SynthesizeSpeechRequest{
+		Input: input,
+		Voice: &texttospeechpb.VoiceSelectionParams{
+			LanguageCode: req.LanguageCode,
+			Name:         req.Voice,
+		},
+		AudioConfig: &texttospeechpb.AudioConfig{
+			AudioEncoding: texttospeechpb.AudioEncoding_MP3,
+			SpeakingRate:  req.SpeakingRate,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.AudioContent, nil
+}
+
+func (g *GoogleTTSEngine) SupportsSSML(voice string) bool { return UseSSMLForVoice(voice) }
+
+// Dưới ngưỡng 5000 bytes của Google
+func (g *GoogleTTSEngine) MaxChunkBytes() int { return 4500 }
+
+func (g *GoogleTTSEngine) OutputFormat() AudioFormat { return AudioMP3 }
+
+// ---------------- Lệnh cục bộ (piper, espeak-ng...) ----------------
+
+// Tên giọng được chèn vào lệnh shell nên chỉ cho phép ký tự an toàn
+var localVoiceNameRe = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+type LocalTTSEngine struct {
+	Command      string
+	VoiceNames   []string
+	LanguageCode string
+	Format       AudioFormat
+	MaxBytes     int
+}
+
+func (l *LocalTTSEngine) Name() string { return "local" }
+
+func (l *LocalTTSEngine) Voices(ctx context.Context) ([]TTSVoice, error) {
+	voices := make([]TTSVoice, 0, len(l.VoiceNames))
+	for _, name := range l.VoiceNames {
+		voices = append(voices, TTSVoice{Name: name, LanguageCode: l.LanguageCode})
+	}
+	return voices, nil
+}
+
+// voiceFor dùng giọng mặc định khi giọng yêu cầu không có trong danh sách (vd giọng Google của job cũ)
+func (l *LocalTTSEngine) voiceFor(voice string) string {
+	for _, name := range l.VoiceNames {
+		if name == voice {
+			return voice
+		}
+	}
+	return l.VoiceNames[0]
+}
+
+func (l *LocalTTSEngine) Synthesize(ctx context.Context, req SynthesisRequest) ([]byte, error) {
+	voice := l.voiceFor(req.Voice)
+	if !localVoiceNameRe.MatchString(voice) {
+		return nil, fmt.Errorf("tên giọng local không hợp lệ: %s", voice)
+	}
+	rate := req.SpeakingRate
+	if rate <= 0 {
+		rate = 1.0
+	}
+
+	cmdline := strings.NewReplacer("{voice}", voice, "{rate}", strconv.FormatFloat(rate, 'f', 2, 64)).Replace(l.Command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", cmdline)
+	cmd.Stdin = strings.NewReader(req.Text)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("lệnh TTS local lỗi: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, errors.New("lệnh TTS local không xuất audio")
+	}
+	return stdout.Bytes(), nil
+}
+
+func (l *LocalTTSEngine) SupportsSSML(voice string) bool { return false }
+
+func (l *LocalTTSEngine) MaxChunkBytes() int {
+	if l.MaxBytes <= 0 {
+		return 2000
+	}
+	return l.MaxBytes
+}
+
+func (l *LocalTTSEngine) OutputFormat() AudioFormat { return l.Format }
+
+// ---------------- Giả lập ----------------
+
+// FakeTTSEngine trả về các frame MP3 im lặng, thời lượng tỉ lệ với độ dài văn bản (chạy offline, không cần credentials)
+type FakeTTSEngine struct{}
+
+// Frame MPEG-2 Layer III, 32 kbps, 24 kHz, mono (giống audio Google trả về): 96 bytes, 576 mẫu = 24ms
+var fakeSilentFrame = func() []byte {
+	frame := make([]byte, 96)
+	binary.BigEndian.PutUint32(frame, 0xFFF344C0)
+	return frame
+}()
+
+var fakeSSMLTagRe = regexp.MustCompile(`<[^>]*>`)
+
+const (
+	fakeFrameSeconds   = 0.024
+	fakeCharsPerSecond = 15.0
+)
+
+func (f *FakeTTSEngine) Name() string { return "fake" }
+
+func (f *FakeTTSEngine) Voices(ctx context.Context) ([]TTSVoice, error) {
+	return []TTSVoice{
+		{Name: defaultVoice, LanguageCode: "vi-VN"},
+		{Name: defaultDialogueVoiceB, LanguageCode: "vi-VN"},
+		{Name: "en-US-Chirp3-HD-Puck", LanguageCode: "en-US"},
+		{Name: "en-US-Chirp3-HD-Aoede", LanguageCode: "en-US"},
+	}, nil
+}
+
+func (f *FakeTTSEngine) Synthesize(ctx context.Context, req SynthesisRequest) ([]byte, error) {
+	text := req.Text
+	if req.SSML {
+		text = fakeSSMLTagRe.ReplaceAllString(text, "")
+	}
+	rate := req.SpeakingRate
+	if rate <= 0 {
+		rate = 1.0
+	}
+	seconds := float64(utf8.RuneCountInString(strings.TrimSpace(text))) / fakeCharsPerSecond / rate
+	frames := int(math.Max(1, math.Ceil(seconds/fakeFrameSeconds)))
+	return bytes.Repeat(fakeSilentFrame, frames), nil
+}
+
+func (f *FakeTTSEngine) SupportsSSML(voice string) bool { return UseSSMLForVoice(voice) }
+
+func (f *FakeTTSEngine) MaxChunkBytes() int { return 4500 }
+
+func (f *FakeTTSEngine) OutputFormat() AudioFormat { return AudioMP3 }
+
+// joinAudio nối audio của các chunk theo định dạng của engine
+func joinAudio(format AudioFormat, parts [][]byte) ([]byte, error) {
+	if format == AudioWAV {
+		return joinWAV(parts)
+	}
+	return JoinMP3(parts)
+}
+
+// joinWAV ghép dữ liệu PCM của các file WAV cùng định dạng thành 1 file
+func joinWAV(parts [][]byte) ([]byte, error) {
+	var fmtChunk, pcm []byte
+	for i, part := range parts {
+		if len(part) < 12 || string(part[0:4]) != "RIFF" || string(part[8:12]) != "WAVE" {
+			return nil, fmt.Errorf("audio chunk %d không phải WAV", i+1)
+		}
+		for pos := 12; pos+8 <= len(part); {
+			id := string(part[pos : pos+4])
+			size := int(binary.LittleEndian.Uint32(part[pos+4 : pos+8]))
+			end := pos + 8 + size
+			if end > len(part) {
+				// Một số lệnh ghi ra stdout nên không biết trước kích thước
+				end = len(part)
+			}
+			switch id {
+			case "fmt ":
+				if fmtChunk == nil {
+					fmtChunk = part[pos:end]
+				} else if !bytes.Equal(fmtChunk, part[pos:end]) {
+					return nil, errors.New("các chunk WAV khác định dạng")
+				}
+			case "data":
+				pcm = append(pcm, part[pos+8:end]...)
+			}
+			pos = end + size%2
+		}
+	}
+	if fmtChunk == nil {
+		return nil, errors.New("audio WAV thiếu chunk fmt")
+	}
+
+	out := make([]byte, 0, 12+len(fmtChunk)+8+len(pcm))
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(4+len(fmtChunk)+8+len(pcm)))
+	out = append(out, "WAVE"...)
+	out = append(out, fmtChunk...)
+	out = append(out, "data"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(pcm)))
+	out = append(out, pcm...)
+	if len(pcm)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out, nil
+}
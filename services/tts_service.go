@@ -5,68 +5,369 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"log"
+	"strings"
+	"sync"
+	"time"
 
-	texttospeech "cloud.google.com/go/texttospeech/apiv1"
-	texttospeechpb "cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
-	"google.golang.org/api/option"
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
 )
 
-// SynthesizeText chuyển text thành audio []byte
+// Một đoạn cần đọc với giọng riêng (hội thoại nhiều người dẫn)
+type SpeechSegment struct {
+	Text  string
+	Voice string
+}
+
+// Tuỳ chọn tổng hợp giọng nói
+type SynthesisOptions struct {
+	SpeakingRate float64
+	DanhMucID    string                // Dùng thêm mục từ điển phát âm riêng của danh mục
+	Progress     func(done, total int) // Gọi sau mỗi chunk hoàn thành (có thể nil)
+	// Đoạn văn khác ngôn ngữ với giọng của đoạn được đọc bằng giọng của ngôn ngữ đó (tài liệu nhiều ngôn ngữ)
+	SwitchLanguage bool
+}
+
+// Kết quả tổng hợp: audio đã ghép, mốc chương (rỗng nếu văn bản không có tiêu đề phần/chương) và phụ đề từng câu
+type SynthesisResult struct {
+	Audio      []byte
+	Chapters   []models.ChuongAudio
+	Transcript []models.DongPhuDe
+
+	CacheHits, CacheMisses int // Số chunk lấy từ bộ nhớ đệm / phải tổng hợp mới
+}
+
+// SynthesizeText chuyển text thành audio []byte (áp dụng từ điển phát âm chung)
 func SynthesizeText(text string, voice string, rate float64) ([]byte, error) {
 	if len(text) == 0 {
 		return nil, errors.New("text is empty")
 	}
-	if voice == "" {
-		voice = "vi-VN-Chirp3-HD-Puck"
+	return SynthesizeSegments([]SpeechSegment{{Text: text, Voice: voice}}, SynthesisOptions{SpeakingRate: rate})
+}
+
+// 1 chunk cần tổng hợp, giữ vị trí để ghép lại đúng thứ tự
+type synthesisChunk struct {
+	seg, idx, segChunks int
+	chapter             int // Chỉ số chương chứa chunk
+	section             int // Chỉ số phần (toàn bộ các đoạn) chứa chunk, dùng để căn phụ đề
+	req                 SynthesisRequest
+}
+
+// 1 phần của văn bản, bắt đầu bằng dòng tiêu đề (trừ phần mở đầu)
+type speechSection struct {
+	title string
+	text  string
+}
+
+// SynthesizeSegments đọc từng đoạn bằng giọng của đoạn đó (qua TTS engine theo cấu hình) và nối thành 1 file audio.
+func SynthesizeSegments(segments []SpeechSegment, opts SynthesisOptions) ([]byte, error) {
+	res, err := SynthesizeSpeech(segments, opts)
+	if err != nil {
+		return nil, err
+	}
+	return res.Audio, nil
+}
+
+// SynthesizeSpeech như SynthesizeSegments, kèm mốc chương theo các dòng tiêu đề trong văn bản.
+// Các chunk được tổng hợp song song (giới hạn TTS_CONCURRENCY), lỗi tạm thời được thử lại, kết quả ghép theo thứ tự.
+// Mỗi phần được chia chunk riêng nên thời điểm bắt đầu phần = tổng thời lượng các chunk trước đó.
+func SynthesizeSpeech(segments []SpeechSegment, opts SynthesisOptions) (*SynthesisResult, error) {
+	if len(segments) == 0 {
+		return nil, errors.New("text is empty")
+	}
+	engine, err := DefaultTTSEngine()
+	if err != nil {
+		return nil, err
 	}
+	rate := opts.SpeakingRate
 	if rate <= 0 {
 		rate = 1.0
 	}
+	lex := LoadLexicon(opts.DanhMucID)
+	cfg := config.GetTTSConfig()
+
+	var chunks []synthesisChunk
+	var titles []string // Tiêu đề chương, "" = phần mở đầu trước tiêu đề đầu tiên
+	var cues [][]transcriptCue
+	hasHeading := false
+	for segIdx, seg := range segments {
+		voice := seg.Voice
+		if voice == "" {
+			voice = DefaultVoiceFor(opts.DanhMucID, DetectLanguage(seg.Text))
+		}
+		// Giọng cho đoạn văn khác ngôn ngữ, engine không có giọng của ngôn ngữ đó thì giữ giọng của đoạn
+		runVoices := map[string]string{}
+		voiceFor := func(lang string) string {
+			if lang == "" || lang == VoiceLanguage(voice) {
+				return voice
+			}
+			if _, ok := runVoices[lang]; !ok {
+				runVoices[lang] = LanguageVoice(lang, voice)
+			}
+			if v := runVoices[lang]; v != "" {
+				return v
+			}
+			return voice
+		}
+
+		// Giọng hỗ trợ SSML: đọc có ngắt nghỉ, nhấn mạnh tiêu đề (chunk SSML không bao giờ cắt giữa thẻ).
+		// Số, ngày, tiền tệ... được đọc thành chữ theo ngôn ngữ của giọng
+		var reqs []SynthesisRequest
+		var chapters, sections []int
+		for _, sec := range splitSpeechSections(seg.Text) {
+			if sec.title != "" || len(titles) == 0 {
+				titles = append(titles, sec.title)
+				hasHeading = hasHeading || sec.title != ""
+			}
+			runs := []languageRun{{text: sec.text}}
+			if opts.SwitchLanguage {
+				if r := splitLanguageRuns(sec.text, VoiceLanguage(voice)); len(r) > 1 {
+					runs = r
+				}
+			}
+
+			var secCues []transcriptCue
+			for _, run := range runs {
+				runVoice := voiceFor(run.lang)
+				useSSML := engine.SupportsSSML(runVoice)
+				lang := voiceLanguageCode(runVoice)
+				// Từ điển phát âm ghi cách đọc cho giọng tiếng Việt, giọng ngôn ngữ khác đọc nguyên văn
+				runLex := lex
+				if baseLanguage(lang) != "vi" {
+					runLex = nil
+				}
+				var texts []string
+				if useSSML {
+					texts = splitSSMLToChunksByByte(BuildSSML(run.text, runLex, lang), engine.MaxChunkBytes())
+				} else {
+					texts = splitTextToChunksByByte(PrepareSpeechText(run.text, runLex, lang), engine.MaxChunkBytes())
+				}
+				for _, text := range texts {
+					reqs = append(reqs, SynthesisRequest{
+						Text:         text,
+						SSML:         useSSML,
+						Voice:        runVoice,
+						LanguageCode: lang,
+						SpeakingRate: rate,
+					})
+					chapters = append(chapters, len(titles)-1)
+					sections = append(sections, len(cues))
+				}
+				secCues = append(secCues, transcriptCues(run.text, runLex, lang)...)
+			}
+			cues = append(cues, secCues)
+		}
+		for idx, req := range reqs {
+			chunks = append(chunks, synthesisChunk{seg: segIdx, idx: idx, segChunks: len(reqs), chapter: chapters[idx], section: sections[idx], req: req})
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, errors.New("text is empty")
+	}
 
-	ctx := context.Background()
-	jsonCreds := os.Getenv("GOOGLE_CREDENTIALS_JSON")
-	if jsonCreds == "" {
-		return nil, errors.New("GOOGLE_CREDENTIALS_JSON environment variable is not set")
+	workers := cfg.Concurrency
+	if workers < 1 {
+		workers = 1
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	client, err := texttospeech.NewClient(ctx, option.WithCredentialsJSON([]byte(jsonCreds)))
+	useCache := synthesisCacheEnabled()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		done     int
+		hits     int
+		firstErr error
+		parts    = make([][]byte, len(chunks))
+		sem      = make(chan struct{}, workers)
+	)
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			ch := chunks[i]
+			var key string
+			if useCache {
+				key = synthesisCacheKey(engine, ch.req)
+				if audio, ok := loadCachedSynthesis(key); ok {
+					mu.Lock()
+					defer mu.Unlock()
+					parts[i] = audio
+					hits++
+					done++
+					if opts.Progress != nil {
+						opts.Progress(done, len(chunks))
+					}
+					return
+				}
+			}
+
+			fmt.Printf("Synthesizing segment %d/%d chunk %d/%d (%s/%s): %d bytes\n", ch.seg+1, len(segments), ch.idx+1, ch.segChunks, engine.Name(), ch.req.Voice, len(ch.req.Text))
+			audio, err := synthesizeChunkWithRetry(ctx, engine, ch.req, cfg)
+			if err == nil && useCache {
+				storeCachedSynthesis(key, engine, ch.req, audio)
+			}
 
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("chunk %d/%d: %w", i+1, len(chunks), err)
+					cancel()
+				}
+				return
+			}
+			parts[i] = audio
+			done++
+			if opts.Progress != nil {
+				opts.Progress(done, len(chunks))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if useCache {
+		log.Printf("TTS cache: %d/%d chunk lấy từ bộ nhớ đệm, %d chunk tổng hợp mới\n", hits, len(chunks), len(chunks)-hits)
+	}
+	audio, err := joinAudio(engine.OutputFormat(), parts)
 	if err != nil {
 		return nil, err
 	}
-	defer client.Close()
+	res := &SynthesisResult{Audio: audio, CacheHits: hits, CacheMisses: len(chunks) - hits}
+	if engine.OutputFormat() == AudioMP3 {
+		durations := make([]float64, len(parts))
+		for i, part := range parts {
+			durations[i], _ = GetMP3DurationFromBytes(part)
+		}
+		if hasHeading {
+			res.Chapters = buildChapters(titles, chunks, durations)
+		}
+		res.Transcript = buildTranscript(cues, chunks, durations)
+	}
+	return res, nil
+}
+
+// splitSpeechSections tách văn bản thành các phần theo dòng tiêu đề ("Chương 1", "Phần II: ..."),
+// nội dung trước tiêu đề đầu tiên là phần mở đầu (title rỗng)
+func splitSpeechSections(text string) []speechSection {
+	var sections []speechSection
+	var paras []string
+	title := ""
+	flush := func() {
+		if len(paras) > 0 {
+			sections = append(sections, speechSection{title: title, text: strings.Join(paras, "\n\n")})
+		}
+		paras = nil
+	}
+	for _, para := range plainParagraphSplitRe.Split(text, -1) {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if _, ok := plainHeadingLevel(para); ok {
+			flush()
+			title = normalizeSpaces(para)
+		}
+		paras = append(paras, para)
+	}
+	flush()
+	if len(sections) == 0 && strings.TrimSpace(text) != "" {
+		sections = append(sections, speechSection{text: text})
+	}
+	return sections
+}
 
-	chunks := splitTextToChunksByByte(text, 4500) // Dưới ngưỡng 5000 bytes
-	var allAudio []byte
+// buildChapters cộng dồn thời lượng từng chunk MP3 để ra mốc bắt đầu/kết thúc (ms) của mỗi chương
+func buildChapters(titles []string, chunks []synthesisChunk, durations []float64) []models.ChuongAudio {
+	starts := make([]int, len(titles))
+	ends := make([]int, len(titles))
+	seen := make([]bool, len(titles))
+	var elapsed float64
+	for i, d := range durations {
+		ch := chunks[i].chapter
+		if !seen[ch] {
+			seen[ch] = true
+			starts[ch] = int(elapsed * 1000)
+		}
+		elapsed += d
+		ends[ch] = int(elapsed * 1000)
+	}
 
-	for idx, chunk := range chunks {
-		fmt.Printf("Synthesizing chunk %d/%d: %d bytes\n", idx+1, len(chunks), len(chunk))
+	var chapters []models.ChuongAudio
+	for i, title := range titles {
+		if !seen[i] || ends[i] <= starts[i] {
+			continue
+		}
+		if title == "" {
+			title = "Mở đầu"
+		}
+		chapters = append(chapters, models.ChuongAudio{
+			ThuTu:     len(chapters) + 1,
+			TieuDe:    title,
+			BatDauMs:  starts[i],
+			KetThucMs: ends[i],
+		})
+	}
+	return chapters
+}
 
-		req := &texttospeechpb.SynthesizeSpeechRequest{
-			Input: &texttospeechpb.SynthesisInput{
-				InputSource: &texttospeechpb.SynthesisInput_Text{
-					Text: chunk,
-				},
-			},
-			Voice: &texttospeechpb.VoiceSelectionParams{
-				LanguageCode: "vi-VN",
-				Name:         voice,
-			},
-			AudioConfig: &texttospeechpb.AudioConfig{
-				AudioEncoding: texttospeechpb.AudioEncoding_MP3,
-				SpeakingRate:  rate,
-			},
+// synthesizeChunkWithRetry gọi engine với timeout cho mỗi lần, thử lại lỗi tạm thời với thời gian chờ tăng dần
+func synthesizeChunkWithRetry(ctx context.Context, engine TTSEngine, req SynthesisRequest, cfg config.TTSConfig) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := cfg.BaseBackoff << (attempt - 1)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
 		}
 
-		resp, err := client.SynthesizeSpeech(ctx, req)
-		if err != nil {
-			return nil, err
+		callCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+		audio, err := engine.Synthesize(callCtx, req)
+		cancel()
+		if err == nil {
+			return audio, nil
 		}
-		allAudio = append(allAudio, resp.AudioContent...)
+
+		lastErr = err
+		if ctx.Err() != nil || !isTransientError(err) {
+			break
+		}
+		log.Printf("TTS %s lỗi (lần %d/%d), thử lại: %v\n", engine.Name(), attempt+1, cfg.MaxRetries+1, err)
 	}
+	return nil, lastErr
+}
 
-	return allAudio, nil
+// voiceLanguageCode lấy mã ngôn ngữ engine báo cho giọng (giọng local như "en-us", "vi" không theo dạng tên của Google),
+// không có trong danh sách thì tách từ tên giọng ("vi-VN-Chirp3-HD-Puck" -> "vi-VN"), cuối cùng mặc định tiếng Việt
+func voiceLanguageCode(voice string) string {
+	if engine, err := DefaultTTSEngine(); err == nil {
+		if voices, err := engineVoices(context.Background(), engine); err == nil {
+			for _, v := range voices {
+				if v.Name == voice && v.LanguageCode != "" {
+					return v.LanguageCode
+				}
+			}
+		}
+	}
+	parts := strings.SplitN(voice, "-", 3)
+	if len(parts) < 3 {
+		return "vi-VN"
+	}
+	return parts[0] + "-" + parts[1]
 }
 
 // splitTextToChunksByByte chia text theo giới hạn byte + dấu câu
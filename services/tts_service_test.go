@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// recordingTTSEngine ghi lại các chunk gửi tới FakeTTSEngine
+type recordingTTSEngine struct {
+	FakeTTSEngine
+	mu   sync.Mutex
+	reqs []SynthesisRequest
+}
+
+func (r *recordingTTSEngine) Synthesize(ctx context.Context, req SynthesisRequest) ([]byte, error) {
+	r.mu.Lock()
+	r.reqs = append(r.reqs, req)
+	r.mu.Unlock()
+	return r.FakeTTSEngine.Synthesize(ctx, req)
+}
+
+func useTestTTSEngine(t *testing.T, engine TTSEngine) {
+	t.Helper()
+	t.Setenv("TTS_CACHE", "off")
+	defaultTTSEngineMu.Lock()
+	old := defaultTTSEngine
+	defaultTTSEngine = engine
+	defaultTTSEngineMu.Unlock()
+	InvalidateVoiceCatalog()
+	t.Cleanup(func() {
+		defaultTTSEngineMu.Lock()
+		defaultTTSEngine = old
+		defaultTTSEngineMu.Unlock()
+		InvalidateVoiceCatalog()
+	})
+}
+
+func TestSynthesizeSpeechWithFakeEngine(t *testing.T) {
+	engine := &recordingTTSEngine{}
+	useTestTTSEngine(t, engine)
+
+	para := strings.Repeat("Đây là một câu trong đoạn văn dùng để thử tổng hợp giọng nói. ", 40)
+	text := "Chương 1\n\n" + para + "\n\n" + para + "\n\nChương 2\n\n" + para
+
+	var progress []int
+	res, err := SynthesizeSpeech([]SpeechSegment{{Text: text}}, SynthesisOptions{
+		SpeakingRate: 1.25,
+		Progress:     func(done, total int) { progress = append(progress, total) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(engine.reqs) < 3 {
+		t.Fatalf("văn bản dài phải được chia nhiều chunk, có %d", len(engine.reqs))
+	}
+	if len(progress) != len(engine.reqs) {
+		t.Errorf("Progress gọi %d lần, muốn %d", len(progress), len(engine.reqs))
+	}
+
+	// Thời lượng file ghép = tổng thời lượng từng chunk FakeTTSEngine tạo ra
+	var want float64
+	for _, req := range engine.reqs {
+		if len(req.Text) > engine.MaxChunkBytes() {
+			t.Errorf("chunk %d bytes vượt giới hạn %d", len(req.Text), engine.MaxChunkBytes())
+		}
+		if req.Voice != defaultVoice || req.SpeakingRate != 1.25 {
+			t.Errorf("chunk dùng giọng %s, tốc độ %v", req.Voice, req.SpeakingRate)
+		}
+		seconds := float64(utf8.RuneCountInString(strings.TrimSpace(req.Text))) / fakeCharsPerSecond / req.SpeakingRate
+		want += math.Max(1, math.Ceil(seconds/fakeFrameSeconds)) * fakeFrameSeconds
+	}
+	got, err := GetMP3DurationFromBytes(res.Audio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got-want) > 0.001 {
+		t.Errorf("thời lượng = %.3fs, muốn %.3fs", got, want)
+	}
+
+	if len(res.Chapters) != 2 || res.Chapters[0].TieuDe != "Chương 1" || res.Chapters[1].TieuDe != "Chương 2" {
+		t.Fatalf("Chapters = %+v", res.Chapters)
+	}
+	if last := res.Chapters[1]; math.Abs(float64(last.KetThucMs)-want*1000) > 1 {
+		t.Errorf("chương cuối kết thúc ở %dms, muốn %.0fms", last.KetThucMs, want*1000)
+	}
+	if len(res.Transcript) == 0 {
+		t.Error("thiếu phụ đề")
+	}
+
+	// Gắn thẻ ID3 không làm đổi thời lượng, TLEN ghi đúng độ dài
+	tagged := TagMP3(res.Audio, MP3Tags{Title: "Podcast thử", Chapters: res.Chapters})
+	if !bytes.HasPrefix(tagged, []byte("ID3")) {
+		t.Fatal("thiếu thẻ ID3")
+	}
+	taggedDur, err := GetMP3DurationFromBytes(tagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(taggedDur-got) > 0.001 {
+		t.Errorf("thời lượng sau khi gắn thẻ = %.3fs, trước = %.3fs", taggedDur, got)
+	}
+	for _, frame := range []string{"TIT2", "TLEN", "CTOC", "CHAP"} {
+		if !bytes.Contains(tagged, []byte(frame)) {
+			t.Errorf("thẻ ID3 thiếu frame %s", frame)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrInvalidURL         = errors.New("URL không hợp lệ, chỉ hỗ trợ http/https")
+	ErrPageTooLarge       = errors.New("trang web vượt quá dung lượng cho phép")
+	ErrUnsupportedContent = errors.New("URL không trả về trang HTML")
+	ErrTooManyRedirects   = errors.New("URL chuyển hướng quá nhiều lần")
+	ErrPrivateAddress     = errors.New("không được phép tải địa chỉ mạng nội bộ")
+	ErrNoReadableContent  = errors.New("không tìm thấy nội dung bài viết trên trang")
+)
+
+// Loại nội dung được chấp nhận khi tải URL
+var articleAllowedMimeType = map[string]bool{"text/html": true, "application/xhtml+xml": true}
+
+// Bài viết đã trích xuất từ trang web
+type Article struct {
+	URL   string // URL cuối cùng sau khi chuyển hướng
+	Title string
+	Text  string // Các đoạn văn cách nhau bởi dòng trống
+}
+
+// Tải trang web với giới hạn chuyển hướng, dung lượng và loại nội dung
+type ArticleFetcher struct {
+	Client   *http.Client
+	MaxBytes int64
+}
+
+// Chỉ test đặt true: cho phép tải httptest server trên 127.0.0.1 mà vẫn chặn các địa chỉ nội bộ khác
+var articleFetchAllowLoopback = false
+
+// NewArticleFetcher tạo fetcher theo cấu hình môi trường
+func NewArticleFetcher(cfg config.URLFetchConfig) *ArticleFetcher {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	proxy := http.ProxyFromEnvironment
+	transport := &http.Transport{
+		Proxy:       proxy,
+		DialContext: dialer.DialContext,
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if !cfg.AllowPrivate {
+		// Kết nối trực tiếp: kiểm tra IP sau khi phân giải DNS để chặn cả redirect tới địa chỉ nội bộ.
+		// Kết nối tới proxy đã cấu hình thì không chặn (proxy thường nằm trong mạng nội bộ),
+		// địa chỉ đích của request đi qua proxy được kiểm tra trong privateGuardTransport
+		guarded := &net.Dialer{
+			Timeout: cfg.Timeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || isBlockedFetchIP(ip) {
+					return ErrPrivateAddress
+				}
+				return nil
+			},
+		}
+		proxyAddrs := proxyDialAddrs(proxy)
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if proxyAddrs[address] {
+				return dialer.DialContext(ctx, network, address)
+			}
+			return guarded.DialContext(ctx, network, address)
+		}
+		roundTripper = &privateGuardTransport{next: transport, proxy: proxy}
+	}
+
+	maxRedirects := cfg.MaxRedirects
+	return &ArticleFetcher{
+		Client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: roundTripper,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return ErrTooManyRedirects
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return ErrInvalidURL
+				}
+				return nil
+			},
+		},
+		MaxBytes: cfg.MaxBytes,
+	}
+}
+
+// privateGuardTransport chặn request đi qua proxy tới host phân giải ra địa chỉ nội bộ
+// (mỗi lần chuyển hướng cũng đi qua RoundTrip nên được kiểm tra lại)
+type privateGuardTransport struct {
+	next  http.RoundTripper
+	proxy func(*http.Request) (*url.URL, error)
+}
+
+func (t *privateGuardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if proxyURL, err := t.proxy(req); err == nil && proxyURL != nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(req.Context(), req.URL.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if isBlockedFetchIP(addr.IP) {
+				return nil, ErrPrivateAddress
+			}
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// proxyDialAddrs trả về địa chỉ host:port của các proxy cấu hình qua HTTP_PROXY/HTTPS_PROXY
+func proxyDialAddrs(proxy func(*http.Request) (*url.URL, error)) map[string]bool {
+	addrs := map[string]bool{}
+	for _, target := range []string{"http://example.com", "https://example.com"} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil {
+			continue
+		}
+		port := proxyURL.Port()
+		if port == "" {
+			port = map[string]string{"https": "443", "socks5": "1080"}[proxyURL.Scheme]
+		}
+		if port == "" {
+			port = "80"
+		}
+		addrs[net.JoinHostPort(proxyURL.Hostname(), port)] = true
+	}
+	return addrs
+}
+
+func isBlockedFetchIP(ip net.IP) bool {
+	if articleFetchAllowLoopback && ip.IsLoopback() {
+		return false
+	}
+	return isPrivateIP(ip)
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast()
+}
+
+// ValidateArticleURL kiểm tra URL có thể dùng làm nguồn bài viết
+func ValidateArticleURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	return u, nil
+}
+
+// Fetch tải trang và trích xuất nội dung chính của bài viết
+func (f *ArticleFetcher) Fetch(ctx context.Context, rawURL string) (*Article, error) {
+	u, err := ValidateArticleURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "SonifyBot/1.0 (+podcast article import)")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		// Trả về lỗi gốc để controller/worker nhận biết (redirect, IP nội bộ)
+		for _, known := range []error{ErrTooManyRedirects, ErrPrivateAddress, ErrInvalidURL} {
+			if errors.Is(err, known) {
+				return nil, known
+			}
+		}
+		return nil, fmt.Errorf("không thể tải URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("không thể tải URL, status code: %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !articleAllowedMimeType[mediaType] {
+		return nil, ErrUnsupportedContent
+	}
+	if f.MaxBytes > 0 && resp.ContentLength > f.MaxBytes {
+		return nil, ErrPageTooLarge
+	}
+
+	// Đọc dư 1 byte để phát hiện trang vượt giới hạn
+	body := io.Reader(resp.Body)
+	if f.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, f.MaxBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi đọc trang web: %w", err)
+	}
+	if f.MaxBytes > 0 && int64(len(data)) > f.MaxBytes {
+		return nil, ErrPageTooLarge
+	}
+
+	// Chuyển mã về UTF-8 theo header/meta charset
+	utf8Reader, err := charset.NewReader(bytes.NewReader(data), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("không nhận diện được bảng mã trang: %w", err)
+	}
+
+	article, err := ExtractArticle(utf8Reader)
+	if err != nil {
+		return nil, err
+	}
+	article.URL = resp.Request.URL.String()
+	return article, nil
+}
+
+// Class/id thường dùng cho menu, quảng cáo, bình luận... không phải nội dung bài
+var articleNoisePattern = regexp.MustCompile(`(?i)(^|[\s_-])(nav|navbar|menu|breadcrumbs?|sidebar|footer|masthead|comments?|share|sharing|social|related|advert|advertisement|ads?|banner|promo|sponsor(ed)?|popup|modal|cookie|subscribe|newsletter|widget|outbrain|taboola)([\s_-]|$)`)
+
+// Các thẻ luôn bị loại khỏi nội dung bài viết
+var articleRemoveTags = map[string]bool{
+	"nav": true, "header": true, "footer": true, "aside": true, "form": true,
+	"button": true, "select": true, "input": true, "textarea": true, "figure": true,
+	"script": true, "style": true, "noscript": true, "iframe": true, "svg": true,
+}
+
+// Các thẻ giữ cấu trúc đoạn khi xuất văn bản bài viết
+var articleBlockTags = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"li": true, "blockquote": true, "pre": true,
+}
+
+// ExtractArticle lấy tiêu đề và các đoạn nội dung chính từ HTML (kiểu readability)
+func ExtractArticle(r io.Reader) (*Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("không thể phân tích HTML: %w", err)
+	}
+
+	title := articleTitle(doc)
+
+	body := htmlFindFirst(doc, "body")
+	if body == nil {
+		return nil, ErrNoReadableContent
+	}
+	pruneArticleNoise(body)
+
+	content := bestArticleCandidate(body)
+	var paragraphs []string
+	for _, node := range content {
+		paragraphs = append(paragraphs, articleParagraphs(node)...)
+	}
+
+	// Bỏ tiêu đề lặp lại ở đầu bài
+	if len(paragraphs) > 0 && title != "" && paragraphs[0] == title {
+		paragraphs = paragraphs[1:]
+	}
+	if len(paragraphs) == 0 {
+		return nil, ErrNoReadableContent
+	}
+
+	return &Article{Title: title, Text: strings.Join(paragraphs, "\n\n")}, nil
+}
+
+// articleTitle ưu tiên og:title, sau đó <title>, cuối cùng là <h1>
+func articleTitle(doc *html.Node) string {
+	var ogTitle string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if ogTitle != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "meta" && htmlAttr(n, "property") == "og:title" {
+			ogTitle = strings.TrimSpace(htmlAttr(n, "content"))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if ogTitle != "" {
+		return ogTitle
+	}
+	if t := htmlFindFirst(doc, "title"); t != nil {
+		if text := htmlInlineText(t); text != "" {
+			return text
+		}
+	}
+	if h := htmlFindFirst(doc, "h1"); h != nil {
+		return htmlInlineText(h)
+	}
+	return ""
+}
+
+// pruneArticleNoise xoá menu, quảng cáo, form, phần ẩn... khỏi cây DOM
+func pruneArticleNoise(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && isArticleNoise(c)) {
+			n.RemoveChild(c)
+		} else {
+			pruneArticleNoise(c)
+		}
+		c = next
+	}
+}
+
+func isArticleNoise(n *html.Node) bool {
+	if articleRemoveTags[n.Data] {
+		return true
+	}
+	if _, hidden := htmlAttrOK(n, "hidden"); hidden || htmlAttr(n, "aria-hidden") == "true" {
+		return true
+	}
+	switch htmlAttr(n, "role") {
+	case "navigation", "banner", "complementary", "contentinfo", "dialog", "search":
+		return true
+	}
+	// Không loại vùng nội dung chính dù class trùng mẫu
+	if n.Data == "article" || n.Data == "main" {
+		return false
+	}
+	style := strings.ReplaceAll(strings.ToLower(htmlAttr(n, "style")), " ", "")
+	if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+		return true
+	}
+	return articleNoisePattern.MatchString(htmlAttr(n, "class")) || articleNoisePattern.MatchString(htmlAttr(n, "id"))
+}
+
+// bestArticleCandidate chấm điểm các khối chứa đoạn văn, trả về khối tốt nhất cùng các khối anh em đủ điểm
+func bestArticleCandidate(body *html.Node) []*html.Node {
+	scores := map[*html.Node]float64{}
+	var order []*html.Node
+
+	addScore := func(n *html.Node, s float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			order = append(order, n)
+		}
+		scores[n] += s
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "p" || n.Data == "pre") {
+			text := htmlInlineText(n)
+			if len([]rune(text)) >= 25 {
+				s := 1 + float64(strings.Count(text, ",")) + min(float64(len([]rune(text)))/100, 3)
+				addScore(n.Parent, s)
+				if n.Parent != nil {
+					addScore(n.Parent.Parent, s/2)
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(body)
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range order {
+		scores[n] *= 1 - linkDensity(n)
+		if n.Data == "article" || n.Data == "main" {
+			scores[n] *= 1.25
+		}
+		if scores[n] > bestScore {
+			best, bestScore = n, scores[n]
+		}
+	}
+
+	if best == nil {
+		if n := htmlFindFirst(body, "article", "main"); n != nil {
+			return []*html.Node{n}
+		}
+		return []*html.Node{body}
+	}
+	if best.Parent == nil || best == body {
+		return []*html.Node{best}
+	}
+
+	// Gom các khối anh em cũng có điểm cao (bài viết bị chia nhiều <div>)
+	var result []*html.Node
+	threshold := bestScore * 0.2
+	for c := best.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if c == best || (c.Type == html.ElementNode && scores[c] >= threshold && scores[c] > 0) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// linkDensity tỉ lệ chữ nằm trong link, cao nghĩa là menu/danh sách liên kết
+func linkDensity(n *html.Node) float64 {
+	total := len([]rune(htmlInlineText(n)))
+	if total == 0 {
+		return 0
+	}
+	linkChars := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			linkChars += len([]rune(htmlInlineText(n)))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(linkChars) / float64(total)
+}
+
+// articleParagraphs lấy văn bản từng đoạn/tiêu đề/mục liệt kê trong khối nội dung
+func articleParagraphs(n *html.Node) []string {
+	var out []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && articleBlockTags[n.Data] {
+			text := htmlInlineText(n)
+			if text != "" && linkDensity(n) < 0.5 {
+				out = append(out, text)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	// Khối không có thẻ đoạn nào (văn bản trần trong <div>)
+	if len(out) == 0 {
+		if text := htmlToText(n); text != "" {
+			return strings.Split(text, "\n\n")
+		}
+	}
+	return out
+}
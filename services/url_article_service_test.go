@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Huong3203/APIPodcast/config"
+)
+
+const testArticleHTML = `<!DOCTYPE html>
+<html><head><title>Tiêu đề trang</title><meta property="og:title" content="Bài viết thử nghiệm"></head>
+<body>
+<nav><a href="/">Trang chủ</a> <a href="/tin-tuc">Tin tức</a></nav>
+<div class="sidebar"><p>Quảng cáo bên lề, không phải nội dung bài viết, không được lấy.</p></div>
+<article>
+<h1>Bài viết thử nghiệm</h1>
+<p>Đoạn thứ nhất của bài viết, đủ dài để được chấm điểm như một đoạn nội dung chính.</p>
+<p>Đoạn thứ hai nói thêm về chủ đề, có dấu phẩy, có chi tiết, và cũng đủ dài để giữ lại.</p>
+</article>
+<footer><p>Bản quyền thuộc về trang web, mọi hình thức sao chép đều bị cấm.</p></footer>
+</body></html>`
+
+// newTestFetcher tạo fetcher cho httptest server trên 127.0.0.1 (bật hook cho phép loopback nếu cần)
+func newTestFetcher(t *testing.T, allowLoopback bool, maxBytes int64) *ArticleFetcher {
+	t.Helper()
+	old := articleFetchAllowLoopback
+	articleFetchAllowLoopback = allowLoopback
+	t.Cleanup(func() { articleFetchAllowLoopback = old })
+	return NewArticleFetcher(config.URLFetchConfig{
+		Timeout:      5 * time.Second,
+		MaxBytes:     maxBytes,
+		MaxRedirects: 3,
+	})
+}
+
+func newArticleServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, testArticleHTML)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/to-private", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://10.255.255.1/admin", http.StatusFound)
+	})
+	mux.HandleFunc("/to-metadata", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok":true}`)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body><p>"+strings.Repeat("nội dung dài ", 2000)+"</p></body></html>")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestArticleFetcherFetch(t *testing.T) {
+	srv := newArticleServer(t)
+	f := newTestFetcher(t, true, 1<<20)
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr error
+		wantURL string
+	}{
+		{name: "bài viết", path: "/article", wantURL: "/article"},
+		{name: "theo chuyển hướng", path: "/old", wantURL: "/article"},
+		{name: "chuyển hướng vòng lặp", path: "/loop", wantErr: ErrTooManyRedirects},
+		{name: "chuyển hướng tới IP nội bộ", path: "/to-private", wantErr: ErrPrivateAddress},
+		{name: "chuyển hướng tới metadata", path: "/to-metadata", wantErr: ErrPrivateAddress},
+		{name: "không phải HTML", path: "/json", wantErr: ErrUnsupportedContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article, err := f.Fetch(context.Background(), srv.URL+tt.path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, muốn %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch lỗi: %v", err)
+			}
+			if article.Title != "Bài viết thử nghiệm" {
+				t.Errorf("Title = %q", article.Title)
+			}
+			if !strings.HasSuffix(article.URL, tt.wantURL) {
+				t.Errorf("URL = %q, muốn kết thúc bằng %q", article.URL, tt.wantURL)
+			}
+			if !strings.Contains(article.Text, "Đoạn thứ nhất") || !strings.Contains(article.Text, "Đoạn thứ hai") {
+				t.Errorf("thiếu nội dung bài: %q", article.Text)
+			}
+			for _, noise := range []string{"Trang chủ", "Quảng cáo", "Bản quyền"} {
+				if strings.Contains(article.Text, noise) {
+					t.Errorf("nội dung còn %q: %q", noise, article.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestArticleFetcherPageTooLarge(t *testing.T) {
+	srv := newArticleServer(t)
+	f := newTestFetcher(t, true, 1024)
+	if _, err := f.Fetch(context.Background(), srv.URL+"/big"); !errors.Is(err, ErrPageTooLarge) {
+		t.Fatalf("err = %v, muốn %v", err, ErrPageTooLarge)
+	}
+}
+
+func TestArticleFetcherBlocksLoopbackByDefault(t *testing.T) {
+	srv := newArticleServer(t)
+	f := newTestFetcher(t, false, 1<<20)
+	if _, err := f.Fetch(context.Background(), srv.URL+"/article"); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("err = %v, muốn %v", err, ErrPrivateAddress)
+	}
+}
+
+func TestValidateArticleURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://vnexpress.net/bai-viet", true},
+		{"  http://example.com  ", true},
+		{"ftp://example.com/file", false},
+		{"file:///etc/passwd", false},
+		{"javascript:alert(1)", false},
+		{"http://", false},
+		{"không phải url", false},
+	}
+	for _, tt := range tests {
+		_, err := ValidateArticleURL(tt.url)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateArticleURL(%q) err = %v, muốn hợp lệ = %v", tt.url, err, tt.ok)
+		}
+	}
+}
+
+// roundTripFunc giả lập transport phía sau guard
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestPrivateGuardTransportThroughProxy(t *testing.T) {
+	proxyURL, _ := url.Parse("http://10.0.0.5:3128")
+	reached := false
+	guard := &privateGuardTransport{
+		next: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			reached = true
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+		}),
+		proxy: func(*http.Request) (*url.URL, error) { return proxyURL, nil },
+	}
+
+	tests := []struct {
+		url     string
+		wantErr error
+	}{
+		{"http://93.184.216.34/bai-viet", nil},
+		{"http://127.0.0.1/admin", ErrPrivateAddress},
+		{"http://192.168.1.1/", ErrPrivateAddress},
+		{"http://[::1]:8080/", ErrPrivateAddress},
+	}
+	for _, tt := range tests {
+		reached = false
+		req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		_, err := guard.RoundTrip(req)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, muốn %v", tt.url, err, tt.wantErr)
+		}
+		if reached != (tt.wantErr == nil) {
+			t.Errorf("%s: request tới proxy = %v", tt.url, reached)
+		}
+	}
+
+	// Địa chỉ của proxy (dù là IP nội bộ) được phép kết nối
+	addrs := proxyDialAddrs(guard.proxy)
+	if !addrs["10.0.0.5:3128"] {
+		t.Errorf("proxyDialAddrs = %v, thiếu 10.0.0.5:3128", addrs)
+	}
+}
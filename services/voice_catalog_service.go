@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Huong3203/APIPodcast/config"
+	"github.com/Huong3203/APIPodcast/models"
+)
+
+// Danh mục giọng đọc: danh sách giọng của engine (lưu tạm 1 giờ) kết hợp cấu hình bật/tắt của admin
+
+var ErrVoiceUnavailable = errors.New("giọng đọc không tồn tại hoặc đã bị tắt")
+
+// Câu mẫu dùng để nghe thử giọng, theo ngôn ngữ của giọng (ngôn ngữ khác dùng câu tiếng Anh)
+var voicePreviewTexts = map[string]string{
+	"vi": "Xin chào, đây là giọng đọc thử của ứng dụng podcast. Chúc bạn có những phút giây nghe thật thú vị.",
+	"en": "Hello, this is a preview of the podcast app voice. We hope you enjoy listening.",
+}
+
+const voiceListTTL = time.Hour
+
+// Giọng đọc trong danh mục trả về cho client
+type VoiceInfo struct {
+	TTSVoice
+	Engine     string `json:"engine"`
+	TenHienThi string `json:"ten_hien_thi,omitempty"`
+	KichHoat   bool   `json:"kich_hoat"`
+}
+
+var (
+	voiceListMu     sync.Mutex
+	voiceListCache  []TTSVoice
+	voiceListLoaded time.Time
+
+	voicePreviewMu    sync.Mutex
+	voicePreviewCache = map[string][]byte{}
+)
+
+// engineVoices lấy danh sách giọng từ engine, lưu tạm để không gọi API mỗi lần upload
+func engineVoices(ctx context.Context, engine TTSEngine) ([]TTSVoice, error) {
+	voiceListMu.Lock()
+	defer voiceListMu.Unlock()
+	if voiceListCache != nil && time.Since(voiceListLoaded) < voiceListTTL {
+		return voiceListCache, nil
+	}
+	voices, err := engine.Voices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(voices, func(i, j int) bool {
+		if voices[i].LanguageCode != voices[j].LanguageCode {
+			return voices[i].LanguageCode < voices[j].LanguageCode
+		}
+		return voices[i].Name < voices[j].Name
+	})
+	voiceListCache, voiceListLoaded = voices, time.Now()
+	return voices, nil
+}
+
+// InvalidateVoiceCatalog xoá danh sách giọng đã lưu tạm (gọi lại engine ở lần sau)
+func InvalidateVoiceCatalog() {
+	voiceListMu.Lock()
+	voiceListCache = nil
+	voiceListMu.Unlock()
+}
+
+// ListVoiceCatalog trả về mọi giọng của engine hiện tại kèm trạng thái bật/tắt
+func ListVoiceCatalog(ctx context.Context) ([]VoiceInfo, error) {
+	engine, err := DefaultTTSEngine()
+	if err != nil {
+		return nil, err
+	}
+	voices, err := engineVoices(ctx, engine)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]models.CauHinhGiong{}
+	if config.DB != nil {
+		var rows []models.CauHinhGiong
+		config.DB.Where("engine = ?", engine.Name()).Find(&rows)
+		for _, r := range rows {
+			overrides[r.Ten] = r
+		}
+	}
+
+	catalog := make([]VoiceInfo, 0, len(voices))
+	for _, v := range voices {
+		info := VoiceInfo{TTSVoice: v, Engine: engine.Name(), KichHoat: true}
+		if o, ok := overrides[v.Name]; ok {
+			info.KichHoat = o.KichHoat
+			info.TenHienThi = o.TenHienThi
+		}
+		catalog = append(catalog, info)
+	}
+	return catalog, nil
+}
+
+// FindVoice tìm giọng trong danh mục (false nếu engine không có giọng này)
+func FindVoice(ctx context.Context, name string) (VoiceInfo, bool, error) {
+	catalog, err := ListVoiceCatalog(ctx)
+	if err != nil {
+		return VoiceInfo{}, false, err
+	}
+	for _, v := range catalog {
+		if v.Name == name {
+			return v, true, nil
+		}
+	}
+	return VoiceInfo{}, false, nil
+}
+
+// CheckVoiceChoice kiểm tra giọng người dùng chọn (rỗng = hợp lệ, dùng giọng mặc định).
+// Không lấy được danh sách giọng (engine lỗi mạng...) thì cho qua để không chặn upload
+func CheckVoiceChoice(voice string) error {
+	if voice == "" {
+		return nil
+	}
+	v, ok, err := FindVoice(context.Background(), voice)
+	if err != nil {
+		return nil
+	}
+	if !ok || !v.KichHoat {
+		return fmt.Errorf("%w: %s", ErrVoiceUnavailable, voice)
+	}
+	return nil
+}
+
+// DefaultVoiceFor chọn giọng khi upload không chỉ định, theo ngôn ngữ của tài liệu (lang rỗng = tiếng Việt):
+// giọng mặc định của danh mục, rồi giọng mặc định hệ thống, rồi giọng đầu tiên đang bật của ngôn ngữ đó.
+// Engine không có giọng nào của ngôn ngữ thì dùng giọng tiếng Việt như trước
+func DefaultVoiceFor(danhMucID, lang string) string {
+	if lang == "" {
+		lang = "vi"
+	}
+	catalog, err := ListVoiceCatalog(context.Background())
+	if err != nil {
+		return defaultVoice
+	}
+	usable := func(name string) bool {
+		for _, v := range catalog {
+			if v.Name == name {
+				return v.KichHoat && baseLanguage(v.LanguageCode) == lang
+			}
+		}
+		return false
+	}
+
+	if danhMucID != "" && config.DB != nil {
+		var dm models.DanhMuc
+		if err := config.DB.Select("giong_mac_dinh").First(&dm, "id = ?", danhMucID).Error; err == nil &&
+			dm.GiongMacDinh != "" && usable(dm.GiongMacDinh) {
+			return dm.GiongMacDinh
+		}
+	}
+	if usable(defaultVoice) {
+		return defaultVoice
+	}
+	if v := pickLanguageVoice(catalog, lang, defaultVoice); v != "" {
+		return v
+	}
+	if lang != "vi" {
+		return DefaultVoiceFor(danhMucID, "vi")
+	}
+	return defaultVoice
+}
+
+// LanguageVoice chọn giọng đang bật của ngôn ngữ lang, ưu tiên cùng dòng giọng với like
+// ("vi-VN-Chirp3-HD-Puck" -> "en-US-Chirp3-HD-Puck"); "" nếu engine không có giọng nào của ngôn ngữ này
+func LanguageVoice(lang, like string) string {
+	catalog, err := ListVoiceCatalog(context.Background())
+	if err != nil {
+		return ""
+	}
+	return pickLanguageVoice(catalog, lang, like)
+}
+
+// pickLanguageVoice: cùng dòng giọng với like, rồi giọng của vùng mặc định (en -> en-US), rồi giọng bất kỳ của ngôn ngữ
+func pickLanguageVoice(catalog []VoiceInfo, lang, like string) string {
+	family := ""
+	if parts := strings.SplitN(like, "-", 3); len(parts) == 3 {
+		family = parts[2]
+	}
+	locale := languageLocales[lang]
+
+	var sameLocale, first string
+	for _, v := range catalog {
+		if !v.KichHoat || baseLanguage(v.LanguageCode) != lang {
+			continue
+		}
+		if family != "" && strings.HasSuffix(v.Name, "-"+family) && strings.EqualFold(v.LanguageCode, locale) {
+			return v.Name
+		}
+		if sameLocale == "" && strings.EqualFold(v.LanguageCode, locale) {
+			sameLocale = v.Name
+		}
+		if first == "" {
+			first = v.Name
+		}
+	}
+	if sameLocale != "" {
+		return sameLocale
+	}
+	return first
+}
+
+// VoicePreview đọc câu mẫu bằng giọng đã chọn, kết quả lưu trong bộ nhớ theo giọng + tốc độ
+// (đồng thời đi qua bộ nhớ đệm TTS trên storage nên server khởi động lại cũng không tổng hợp lại)
+func VoicePreview(voice string, rate float64) ([]byte, AudioFormat, error) {
+	engine, err := DefaultTTSEngine()
+	if err != nil {
+		return nil, "", err
+	}
+	key := engine.Name() + "|" + voice + "|" + strconv.FormatFloat(rate, 'f', 2, 64)
+
+	voicePreviewMu.Lock()
+	audio, ok := voicePreviewCache[key]
+	voicePreviewMu.Unlock()
+	if ok {
+		return audio, engine.OutputFormat(), nil
+	}
+
+	text, ok := voicePreviewTexts[VoiceLanguage(voice)]
+	if !ok {
+		text = voicePreviewTexts["en"]
+	}
+	audio, err = SynthesizeText(text, voice, rate)
+	if err != nil {
+		return nil, "", err
+	}
+
+	voicePreviewMu.Lock()
+	if len(voicePreviewCache) >= 200 {
+		voicePreviewCache = map[string][]byte{}
+	}
+	voicePreviewCache[key] = audio
+	voicePreviewMu.Unlock()
+	return audio, engine.OutputFormat(), nil
+}
@@ -74,6 +74,36 @@ func UploadBytesToSupabase(data []byte, filename string, contentType string) (st
 	return publicURL, nil
 }
 
+// UploadCacheToSupabase uploads cached synthesized audio, overwriting any existing object
+// Path: uploads/tts-cache/<filename>, returns the object path (not a public URL)
+func UploadCacheToSupabase(data []byte, filename string, contentType string) (string, error) {
+	supabaseURL := os.Getenv("SUPABASE_URL")
+	supabaseKey := os.Getenv("SUPABASE_KEY")
+
+	storageClient := storage.NewClient(supabaseURL+"/storage/v1", supabaseKey, nil)
+
+	objectPath := fmt.Sprintf("tts-cache/%s", filename)
+	upsert := true
+	options := storage.FileOptions{
+		ContentType: &contentType,
+		Upsert:      &upsert,
+	}
+
+	if _, err := storageClient.UploadFile("uploads", objectPath, bytes.NewReader(data), options); err != nil {
+		return "", err
+	}
+	return objectPath, nil
+}
+
+// DownloadFromSupabase downloads an object from the uploads bucket by its path
+func DownloadFromSupabase(objectPath string) ([]byte, error) {
+	supabaseURL := os.Getenv("SUPABASE_URL")
+	supabaseKey := os.Getenv("SUPABASE_KEY")
+
+	storageClient := storage.NewClient(supabaseURL+"/storage/v1", supabaseKey, nil)
+	return storageClient.DownloadFile("uploads", objectPath)
+}
+
 // UploadImageToSupabase uploads an image (e.g. .jpg, .png) to Supabase Storage
 // Path: uploads/images/<fileID>.<ext>
 func UploadImageToSupabase(fileHeader *multipart.FileHeader, fileID string) (string, error) {